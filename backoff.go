@@ -17,13 +17,6 @@ const (
 	maxExponent = 62
 )
 
-var (
-	// 使用独立的随机数生成器，避免全局锁竞争
-	// Use a separate random number generator to avoid global lock contention
-	randGen = rand.New(rand.NewSource(time.Now().UnixNano()))
-	randMu  sync.Mutex
-)
-
 // BackoffFunc 定义了退避策略函数的类型
 // BackoffFunc defines the type for backoff strategy functions
 type BackoffFunc = func(int64) time.Duration
@@ -37,18 +30,28 @@ func FixedBackoff(interval int64) time.Duration {
 	return time.Duration(interval) * baseInterval
 }
 
-// RandomBackoff 返回随机时间间隔的退避策略
-// RandomBackoff returns a random-interval backoff strategy
+// RandomBackoff 返回随机时间间隔的退避策略，随机数来自共享的随机数生成器池，WithSeed 对它无效，需要可重现的结果时使用 NewRandomBackoff 或 random(...) 描述
+// RandomBackoff returns a random-interval backoff strategy, the random numbers come from the shared generator pool so WithSeed has no effect, use NewRandomBackoff or a random(...) spec for reproducible results
 func RandomBackoff(maxInterval int64) time.Duration {
+	return randomBackoff(nil, maxInterval)
+}
+
+// NewRandomBackoff 返回使用指定随机数生成器的随机时间间隔退避策略，返回的函数不是并发安全的
+// NewRandomBackoff returns a random-interval backoff strategy using the specified generator, the returned function is not safe for concurrent use
+func NewRandomBackoff(rng *rand.Rand) BackoffFunc {
+	return func(maxInterval int64) time.Duration {
+		return randomBackoff(rng, maxInterval)
+	}
+}
+
+// randomBackoff 使用指定的随机数生成器计算随机时间间隔，rng 为 nil 时使用随机数生成器池
+// randomBackoff calculates a random interval using the specified generator, falling back to the generator pool when rng is nil
+func randomBackoff(rng *rand.Rand, maxInterval int64) time.Duration {
 	if maxInterval <= 0 {
 		return defaultDelay
 	}
 
-	randMu.Lock()
-	interval := randGen.Int63n(maxInterval)
-	randMu.Unlock()
-
-	return time.Duration(interval) * baseInterval
+	return time.Duration(rngInt63n(rng, maxInterval)) * baseInterval
 }
 
 // ExponentialBackoff 返回指数增长的退避策略
@@ -64,7 +67,14 @@ func ExponentialBackoff(power int64) time.Duration {
 		power = maxExponent
 	}
 
-	return time.Duration(int64(math.Exp2(float64(power)))) * baseInterval
+	// 限制结果不超过 time.Duration 的最大值
+	// Limit the result to the maximum value of time.Duration
+	multiple := int64(math.Exp2(float64(power)))
+	if multiple > math.MaxInt64/int64(baseInterval) {
+		return time.Duration(math.MaxInt64)
+	}
+
+	return time.Duration(multiple) * baseInterval
 }
 
 // CombineBackoffs 将多个退避策略组合成一个
@@ -77,7 +87,7 @@ func CombineBackoffs(backoffs ...BackoffFunc) BackoffFunc {
 	return func(n int64) time.Duration {
 		var totalDelay time.Duration
 		for _, backoff := range backoffs {
-			totalDelay += backoff(n)
+			totalDelay = addDelay(totalDelay, backoff(n))
 		}
 
 		if totalDelay <= 0 {
@@ -86,3 +96,341 @@ func CombineBackoffs(backoffs ...BackoffFunc) BackoffFunc {
 		return totalDelay
 	}
 }
+
+// scaleDelay 将以 baseInterval 为单位的延迟时间转换为以 unit 为单位，结果不超过 time.Duration 的最大值
+// scaleDelay converts a delay expressed in baseInterval units to unit units, the result never exceeds the maximum value of time.Duration
+func scaleDelay(delay, unit time.Duration) time.Duration {
+	if unit <= 0 || unit == baseInterval {
+		return delay
+	}
+
+	scaled := float64(delay) / float64(baseInterval) * float64(unit)
+	if scaled >= math.MaxInt64 {
+		return time.Duration(math.MaxInt64)
+	}
+
+	return time.Duration(scaled)
+}
+
+// addDelay 返回两个延迟时间之和，结果不超过 time.Duration 的最大值
+// addDelay returns the sum of two delays, the result never exceeds the maximum value of time.Duration
+func addDelay(a, b time.Duration) time.Duration {
+	if b > 0 && a > time.Duration(math.MaxInt64)-b {
+		return time.Duration(math.MaxInt64)
+	}
+	return a + b
+}
+
+// normalizeJitterBounds 修正抖动退避策略的基础时间和上限时间
+// normalizeJitterBounds corrects the base and ceil of jitter backoff strategies
+func normalizeJitterBounds(base, ceil time.Duration) (time.Duration, time.Duration) {
+	// 基础时间无效时使用默认的基础时间单位
+	// Use the default base time unit when the base is invalid
+	if base <= 0 {
+		base = baseInterval
+	}
+
+	// 上限时间无效时表示没有上限
+	// An invalid ceil means there is no upper limit
+	if ceil <= 0 {
+		ceil = time.Duration(math.MaxInt64)
+	}
+
+	// 上限时间不能小于基础时间
+	// The ceil cannot be smaller than the base
+	if ceil < base {
+		ceil = base
+	}
+
+	return base, ceil
+}
+
+// cappedExponential 计算 min(ceil, base * 2^n)，并防止溢出
+// cappedExponential computes min(ceil, base * 2^n) and prevents overflow
+func cappedExponential(base, ceil time.Duration, n int64) time.Duration {
+	if n <= 0 {
+		return base
+	}
+
+	// 如果指数过大或乘法会溢出上限，则直接返回上限
+	// If the exponent is too large or the multiplication would exceed the ceil, return the ceil directly
+	if n > maxExponent || base > ceil>>uint(n) {
+		return ceil
+	}
+
+	return base << uint(n)
+}
+
+// NewFullJitterBackoff 返回全抖动退避策略：sleep = random(0, min(ceil, base * 2^n))
+// 随机数来自共享的随机数生成器池，WithSeed 对它无效，需要可重现的结果时使用 NewFullJitterFactory
+// NewFullJitterBackoff returns a full jitter backoff strategy: sleep = random(0, min(ceil, base * 2^n))
+// The random numbers come from the shared generator pool so WithSeed has no effect, use NewFullJitterFactory for reproducible results
+func NewFullJitterBackoff(base, ceil time.Duration) BackoffFunc {
+	base, ceil = normalizeJitterBounds(base, ceil)
+
+	return func(n int64) time.Duration {
+		temp := cappedExponential(base, ceil, n)
+		return rngDuration(nil, temp)
+	}
+}
+
+// NewEqualJitterBackoff 返回等抖动退避策略：temp = min(ceil, base * 2^n)，sleep = temp/2 + random(0, temp/2)
+// 随机数来自共享的随机数生成器池，WithSeed 对它无效，需要可重现的结果时使用 NewEqualJitterFactory
+// NewEqualJitterBackoff returns an equal jitter backoff strategy: temp = min(ceil, base * 2^n), sleep = temp/2 + random(0, temp/2)
+// The random numbers come from the shared generator pool so WithSeed has no effect, use NewEqualJitterFactory for reproducible results
+func NewEqualJitterBackoff(base, ceil time.Duration) BackoffFunc {
+	base, ceil = normalizeJitterBounds(base, ceil)
+
+	return func(n int64) time.Duration {
+		temp := cappedExponential(base, ceil, n)
+		half := temp / 2
+		return half + rngDuration(nil, temp-half)
+	}
+}
+
+// NewDecorrelatedJitterBackoff 返回去相关抖动退避策略：sleep = min(ceil, random(base, sleep * 3))
+// 该策略依赖上一次的延迟时间，因此返回的函数内部保存了状态，并使用互斥锁保护
+// NewDecorrelatedJitterBackoff returns a decorrelated jitter backoff strategy: sleep = min(ceil, random(base, sleep * 3))
+// The strategy depends on the previous delay, so the returned function keeps state internally, guarded by a mutex
+func NewDecorrelatedJitterBackoff(base, ceil time.Duration) BackoffFunc {
+	base, ceil = normalizeJitterBounds(base, ceil)
+
+	var mu sync.Mutex
+	sleep := base
+
+	return func(int64) time.Duration {
+		mu.Lock()
+		defer mu.Unlock()
+
+		sleep = decorrelatedJitter(nil, base, ceil, sleep)
+		return sleep
+	}
+}
+
+// decorrelatedJitter 根据上一次的延迟时间计算下一次的去相关抖动延迟
+// decorrelatedJitter calculates the next decorrelated jitter delay from the previous delay
+func decorrelatedJitter(rng *rand.Rand, base, ceil, prev time.Duration) time.Duration {
+	// 计算 prev * 3，并防止溢出
+	// Compute prev * 3 and prevent overflow
+	upper := ceil
+	if prev <= ceil/3 {
+		upper = prev * 3
+	}
+	if upper < base {
+		upper = base
+	}
+
+	sleep := base + rngDuration(rng, upper-base)
+	if sleep > ceil {
+		sleep = ceil
+	}
+
+	return sleep
+}
+
+// BackoffFactory 定义了创建 Backoff 实例的工厂函数类型
+// BackoffFactory defines the type of factory functions that create Backoff instances
+type BackoffFactory = func() Backoff
+
+// funcBackoff 将无状态的 BackoffFunc 适配为 Backoff，直接使用执行次数作为参数
+// funcBackoff adapts a stateless BackoffFunc to Backoff, using the execution count directly as the argument
+type funcBackoff struct {
+	fn BackoffFunc
+}
+
+// Next 方法返回 BackoffFunc 针对执行次数计算的延迟时间
+// The Next method returns the delay calculated by the BackoffFunc for the execution count
+func (b *funcBackoff) Next(attempt int64, _ error) (time.Duration, bool) {
+	return b.fn(attempt), true
+}
+
+// Reset 方法不执行任何操作，因为 BackoffFunc 没有状态
+// The Reset method does nothing because a BackoffFunc has no state
+func (b *funcBackoff) Reset() {}
+
+// FuncBackoff 返回一个将 BackoffFunc 适配为 Backoff 的工厂函数，BackoffFunc 的参数为执行次数
+// FuncBackoff returns a factory that adapts a BackoffFunc to Backoff, the BackoffFunc argument is the execution count
+func FuncBackoff(fn BackoffFunc) BackoffFactory {
+	if fn == nil {
+		fn = FixedBackoff
+	}
+
+	return func() Backoff {
+		return &funcBackoff{fn: fn}
+	}
+}
+
+// legacyBackoff 将 BackoffFunc 适配为 Backoff，并保留原有的参数计算方式：rand * jitter + count * factor
+// legacyBackoff adapts a BackoffFunc to Backoff while keeping the original argument calculation: rand * jitter + count * factor
+// fn 为 nil 时使用默认的退避函数：指数退避与随机退避之和，其中的随机部分使用本实例的随机数生成器
+// When fn is nil the default backoff function is used: the sum of exponential and random backoff, whose random part uses the generator of this instance
+type legacyBackoff struct {
+	fn     BackoffFunc
+	jitter float64
+	factor float64
+	unit   time.Duration
+	rng    *rand.Rand
+}
+
+// newLegacyBackoff 函数创建一个新的 legacyBackoff 实例
+// The newLegacyBackoff function creates a new legacyBackoff instance
+func newLegacyBackoff(fn BackoffFunc, jitter, factor float64, unit time.Duration) *legacyBackoff {
+	return &legacyBackoff{fn: fn, jitter: jitter, factor: factor, unit: unit}
+}
+
+// Next 方法使用随机的抖动和执行次数的乘积作为参数调用 BackoffFunc
+// The Next method calls the BackoffFunc with a random jitter plus the product of the execution count and factor
+func (b *legacyBackoff) Next(attempt int64, _ error) (time.Duration, bool) {
+	n := int64(rngFloat64(b.rng)*b.jitter + float64(attempt)*b.factor)
+
+	// 如果计算出的参数小于等于 0，则使用默认的延迟数值
+	// If the calculated argument is less than or equal to 0, use the default delay number
+	if n <= 0 {
+		n = defaultDelayNum
+	}
+
+	// 第一次使用时创建默认的退避函数，此时随机数生成器已经设置完成
+	// Create the default backoff function on first use, by which time the generator has been set
+	if b.fn == nil {
+		b.fn = newDefaultBackoffFunc(b.rng)
+	}
+
+	return scaleDelay(b.fn(n), b.unit), true
+}
+
+// Reset 方法不执行任何操作，因为 BackoffFunc 没有状态
+// The Reset method does nothing because a BackoffFunc has no state
+func (b *legacyBackoff) Reset() {}
+
+// SetRand 方法设置本实例使用的随机数生成器
+// The SetRand method sets the random number generator used by this instance
+func (b *legacyBackoff) SetRand(rng *rand.Rand) {
+	b.rng = rng
+}
+
+// statelessBackoff 是只依赖执行次数和随机数生成器的无状态退避策略，随机数生成器由每次调用传入
+// statelessBackoff is a stateless backoff that only depends on the execution count and the random number generator, which is passed in for every call
+type statelessBackoff struct {
+	next func(attempt int64, rng *rand.Rand) time.Duration
+	rng  *rand.Rand
+}
+
+// Next 方法返回指定执行次数对应的延迟时间
+// The Next method returns the delay for the specified execution count
+func (b *statelessBackoff) Next(attempt int64, _ error) (time.Duration, bool) {
+	return b.next(attempt, b.rng), true
+}
+
+// Reset 方法不执行任何操作
+// The Reset method does nothing
+func (b *statelessBackoff) Reset() {}
+
+// SetRand 方法设置本实例使用的随机数生成器
+// The SetRand method sets the random number generator used by this instance
+func (b *statelessBackoff) SetRand(rng *rand.Rand) {
+	b.rng = rng
+}
+
+// NewFullJitterFactory 返回一个创建全抖动退避策略的工厂函数，第 n 次执行后 sleep = random(0, min(ceil, base * 2^(n-1)))
+// 随机数来自每次调用的随机数生成器，因此 WithSeed 对它有效
+// NewFullJitterFactory returns a factory for full jitter backoffs, after the n-th execution sleep = random(0, min(ceil, base * 2^(n-1)))
+// The random numbers come from the generator of each call, so WithSeed applies to it
+func NewFullJitterFactory(base, ceil time.Duration) BackoffFactory {
+	base, ceil = normalizeJitterBounds(base, ceil)
+
+	return func() Backoff {
+		return &statelessBackoff{next: func(attempt int64, rng *rand.Rand) time.Duration {
+			return rngDuration(rng, cappedExponential(base, ceil, attempt-1))
+		}}
+	}
+}
+
+// NewEqualJitterFactory 返回一个创建等抖动退避策略的工厂函数，第 n 次执行后 temp = min(ceil, base * 2^(n-1))，sleep = temp/2 + random(0, temp/2)
+// 随机数来自每次调用的随机数生成器，因此 WithSeed 对它有效
+// NewEqualJitterFactory returns a factory for equal jitter backoffs, after the n-th execution temp = min(ceil, base * 2^(n-1)) and sleep = temp/2 + random(0, temp/2)
+// The random numbers come from the generator of each call, so WithSeed applies to it
+func NewEqualJitterFactory(base, ceil time.Duration) BackoffFactory {
+	base, ceil = normalizeJitterBounds(base, ceil)
+
+	return func() Backoff {
+		return &statelessBackoff{next: func(attempt int64, rng *rand.Rand) time.Duration {
+			temp := cappedExponential(base, ceil, attempt-1)
+			half := temp / 2
+			return half + rngDuration(rng, temp-half)
+		}}
+	}
+}
+
+// decorrelatedJitterBackoff 是去相关抖动退避策略的有状态实现
+// decorrelatedJitterBackoff is the stateful implementation of the decorrelated jitter backoff strategy
+type decorrelatedJitterBackoff struct {
+	base  time.Duration
+	ceil  time.Duration
+	sleep time.Duration
+	rng   *rand.Rand
+}
+
+// Next 方法根据上一次的延迟时间计算下一次的延迟时间
+// The Next method calculates the next delay from the previous delay
+func (b *decorrelatedJitterBackoff) Next(int64, error) (time.Duration, bool) {
+	b.sleep = decorrelatedJitter(b.rng, b.base, b.ceil, b.sleep)
+	return b.sleep, true
+}
+
+// Reset 方法将上一次的延迟时间恢复为基础时间
+// The Reset method restores the previous delay to the base
+func (b *decorrelatedJitterBackoff) Reset() {
+	b.sleep = b.base
+}
+
+// SetRand 方法设置本实例使用的随机数生成器
+// The SetRand method sets the random number generator used by this instance
+func (b *decorrelatedJitterBackoff) SetRand(rng *rand.Rand) {
+	b.rng = rng
+}
+
+// NewDecorrelatedJitterFactory 返回一个创建去相关抖动退避策略的工厂函数，每个实例独立保存上一次的延迟时间
+// NewDecorrelatedJitterFactory returns a factory for decorrelated jitter backoffs, each instance keeps its own previous delay
+func NewDecorrelatedJitterFactory(base, ceil time.Duration) BackoffFactory {
+	base, ceil = normalizeJitterBounds(base, ceil)
+
+	return func() Backoff {
+		return &decorrelatedJitterBackoff{base: base, ceil: ceil, sleep: base}
+	}
+}
+
+// limitedBackoff 在执行次数达到上限后停止重试
+// limitedBackoff stops retrying once the execution count reaches the limit
+type limitedBackoff struct {
+	backoff Backoff
+	limit   int64
+}
+
+// Next 方法在执行次数未达到上限时返回内部退避策略的延迟时间
+// The Next method returns the delay of the inner backoff while the execution count is below the limit
+func (b *limitedBackoff) Next(attempt int64, lastErr error) (time.Duration, bool) {
+	if attempt >= b.limit {
+		return 0, false
+	}
+	return b.backoff.Next(attempt, lastErr)
+}
+
+// Reset 方法重置内部退避策略
+// The Reset method resets the inner backoff
+func (b *limitedBackoff) Reset() {
+	b.backoff.Reset()
+}
+
+// SetRand 方法将随机数生成器传递给内部退避策略
+// The SetRand method passes the random number generator to the inner backoff
+func (b *limitedBackoff) SetRand(rng *rand.Rand) {
+	setBackoffRand(b.backoff, rng)
+}
+
+// LimitBackoff 返回一个工厂函数，创建的退避策略在执行 limit 次后停止重试
+// LimitBackoff returns a factory whose backoffs stop retrying after limit executions
+func LimitBackoff(factory BackoffFactory, limit int64) BackoffFactory {
+	return func() Backoff {
+		return &limitedBackoff{backoff: factory(), limit: limit}
+	}
+}
@@ -1,6 +1,7 @@
 package retry
 
 import (
+	"errors"
 	"math"
 	"sync"
 	"testing"
@@ -96,7 +97,7 @@ func TestExponentialBackoff(t *testing.T) {
 		{
 			name:     "max exponential",
 			input:    maxExponent + 1,
-			expected: time.Duration(int64(math.Exp2(float64(maxExponent)))) * baseInterval,
+			expected: time.Duration(math.MaxInt64),
 		},
 	}
 
@@ -194,3 +195,258 @@ func BenchmarkCombinedBackoffs(b *testing.B) {
 		_ = combined(3)
 	}
 }
+
+func TestFullJitterBackoff(t *testing.T) {
+	base := 10 * time.Millisecond
+	ceil := 200 * time.Millisecond
+	backoff := NewFullJitterBackoff(base, ceil)
+
+	for n := int64(0); n < 10; n++ {
+		upper := cappedExponential(base, ceil, n)
+		for i := 0; i < 100; i++ {
+			result := backoff(n)
+			assert.GreaterOrEqual(t, result, time.Duration(0))
+			assert.LessOrEqual(t, result, upper)
+		}
+	}
+}
+
+func TestFullJitterBackoff_UncappedLargeAttempt(t *testing.T) {
+	// 没有上限时 min(ceil, base * 2^n) 为 MaxInt64，随机范围加一不能溢出
+	// Without a ceil min(ceil, base * 2^n) is MaxInt64, and widening the random range by one must not overflow
+	backoff := NewFullJitterBackoff(time.Millisecond, 0)
+
+	var nonZero bool
+	for i := 0; i < 10; i++ {
+		result := backoff(100)
+		assert.GreaterOrEqual(t, result, time.Duration(0))
+		nonZero = nonZero || result > 0
+	}
+	assert.True(t, nonZero)
+}
+
+func TestEqualJitterBackoff(t *testing.T) {
+	base := 10 * time.Millisecond
+	ceil := 200 * time.Millisecond
+	backoff := NewEqualJitterBackoff(base, ceil)
+
+	for n := int64(0); n < 10; n++ {
+		upper := cappedExponential(base, ceil, n)
+		for i := 0; i < 100; i++ {
+			result := backoff(n)
+			assert.GreaterOrEqual(t, result, upper/2)
+			assert.LessOrEqual(t, result, upper)
+		}
+	}
+}
+
+func TestDecorrelatedJitterBackoff(t *testing.T) {
+	base := 10 * time.Millisecond
+	ceil := 200 * time.Millisecond
+	backoff := NewDecorrelatedJitterBackoff(base, ceil)
+
+	prev := base
+	for i := 0; i < 1000; i++ {
+		result := backoff(int64(i))
+		assert.GreaterOrEqual(t, result, base)
+		assert.LessOrEqual(t, result, ceil)
+		assert.LessOrEqual(t, result, prev*3)
+		prev = result
+	}
+}
+
+func TestJitterBackoffBounds(t *testing.T) {
+	tests := []struct {
+		name     string
+		base     time.Duration
+		ceil     time.Duration
+		n        int64
+		expected time.Duration
+	}{
+		{
+			name:     "zero base",
+			base:     0,
+			ceil:     time.Second,
+			n:        0,
+			expected: baseInterval,
+		},
+		{
+			name:     "ceil smaller than base",
+			base:     time.Second,
+			ceil:     time.Millisecond,
+			n:        3,
+			expected: time.Second,
+		},
+		{
+			name:     "no ceil",
+			base:     time.Millisecond,
+			ceil:     0,
+			n:        3,
+			expected: 8 * time.Millisecond,
+		},
+		{
+			name:     "overflow",
+			base:     time.Second,
+			ceil:     0,
+			n:        maxExponent + 1,
+			expected: time.Duration(math.MaxInt64),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base, ceil := normalizeJitterBounds(tt.base, tt.ceil)
+			assert.Equal(t, tt.expected, cappedExponential(base, ceil, tt.n))
+		})
+	}
+}
+
+func TestFuncBackoff(t *testing.T) {
+	backoff := FuncBackoff(FixedBackoff)()
+
+	for attempt := int64(1); attempt <= 3; attempt++ {
+		delay, ok := backoff.Next(attempt, nil)
+		assert.True(t, ok)
+		assert.Equal(t, FixedBackoff(attempt), delay)
+	}
+
+	backoff = FuncBackoff(nil)()
+	delay, ok := backoff.Next(2, nil)
+	assert.True(t, ok)
+	assert.Equal(t, FixedBackoff(2), delay)
+}
+
+func TestLimitBackoff(t *testing.T) {
+	backoff := LimitBackoff(FuncBackoff(FixedBackoff), 3)()
+
+	_, ok := backoff.Next(1, nil)
+	assert.True(t, ok)
+	_, ok = backoff.Next(2, nil)
+	assert.True(t, ok)
+	_, ok = backoff.Next(3, nil)
+	assert.False(t, ok)
+}
+
+func TestDecorrelatedJitterFactory(t *testing.T) {
+	base := 10 * time.Millisecond
+	ceil := 200 * time.Millisecond
+	factory := NewDecorrelatedJitterFactory(base, ceil)
+
+	backoff := factory()
+	prev := base
+	for attempt := int64(1); attempt <= 100; attempt++ {
+		delay, ok := backoff.Next(attempt, nil)
+		assert.True(t, ok)
+		assert.GreaterOrEqual(t, delay, base)
+		assert.LessOrEqual(t, delay, ceil)
+		assert.LessOrEqual(t, delay, prev*3)
+		prev = delay
+	}
+
+	// 重置后第一次的延迟时间不超过 base * 3
+	// After a reset the first delay does not exceed base * 3
+	backoff.Reset()
+	delay, _ := backoff.Next(1, nil)
+	assert.LessOrEqual(t, delay, base*3)
+
+	// 不同的实例之间不共享状态
+	// Different instances do not share state
+	assert.NotSame(t, factory(), factory())
+}
+
+func TestJitterFactory(t *testing.T) {
+	base := 10 * time.Millisecond
+	ceil := 80 * time.Millisecond
+
+	for attempt := int64(1); attempt <= 10; attempt++ {
+		temp := cappedExponential(base, ceil, attempt-1)
+
+		delay, ok := NewFullJitterFactory(base, ceil)().Next(attempt, nil)
+		assert.True(t, ok)
+		assert.GreaterOrEqual(t, delay, time.Duration(0))
+		assert.LessOrEqual(t, delay, temp)
+
+		delay, ok = NewEqualJitterFactory(base, ceil)().Next(attempt, nil)
+		assert.True(t, ok)
+		assert.GreaterOrEqual(t, delay, temp/2)
+		assert.LessOrEqual(t, delay, temp)
+	}
+}
+
+func TestJitterFactory_Seeded(t *testing.T) {
+	factories := map[string]BackoffFactory{
+		"full":         NewFullJitterFactory(time.Millisecond, 5*time.Millisecond),
+		"equal":        NewEqualJitterFactory(time.Millisecond, 5*time.Millisecond),
+		"decorrelated": NewDecorrelatedJitterFactory(time.Millisecond, 5*time.Millisecond),
+		"limited":      LimitBackoff(NewDecorrelatedJitterFactory(time.Millisecond, 5*time.Millisecond), 10),
+		"spec":         MustParseBackoff("chain(decorrelated(base=1ms,max=5ms)x2, exponential(base=1ms,max=5ms,jitter=full))").Factory(),
+	}
+
+	for name, factory := range factories {
+		t.Run(name, func(t *testing.T) {
+			run := func() []time.Duration {
+				cb := &delayCallback{}
+				cfg := NewConfig().
+					WithAttempts(6).
+					WithInitDelay(time.Millisecond).
+					WithBackoffFactory(factory).
+					WithSeed(7).
+					WithCallback(cb)
+				New(cfg).TryOnConflictVal(func() (any, error) { return nil, errors.New("failed") })
+				return cb.delays
+			}
+
+			// 相同的种子得到相同的延迟时间
+			// The same seed gives the same delays
+			first := run()
+			assert.NotEmpty(t, first)
+			assert.Equal(t, first, run())
+		})
+	}
+}
+
+func TestScaleDelay(t *testing.T) {
+	tests := []struct {
+		name     string
+		delay    time.Duration
+		unit     time.Duration
+		expected time.Duration
+	}{
+		{
+			name:     "default unit",
+			delay:    3 * baseInterval,
+			unit:     baseInterval,
+			expected: 3 * baseInterval,
+		},
+		{
+			name:     "millisecond unit",
+			delay:    3 * baseInterval,
+			unit:     time.Millisecond,
+			expected: 3 * time.Millisecond,
+		},
+		{
+			name:     "invalid unit",
+			delay:    3 * baseInterval,
+			unit:     0,
+			expected: 3 * baseInterval,
+		},
+		{
+			name:     "overflow",
+			delay:    time.Duration(math.MaxInt64),
+			unit:     time.Hour,
+			expected: time.Duration(math.MaxInt64),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, scaleDelay(tt.delay, tt.unit))
+		})
+	}
+}
+
+func TestAddDelay(t *testing.T) {
+	assert.Equal(t, 3*time.Second, addDelay(time.Second, 2*time.Second))
+	assert.Equal(t, time.Duration(math.MaxInt64), addDelay(time.Duration(math.MaxInt64), time.Second))
+	assert.Equal(t, time.Duration(math.MaxInt64), CombineBackoffs(ExponentialBackoff, ExponentialBackoff)(maxExponent))
+}
@@ -0,0 +1,68 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// UpdateOnConflict 函数执行乐观的读取-修改-写入：get 读取带版本的值，mutate 计算新的值，cas 在版本没有变化时写入
+// 只有 cas 返回包装了 ErrorConflict 的错误时才重新读取并重试，get 和 mutate 的错误会直接返回，mutate 应该是没有副作用的纯函数
+// 第一次执行总是立即进行，重试次数用完时返回包装了 ErrorConflictAttemptsExceeded 的错误，上下文结束时返回上下文的错误
+// The UpdateOnConflict function performs an optimistic read-modify-write: get reads a versioned value, mutate computes the new value and cas writes it if the version is unchanged
+// It only reads again and retries when cas returns an error wrapping ErrorConflict, errors from get and mutate are returned directly, and mutate should be a pure function without side effects
+// The first run always happens immediately, an error wrapping ErrorConflictAttemptsExceeded is returned when the attempts run out, and the context error when the context is done
+func UpdateOnConflict[T, V any](
+	ctx context.Context,
+	conf *Config,
+	get func(ctx context.Context) (T, V, error),
+	mutate func(value T) (T, error),
+	cas func(ctx context.Context, value T, version V) error,
+) (T, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	var (
+		updated T
+		lastErr error
+	)
+
+	r := newImmediateRetry(conf, func(err error) bool { return errors.Is(err, ErrorConflict) })
+	result := r.TryOnConflictContext(ctx, func() (any, error) {
+		value, version, err := get(ctx)
+		if err != nil {
+			lastErr = err
+			return nil, err
+		}
+
+		value, err = mutate(value)
+		if err != nil {
+			lastErr = err
+			return nil, err
+		}
+
+		if err := cas(ctx, value, version); err != nil {
+			lastErr = err
+			return nil, err
+		}
+
+		updated = value
+		return nil, nil
+	})
+
+	var zero T
+	if result.IsSuccess() {
+		return updated, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return zero, err
+	}
+	if errors.Is(lastErr, ErrorConflict) {
+		return zero, fmt.Errorf("%w after %d attempts: %v", ErrorConflictAttemptsExceeded, result.Count(), lastErr)
+	}
+	if lastErr != nil {
+		return zero, lastErr
+	}
+	return zero, result.TryError()
+}
@@ -0,0 +1,117 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// versionedStore 结构体是一个带版本的内存存储
+// The versionedStore struct is an in-memory store with versions
+type versionedStore struct {
+	mu      sync.Mutex
+	value   int
+	version int64
+	races   int // 每次 cas 之前由其他写入者修改的次数 Number of times another writer changes the value before cas
+}
+
+func (s *versionedStore) get(context.Context) (int, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.value, s.version, nil
+}
+
+func (s *versionedStore) cas(_ context.Context, value int, version int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.races > 0 {
+		s.races--
+		s.value += 100
+		s.version++
+	}
+	if version != s.version {
+		return fmt.Errorf("version %d is stale: %w", version, ErrorConflict)
+	}
+
+	s.value, s.version = value, s.version+1
+	return nil
+}
+
+func newTestCASConfig(attempts uint64) *Config {
+	return NewConfig().
+		WithInitDelay(time.Millisecond).
+		WithBackOffFunc(func(int64) time.Duration { return 0 }).
+		WithAttempts(attempts)
+}
+
+func increment(v int) (int, error) { return v + 1, nil }
+
+func TestUpdateOnConflict(t *testing.T) {
+	store := &versionedStore{races: 2}
+
+	value, err := UpdateOnConflict(context.Background(), newTestCASConfig(5), store.get, increment, store.cas)
+	assert.NoError(t, err)
+
+	// 每次冲突都会重新读取最新的值
+	// Every conflict reads the latest value again
+	assert.Equal(t, 201, value)
+	assert.Equal(t, 201, store.value)
+	assert.Equal(t, int64(3), store.version)
+}
+
+func TestUpdateOnConflict_AttemptsExceeded(t *testing.T) {
+	store := &versionedStore{races: 10}
+
+	value, err := UpdateOnConflict(context.Background(), newTestCASConfig(3), store.get, increment, store.cas)
+	assert.True(t, errors.Is(err, ErrorConflictAttemptsExceeded))
+	assert.Contains(t, err.Error(), "after 3 attempts")
+	assert.Equal(t, 0, value)
+	assert.Equal(t, 7, store.races)
+}
+
+func TestUpdateOnConflict_NoRetry(t *testing.T) {
+	store := &versionedStore{}
+	e := errors.New("not found")
+
+	calls := 0
+	_, err := UpdateOnConflict(context.Background(), newTestCASConfig(5),
+		func(context.Context) (int, int64, error) {
+			calls++
+			return 0, 0, e
+		}, increment, store.cas)
+	assert.Equal(t, e, err)
+	assert.Equal(t, 1, calls)
+
+	// mutate 和 cas 的其他错误也不会重试
+	// Other errors from mutate and cas are not retried either
+	_, err = UpdateOnConflict(context.Background(), newTestCASConfig(5), store.get,
+		func(int) (int, error) { return 0, e }, store.cas)
+	assert.Equal(t, e, err)
+
+	calls = 0
+	_, err = UpdateOnConflict(context.Background(), newTestCASConfig(5), store.get, increment,
+		func(context.Context, int, int64) error {
+			calls++
+			return e
+		})
+	assert.Equal(t, e, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestUpdateOnConflict_ContextCanceled(t *testing.T) {
+	store := &versionedStore{races: 10}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	_, err := UpdateOnConflict(ctx, newTestCASConfig(5), store.get,
+		func(v int) (int, error) {
+			cancel()
+			return v + 1, nil
+		}, store.cas)
+	assert.Equal(t, context.Canceled, err)
+}
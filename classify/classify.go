@@ -0,0 +1,175 @@
+// Package classify 提供了常见的临时错误分类函数，它们可以通过 And、Or 和 Not 组合，并直接传给 retry.Config.WithRetryIfFunc
+// Package classify provides classifiers for common transient errors, they can be combined with And, Or and Not and passed straight to retry.Config.WithRetryIfFunc
+package classify
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+
+	"github.com/shengyanli1982/retry"
+)
+
+// Classifier 类型定义了判断错误是否应该重试的函数类型，与 retry.RetryIfFunc 相同
+// The Classifier type defines the function type that reports whether an error should be retried, the same as retry.RetryIfFunc
+type Classifier = retry.RetryIfFunc
+
+// TransientErrnos 是默认被视为临时错误的系统调用错误码，在 Windows 上还包括对应的 Winsock 错误码
+// TransientErrnos are the syscall error numbers treated as transient by default, including the matching Winsock error numbers on Windows
+var TransientErrnos = append([]syscall.Errno{
+	syscall.EAGAIN,
+	syscall.EINTR,
+	syscall.ECONNRESET,
+	syscall.ECONNREFUSED,
+	syscall.ETIMEDOUT,
+	syscall.EPIPE,
+}, platformTransientErrnos...)
+
+// TransientStatusCodes 是默认被视为临时错误的 HTTP 状态码
+// TransientStatusCodes are the HTTP status codes treated as transient by default
+var TransientStatusCodes = []int{
+	http.StatusRequestTimeout,
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// statusCoder 接口由带有 HTTP 状态码的错误实现
+// The statusCoder interface is implemented by errors carrying an HTTP status code
+type statusCoder interface {
+	StatusCode() int
+}
+
+// And 函数返回一个分类函数，所有的分类函数都返回 true 时才返回 true，没有分类函数时返回 true
+// The And function returns a classifier that returns true only when every classifier does, it returns true without classifiers
+func And(classifiers ...Classifier) Classifier {
+	return func(err error) bool {
+		for _, c := range classifiers {
+			if !c(err) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Or 函数返回一个分类函数，任意一个分类函数返回 true 时返回 true，没有分类函数时返回 false
+// The Or function returns a classifier that returns true when any classifier does, it returns false without classifiers
+func Or(classifiers ...Classifier) Classifier {
+	return func(err error) bool {
+		for _, c := range classifiers {
+			if c(err) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not 函数返回一个与 c 结果相反的分类函数
+// The Not function returns a classifier with the opposite result of c
+func Not(c Classifier) Classifier {
+	return func(err error) bool {
+		return !c(err)
+	}
+}
+
+// Errno 函数返回一个分类函数，错误链中包含指定的系统调用错误码时返回 true，没有指定错误码时使用 TransientErrnos
+// The Errno function returns a classifier that returns true when the error chain contains one of the syscall error numbers, TransientErrnos is used when none are given
+func Errno(errnos ...syscall.Errno) Classifier {
+	if len(errnos) == 0 {
+		errnos = TransientErrnos
+	}
+	errnos = append([]syscall.Errno(nil), errnos...)
+
+	return func(err error) bool {
+		for _, errno := range errnos {
+			if errors.Is(err, errno) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// NetTimeout 函数判断错误是否是超时的 net.Error
+// The NetTimeout function reports whether the error is a net.Error that timed out
+func NetTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// DNSTemporary 函数判断错误是否是临时的或超时的 *net.DNSError
+// The DNSTemporary function reports whether the error is a temporary or timed out *net.DNSError
+func DNSTemporary(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && (dnsErr.IsTemporary || dnsErr.IsTimeout)
+}
+
+// DeadlineExceeded 函数判断错误是否是 context.DeadlineExceeded，例如单次执行的超时
+// The DeadlineExceeded function reports whether the error is context.DeadlineExceeded, such as the timeout of a single execution
+func DeadlineExceeded(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded)
+}
+
+// Canceled 函数判断错误是否是 context.Canceled，被取消的操作通常不应该重试
+// The Canceled function reports whether the error is context.Canceled, cancelled operations should usually not be retried
+func Canceled(err error) bool {
+	return errors.Is(err, context.Canceled)
+}
+
+// UnexpectedEOF 函数判断错误是否是 io.ErrUnexpectedEOF，例如连接在响应中途被关闭
+// The UnexpectedEOF function reports whether the error is io.ErrUnexpectedEOF, such as a connection closed in the middle of a response
+func UnexpectedEOF(err error) bool {
+	return errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// HTTPStatus 函数返回一个分类函数，错误带有指定的 HTTP 状态码时返回 true，没有指定状态码时使用 TransientStatusCodes
+// 状态码从 *retry.StatusError 或实现了 StatusCode() int 的错误中获取
+// The HTTPStatus function returns a classifier that returns true when the error carries one of the HTTP status codes, TransientStatusCodes is used when none are given
+// The status code is read from *retry.StatusError or errors implementing StatusCode() int
+func HTTPStatus(codes ...int) Classifier {
+	if len(codes) == 0 {
+		codes = TransientStatusCodes
+	}
+	set := make(map[int]struct{}, len(codes))
+	for _, code := range codes {
+		set[code] = struct{}{}
+	}
+
+	return func(err error) bool {
+		code, ok := statusCode(err)
+		if !ok {
+			return false
+		}
+		_, ok = set[code]
+		return ok
+	}
+}
+
+// statusCode 函数从错误链中获取 HTTP 状态码
+// The statusCode function gets the HTTP status code from the error chain
+func statusCode(err error) (int, bool) {
+	var statusErr *retry.StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode, true
+	}
+	var coder statusCoder
+	if errors.As(err, &coder) {
+		return coder.StatusCode(), true
+	}
+	return 0, false
+}
+
+// Transient 是一个组合的分类函数，包括默认的系统调用错误码、net.Error 超时、DNS 临时错误、
+// context.DeadlineExceeded、io.ErrUnexpectedEOF 和默认的 HTTP 状态码，context.Canceled 总是返回 false
+// Transient is a combined classifier covering the default syscall error numbers, net.Error timeouts, temporary DNS errors,
+// context.DeadlineExceeded, io.ErrUnexpectedEOF and the default HTTP status codes, it always returns false for context.Canceled
+var Transient = And(
+	Not(Canceled),
+	Or(Errno(), NetTimeout, DNSTemporary, DeadlineExceeded, UnexpectedEOF, HTTPStatus()),
+)
@@ -0,0 +1,9 @@
+//go:build !windows
+
+package classify
+
+import "syscall"
+
+// platformTransientErrnos 是当前平台额外的临时错误码，Unix 上 TransientErrnos 已经覆盖了所有的错误码
+// platformTransientErrnos are the extra transient error numbers of the current platform, TransientErrnos already covers them all on Unix
+var platformTransientErrnos []syscall.Errno
@@ -0,0 +1,119 @@
+package classify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/shengyanli1982/retry"
+	"github.com/stretchr/testify/assert"
+)
+
+type statusError struct {
+	code int
+}
+
+func (e *statusError) Error() string   { return fmt.Sprintf("status %d", e.code) }
+func (e *statusError) StatusCode() int { return e.code }
+
+func TestCombinators(t *testing.T) {
+	yes := func(error) bool { return true }
+	no := func(error) bool { return false }
+	e := errors.New("test")
+
+	assert.True(t, And()(e))
+	assert.True(t, And(yes, yes)(e))
+	assert.False(t, And(yes, no)(e))
+
+	assert.False(t, Or()(e))
+	assert.True(t, Or(no, yes)(e))
+	assert.False(t, Or(no, no)(e))
+
+	assert.False(t, Not(yes)(e))
+	assert.True(t, Not(no)(e))
+}
+
+func TestErrno(t *testing.T) {
+	opErr := &net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}
+
+	assert.True(t, Errno()(opErr))
+	assert.True(t, Errno()(fmt.Errorf("wrapped: %w", syscall.EAGAIN)))
+	assert.False(t, Errno()(syscall.ENOENT))
+	assert.False(t, Errno()(errors.New("connection reset")))
+
+	assert.True(t, Errno(syscall.ENOENT)(syscall.ENOENT))
+	assert.False(t, Errno(syscall.ENOENT)(opErr))
+
+	// 当前平台的每个默认错误码在网络错误中都被视为临时错误
+	// Every default error number of the current platform is transient inside a network error
+	for _, errno := range TransientErrnos {
+		assert.True(t, Transient(&net.OpError{Op: "read", Err: os.NewSyscallError("wsarecv", errno)}), errno.Error())
+	}
+}
+
+func TestNetAndDNS(t *testing.T) {
+	assert.True(t, NetTimeout(&net.OpError{Op: "dial", Err: os.ErrDeadlineExceeded}))
+	assert.False(t, NetTimeout(&net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}))
+
+	assert.True(t, DNSTemporary(&net.DNSError{Err: "server misbehaving", IsTemporary: true}))
+	assert.True(t, DNSTemporary(fmt.Errorf("lookup: %w", &net.DNSError{Err: "timeout", IsTimeout: true})))
+	assert.False(t, DNSTemporary(&net.DNSError{Err: "no such host", IsNotFound: true}))
+}
+
+func TestContextAndEOF(t *testing.T) {
+	assert.True(t, DeadlineExceeded(fmt.Errorf("query: %w", context.DeadlineExceeded)))
+	assert.False(t, DeadlineExceeded(context.Canceled))
+	assert.True(t, Canceled(context.Canceled))
+	assert.False(t, Canceled(context.DeadlineExceeded))
+
+	assert.True(t, UnexpectedEOF(fmt.Errorf("body: %w", io.ErrUnexpectedEOF)))
+	assert.False(t, UnexpectedEOF(io.EOF))
+}
+
+func TestHTTPStatus(t *testing.T) {
+	assert.True(t, HTTPStatus()(&retry.StatusError{StatusCode: 503}))
+	assert.True(t, HTTPStatus()(fmt.Errorf("wrapped: %w", &statusError{code: 429})))
+	assert.False(t, HTTPStatus()(&statusError{code: 500}))
+	assert.False(t, HTTPStatus()(errors.New("503")))
+
+	assert.True(t, HTTPStatus(500)(&statusError{code: 500}))
+	assert.False(t, HTTPStatus(500)(&statusError{code: 503}))
+}
+
+func TestTransient(t *testing.T) {
+	assert.True(t, Transient(syscall.ECONNREFUSED))
+	assert.True(t, Transient(&net.DNSError{IsTemporary: true}))
+	assert.True(t, Transient(context.DeadlineExceeded))
+	assert.True(t, Transient(io.ErrUnexpectedEOF))
+	assert.True(t, Transient(&retry.StatusError{StatusCode: 502}))
+
+	assert.False(t, Transient(context.Canceled))
+	assert.False(t, Transient(errors.New("invalid argument")))
+	assert.False(t, Transient(&statusError{code: 404}))
+}
+
+func TestWithRetryIfFunc(t *testing.T) {
+	cfg := retry.NewConfig().
+		WithInitDelay(time.Millisecond).
+		WithBackOffFunc(func(int64) time.Duration { return 0 }).
+		WithAttempts(5).
+		WithRetryIfFunc(And(Transient, Not(HTTPStatus(429))))
+
+	count := 0
+	result := retry.Do(func() (any, error) {
+		count++
+		if count < 3 {
+			return nil, syscall.ECONNRESET
+		}
+		return nil, &statusError{code: 429}
+	}, cfg)
+
+	assert.Equal(t, retry.ErrorRetryIf, result.TryError())
+	assert.Equal(t, 3, count)
+}
@@ -0,0 +1,18 @@
+//go:build windows
+
+package classify
+
+import "syscall"
+
+// platformTransientErrnos 是 Windows 上与 TransientErrnos 对应的 Winsock 错误码，Windows 上的网络错误不会包含 syscall.ECONNRESET 这样的值
+// platformTransientErrnos are the Winsock error numbers matching TransientErrnos on Windows, network errors on Windows never carry values like syscall.ECONNRESET
+var platformTransientErrnos = []syscall.Errno{
+	syscall.Errno(10004), // WSAEINTR
+	syscall.Errno(10035), // WSAEWOULDBLOCK
+	syscall.WSAECONNABORTED,
+	syscall.WSAECONNRESET,
+	syscall.Errno(10060), // WSAETIMEDOUT
+	syscall.Errno(10061), // WSAECONNREFUSED
+	syscall.ERROR_NETNAME_DELETED,
+	syscall.ERROR_BROKEN_PIPE,
+}
@@ -0,0 +1,23 @@
+//go:build windows
+
+package classify
+
+import (
+	"net"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrno_Winsock(t *testing.T) {
+	// Windows 上的连接错误使用 Winsock 错误码
+	// Connection errors on Windows carry Winsock error numbers
+	refused := &net.OpError{Op: "dial", Err: os.NewSyscallError("connectex", syscall.Errno(10061))}
+	reset := &net.OpError{Op: "read", Err: os.NewSyscallError("wsarecv", syscall.WSAECONNRESET)}
+
+	assert.True(t, Errno()(refused))
+	assert.True(t, Errno()(reset))
+	assert.True(t, Transient(reset))
+}
@@ -2,7 +2,9 @@ package retry
 
 import (
 	"context"
+	"errors"
 	"math"
+	"math/rand"
 	"time"
 )
 
@@ -14,22 +16,33 @@ const (
 	defaultDelay    = defaultDelayNum * time.Millisecond * 100 // 计算默认的延迟时间
 	defaultJitter   = 3.0                                      // 默认的抖动为3.0
 	defaultFactor   = 1.0                                      // 默认的因子为1.0
+	defaultBaseUnit = baseInterval                             // 默认的退避时间单位为100毫秒
 )
 
-// 定义默认的重试条件函数和退避函数
-// Define the default retry condition function and backoff function
+// 定义默认的重试条件函数
+// Define the default retry condition function
 var (
 	// defaultRetryIfFunc 是默认的重试条件函数，对所有错误都进行重试
 	// defaultRetryIfFunc is the default retry condition function, which retries for all errors
 	defaultRetryIfFunc = func(error) bool { return true }
 
-	// defaultBackoffFunc 是默认的退避函数，使用指数退避和随机退避的组合
-	// defaultBackoffFunc is the default backoff function, which combines exponential backoff and random backoff
-	defaultBackoffFunc = func(n int64) time.Duration {
-		return CombineBackoffs(ExponentialBackoff, RandomBackoff)(n)
+	// defaultErrorCodeFunc 是默认的错误码函数，从实现了 ErrorCoder 接口的错误中获取错误码
+	// defaultErrorCodeFunc is the default error code function, which gets the code from errors implementing the ErrorCoder interface
+	defaultErrorCodeFunc = func(err error) string {
+		var coder ErrorCoder
+		if errors.As(err, &coder) {
+			return coder.Code()
+		}
+		return ""
 	}
 )
 
+// newDefaultBackoffFunc 函数返回默认的退避函数，使用指数退避和随机退避的组合，随机部分使用指定的随机数生成器
+// The newDefaultBackoffFunc function returns the default backoff function, which combines exponential backoff and random backoff, the random part uses the specified generator
+func newDefaultBackoffFunc(rng *rand.Rand) BackoffFunc {
+	return CombineBackoffs(ExponentialBackoff, NewRandomBackoff(rng))
+}
+
 // 定义一个空的回调结构体
 // Define an empty callback structure
 type emptyCallback struct{}
@@ -44,23 +57,44 @@ func NewEmptyCallback() Callback {
 	return &emptyCallback{}
 }
 
+// ErrorCodeFunc 类型定义了一个从错误中获取错误码的函数类型，返回空字符串表示没有错误码
+// The ErrorCodeFunc type defines a function type that gets the error code from an error, an empty string means no code
+type ErrorCodeFunc = func(error) string
+
 // RetryIfFunc 类型定义了一个接受错误并返回布尔值的函数类型
 // The RetryIfFunc type defines a function type that accepts an error and returns a boolean value
 type RetryIfFunc = func(error) bool
 
+// RetryIfResultFunc 类型定义了根据返回值和错误判断是否应该重试的函数类型，返回 true 时重试
+// The RetryIfResultFunc type defines the function type that decides whether to retry based on the returned value and error, returning true retries
+type RetryIfResultFunc = func(data any, err error) bool
+
 // Config 结构体定义了重试的配置
 // The Config structure defines the configuration for retries
 type Config struct {
-	ctx             context.Context  // 上下文，用于控制重试的生命周期
-	callback        Callback         // 回调函数，用于在每次重试时执行
-	attempts        uint64           // 重试次数
-	attemptsByError map[error]uint64 // 按错误类型的重试次数
-	factor          float64          // 退避因子，用于控制退避时间的增长速度
-	jitter          float64          // 抖动，用于在退避时间上添加随机性
-	delay           time.Duration    // 延迟时间，用于控制每次重试之间的间隔
-	retryIfFunc     RetryIfFunc      // 重试条件函数，用于判断是否应该重试
-	backoffFunc     BackoffFunc      // 退避函数，用于计算每次重试的延迟时间
-	detail          bool             // 是否显示详细的错误信息
+	ctx               context.Context   // 上下文，用于控制重试的生命周期
+	callback          Callback          // 回调函数，用于在每次重试时执行
+	attempts          uint64            // 重试次数
+	attemptsSet       bool              // 重试次数是否被显式设置，Supervisor 只在显式设置时限制连续失败的次数
+	attemptsByError   map[error]uint64  // 按错误类型的重试次数
+	attemptsByCode    map[string]uint64 // 按错误码的重试次数
+	errorCodeFunc     ErrorCodeFunc     // 错误码函数，用于从错误中获取错误码
+	timeout           time.Duration     // 总的超时时间，0 表示不限制
+	factor            float64           // 退避因子，用于控制退避时间的增长速度
+	jitter            float64           // 抖动，用于在退避时间上添加随机性
+	delay             time.Duration     // 延迟时间，用于控制每次重试之间的间隔
+	minDelay          time.Duration     // 最小延迟时间，0 表示不限制
+	maxDelay          time.Duration     // 最大延迟时间，0 表示不限制
+	baseUnit          time.Duration     // 退避函数的时间单位，用于缩放退避函数的结果
+	retryIfFunc       RetryIfFunc       // 重试条件函数，用于判断是否应该重试
+	retryIfResultFunc RetryIfResultFunc // 结果检查函数，用于根据返回值判断是否应该重试，nil 表示不检查
+	backoffFunc       BackoffFunc       // 退避函数，用于计算每次重试的延迟时间，nil 表示使用默认的退避函数
+	backoffFactory    BackoffFactory    // 退避策略工厂函数，设置后优先于退避函数使用
+	backoffSpec       *BackoffSpec      // 退避策略描述，通过 WithBackoffSpec 设置时用于输出当前的策略
+	detail            bool              // 是否显示详细的错误信息
+	seed              int64             // 随机数种子，仅在 seeded 为 true 时使用
+	seeded            bool              // 是否使用固定的随机数种子
+	immediate         bool              // 第一次执行是否不等待初始延迟时间
 }
 
 // NewConfig 函数返回一个新的 Config 实例，使用默认的配置
@@ -71,11 +105,14 @@ func NewConfig() *Config {
 		callback:        NewEmptyCallback(),
 		attempts:        defaultAttempts,
 		attemptsByError: make(map[error]uint64),
+		attemptsByCode:  make(map[string]uint64),
+		errorCodeFunc:   defaultErrorCodeFunc,
 		factor:          defaultFactor,
 		delay:           defaultDelay,
+		baseUnit:        defaultBaseUnit,
 		jitter:          defaultJitter,
 		retryIfFunc:     defaultRetryIfFunc,
-		backoffFunc:     defaultBackoffFunc,
+		backoffFunc:     nil,
 		detail:          false,
 	}
 }
@@ -98,6 +135,7 @@ func (c *Config) WithCallback(cb Callback) *Config {
 // The WithAttempts method sets the number of retries of the Config and returns the Config instance
 func (c *Config) WithAttempts(attempts uint64) *Config {
 	c.attempts = attempts
+	c.attemptsSet = true
 	return c
 }
 
@@ -108,6 +146,27 @@ func (c *Config) WithAttemptsByError(attemptsByError map[error]uint64) *Config {
 	return c
 }
 
+// WithAttemptsByErrorCode 方法设置 Config 按错误码的重试次数并返回 Config 实例，错误码由错误码函数获取
+// The WithAttemptsByErrorCode method sets the number of retries by error code of the Config and returns the Config instance, the code is obtained by the error code function
+func (c *Config) WithAttemptsByErrorCode(attemptsByCode map[string]uint64) *Config {
+	c.attemptsByCode = attemptsByCode
+	return c
+}
+
+// WithErrorCodeFunc 方法设置 Config 的错误码函数并返回 Config 实例
+// The WithErrorCodeFunc method sets the error code function of the Config and returns the Config instance
+func (c *Config) WithErrorCodeFunc(fn ErrorCodeFunc) *Config {
+	c.errorCodeFunc = fn
+	return c
+}
+
+// WithTimeout 方法设置 Config 总的超时时间并返回 Config 实例，包括所有的执行和等待时间，0 表示不限制
+// The WithTimeout method sets the overall timeout of the Config and returns the Config instance, covering all executions and waits, 0 means no limit
+func (c *Config) WithTimeout(timeout time.Duration) *Config {
+	c.timeout = timeout
+	return c
+}
+
 // WithFactor 方法设置 Config 的因子并返回 Config 实例
 // The WithFactor method sets the factor of the Config and returns the Config instance
 func (c *Config) WithFactor(factor float64) *Config {
@@ -122,6 +181,29 @@ func (c *Config) WithInitDelay(delay time.Duration) *Config {
 	return c
 }
 
+// WithMinDelay 方法设置 Config 的最小延迟时间并返回 Config 实例，每次重试的延迟时间（包括初始延迟时间）不会小于该值
+// The WithMinDelay method sets the minimum delay of the Config and returns the Config instance, the delay of each retry (including the initial delay) is never smaller than this value
+func (c *Config) WithMinDelay(delay time.Duration) *Config {
+	c.minDelay = delay
+	return c
+}
+
+// WithMaxDelay 方法设置 Config 的最大延迟时间并返回 Config 实例，每次重试的延迟时间（包括初始延迟时间）不会大于该值，0 表示不限制
+// The WithMaxDelay method sets the maximum delay of the Config and returns the Config instance, the delay of each retry (including the initial delay) is never larger than this value, 0 means no limit
+func (c *Config) WithMaxDelay(delay time.Duration) *Config {
+	c.maxDelay = delay
+	return c
+}
+
+// WithBaseUnit 方法设置 Config 的退避时间单位并返回 Config 实例
+// 内置的退避函数以 100 毫秒为单位计算延迟时间，设置后退避函数的结果会按该单位缩放
+// The WithBaseUnit method sets the backoff time unit of the Config and returns the Config instance
+// The built-in backoff functions calculate delays in units of 100 milliseconds, the results of the backoff function are scaled to this unit once set
+func (c *Config) WithBaseUnit(unit time.Duration) *Config {
+	c.baseUnit = unit
+	return c
+}
+
 // WithJitter 方法设置 Config 的抖动并返回 Config 实例
 // The WithJitter method sets the jitter of the Config and returns the Config instance
 func (c *Config) WithJitter(jitter float64) *Config {
@@ -136,6 +218,19 @@ func (c *Config) WithRetryIfFunc(retryIf RetryIfFunc) *Config {
 	return c
 }
 
+// WithRetryIfResult 方法设置 Config 的结果检查函数并返回 Config 实例，每次执行后都会调用该函数
+// 执行成功但函数返回 true 时（例如任务的状态仍然是 PENDING）继续重试，重试次数用完时结果中仍然保留最后一次的返回值
+// 执行失败时该函数只能增加重试，RetryIfFunc 允许的重试不受影响
+// Transport、Dialer 等包装不使用该函数，它们自己判断返回值并释放被拒绝的响应和连接
+// The WithRetryIfResult method sets the result check function of the Config and returns the Config instance, the function is called after every execution
+// When the execution succeeds but the function returns true (for example the job status is still PENDING) the loop keeps retrying, and the result still holds the last returned value when the attempts run out
+// For failed executions the function can only add retries, the retries allowed by RetryIfFunc are unaffected
+// Wrappers such as Transport and Dialer do not use the function, they judge their returned values themselves and release rejected responses and connections
+func (c *Config) WithRetryIfResult(retryIf RetryIfResultFunc) *Config {
+	c.retryIfResultFunc = retryIf
+	return c
+}
+
 // WithBackOffFunc 方法设置 Config 的退避函数并返回 Config 实例
 // The WithBackOffFunc method sets the backoff function of the Config and returns the Config instance
 func (c *Config) WithBackOffFunc(backoff BackoffFunc) *Config {
@@ -143,6 +238,46 @@ func (c *Config) WithBackOffFunc(backoff BackoffFunc) *Config {
 	return c
 }
 
+// WithBackoffFactory 方法设置 Config 的退避策略工厂函数并返回 Config 实例，每次调用 TryOnConflict 都会创建一个新的退避策略
+// The WithBackoffFactory method sets the backoff factory of the Config and returns the Config instance, a new backoff is created for each TryOnConflict call
+func (c *Config) WithBackoffFactory(factory BackoffFactory) *Config {
+	c.backoffFactory = factory
+	c.backoffSpec = nil
+	return c
+}
+
+// WithBackoffSpec 方法使用解析后的退避策略描述设置 Config 的退避策略工厂函数并返回 Config 实例
+// The WithBackoffSpec method sets the backoff factory of the Config from a parsed backoff spec and returns the Config instance
+func (c *Config) WithBackoffSpec(spec *BackoffSpec) *Config {
+	if spec == nil {
+		return c.WithBackoffFactory(nil)
+	}
+	c.backoffFactory = spec.Factory()
+	c.backoffSpec = spec
+	return c
+}
+
+// BackoffSpec 方法返回通过 WithBackoffSpec 设置的退避策略描述，没有设置时返回 nil
+// The BackoffSpec method returns the backoff spec set through WithBackoffSpec, or nil if none is set
+func (c *Config) BackoffSpec() *BackoffSpec {
+	return c.backoffSpec
+}
+
+// WithSeed 方法设置 Config 的随机数种子并返回 Config 实例，使用相同种子的每次调用都会产生相同的延迟序列
+// The WithSeed method sets the random seed of the Config and returns the Config instance, every call using the same seed produces the same delay sequence
+func (c *Config) WithSeed(seed int64) *Config {
+	c.seed = seed
+	c.seeded = true
+	return c
+}
+
+// WithImmediate 方法设置第一次执行是否立即开始（不等待初始延迟时间）并返回 Config 实例，之后的重试仍然会加上初始延迟时间
+// The WithImmediate method sets whether the first execution starts immediately (without waiting for the initial delay) and returns the Config instance, later retries still add the initial delay
+func (c *Config) WithImmediate(immediate bool) *Config {
+	c.immediate = immediate
+	return c
+}
+
 // WithDetail 方法设置 Config 的详细错误信息显示选项并返回 Config 实例
 // The WithDetail method sets the detailed error information display option of the Config and returns the Config instance
 func (c *Config) WithDetail(detail bool) *Config {
@@ -150,6 +285,40 @@ func (c *Config) WithDetail(detail bool) *Config {
 	return c
 }
 
+// Clone 方法返回 Config 的一个副本，映射会被深拷贝，上下文、回调函数和函数类型的字段与原配置共享
+// The Clone method returns a copy of the Config, maps are deep-copied while the context, callback and function fields are shared with the original
+func (c *Config) Clone() *Config {
+	clone := *c
+
+	if c.attemptsByError != nil {
+		clone.attemptsByError = make(map[error]uint64, len(c.attemptsByError))
+		for err, attempts := range c.attemptsByError {
+			clone.attemptsByError[err] = attempts
+		}
+	}
+
+	if c.attemptsByCode != nil {
+		clone.attemptsByCode = make(map[string]uint64, len(c.attemptsByCode))
+		for code, attempts := range c.attemptsByCode {
+			clone.attemptsByCode[code] = attempts
+		}
+	}
+
+	return &clone
+}
+
+// clampDelay 方法将延迟时间限制在最小和最大延迟时间之间
+// The clampDelay method clamps the delay between the minimum and maximum delay
+func (c *Config) clampDelay(delay time.Duration) time.Duration {
+	if delay < c.minDelay {
+		delay = c.minDelay
+	}
+	if c.maxDelay > 0 && delay > c.maxDelay {
+		delay = c.maxDelay
+	}
+	return delay
+}
+
 // isConfigValid 函数检查 Config 是否有效，如果无效则使用默认值
 // The isConfigValid function checks whether the Config is valid, and uses the default value if it is invalid
 func isConfigValid(conf *Config) *Config {
@@ -182,6 +351,24 @@ func isConfigValid(conf *Config) *Config {
 			conf.attemptsByError = make(map[error]uint64)
 		}
 
+		// 如果 conf.attemptsByCode 为 nil，则初始化为一个空的映射
+		// If conf.attemptsByCode is nil, initialize it to an empty map
+		if conf.attemptsByCode == nil {
+			conf.attemptsByCode = make(map[string]uint64)
+		}
+
+		// 如果 conf.errorCodeFunc 为 nil，则设置为默认的错误码函数
+		// If conf.errorCodeFunc is nil, set it to the default error code function
+		if conf.errorCodeFunc == nil {
+			conf.errorCodeFunc = defaultErrorCodeFunc
+		}
+
+		// 如果 conf.timeout 小于 0，则设置为不限制
+		// If conf.timeout is less than 0, set it to no limit
+		if conf.timeout < 0 {
+			conf.timeout = 0
+		}
+
 		// 如果 conf.factor 小于 0，则设置为默认的退避因子
 		// If conf.factor is less than 0, set it to the default backoff factor
 		if conf.factor < 0 {
@@ -194,6 +381,24 @@ func isConfigValid(conf *Config) *Config {
 			conf.delay = defaultDelay
 		}
 
+		// 如果 conf.minDelay 小于 0，则设置为不限制
+		// If conf.minDelay is less than 0, set it to no limit
+		if conf.minDelay < 0 {
+			conf.minDelay = 0
+		}
+
+		// 如果 conf.maxDelay 小于 0，则设置为不限制
+		// If conf.maxDelay is less than 0, set it to no limit
+		if conf.maxDelay < 0 {
+			conf.maxDelay = 0
+		}
+
+		// 如果 conf.baseUnit 小于等于 0，则设置为默认的退避时间单位
+		// If conf.baseUnit is less than or equal to 0, set it to the default backoff time unit
+		if conf.baseUnit <= 0 {
+			conf.baseUnit = defaultBaseUnit
+		}
+
 		// 如果 conf.jitter 小于 0，则设置为默认的抖动
 		// If conf.jitter is less than 0, set it to the default jitter
 		if conf.jitter < 0 {
@@ -205,12 +410,6 @@ func isConfigValid(conf *Config) *Config {
 		if conf.retryIfFunc == nil {
 			conf.retryIfFunc = defaultRetryIfFunc
 		}
-
-		// 如果 conf.backoffFunc 为 nil，则设置为默认的退避函数
-		// If conf.backoffFunc is nil, set it to the default backoff function
-		if conf.backoffFunc == nil {
-			conf.backoffFunc = defaultBackoffFunc
-		}
 	}
 
 	// 返回检查并修正后的 Config 实例
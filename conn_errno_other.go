@@ -0,0 +1,13 @@
+//go:build !windows
+
+package retry
+
+import "syscall"
+
+// connErrnos 是表示连接被重置、拒绝或者断开的系统调用错误码
+// connErrnos are the syscall error numbers of reset, refused or broken connections
+var connErrnos = []syscall.Errno{
+	syscall.ECONNRESET,
+	syscall.ECONNREFUSED,
+	syscall.EPIPE,
+}
@@ -0,0 +1,18 @@
+//go:build windows
+
+package retry
+
+import "syscall"
+
+// connErrnos 是表示连接被重置、拒绝或者断开的系统调用错误码，Windows 上的网络错误使用 Winsock 错误码而不是 syscall.ECONNRESET 这样的值
+// connErrnos are the syscall error numbers of reset, refused or broken connections, network errors on Windows carry Winsock error numbers rather than values like syscall.ECONNRESET
+var connErrnos = []syscall.Errno{
+	syscall.ECONNRESET,
+	syscall.ECONNREFUSED,
+	syscall.EPIPE,
+	syscall.WSAECONNRESET,
+	syscall.WSAECONNABORTED,
+	syscall.Errno(10061), // WSAECONNREFUSED
+	syscall.ERROR_NETNAME_DELETED,
+	syscall.ERROR_BROKEN_PIPE,
+}
@@ -0,0 +1,138 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"net"
+)
+
+// DialContextFunc 类型定义了建立网络连接的函数类型，与 net.Dialer.DialContext 的签名相同
+// The DialContextFunc type defines the function type that opens a network connection, with the same signature as net.Dialer.DialContext
+type DialContextFunc = func(ctx context.Context, network, address string) (net.Conn, error)
+
+// Dialer 结构体是一个带重试的拨号器，可以被多个 goroutine 并发使用
+// 拨号阶段的网络错误（例如连接被拒绝）、超时和 DNS 临时错误会被重试，每次重试轮流使用下一个候选地址，调用方上下文的截止时间始终有效
+// The Dialer struct is a dialer with retries and is safe for concurrent use by multiple goroutines
+// Network errors of the dial phase such as refused connections, timeouts and temporary DNS failures are retried, every retry moves on to the next candidate address, and the deadline of the caller context always applies
+type Dialer struct {
+	dial      DialContextFunc
+	retry     *Retry
+	addresses []string
+}
+
+// NewDialer 函数创建一个新的 Dialer 实例，使用零值的 net.Dialer 建立连接，conf 为空时使用默认配置
+// 第一次拨号总是立即进行，之后的重试使用配置中的退避策略，配置会被复制
+// The NewDialer function creates a new Dialer instance that opens connections with a zero net.Dialer, using the default configuration when conf is nil
+// The first dial always happens immediately, later retries use the configured backoff, and the configuration is copied
+func NewDialer(conf *Config) *Dialer {
+	return &Dialer{
+		dial:  (&net.Dialer{}).DialContext,
+		retry: newImmediateRetry(conf, isRetryableDialError),
+	}
+}
+
+// WithDialFunc 方法设置建立连接的函数并返回 Dialer 实例，例如自定义超时时间的 net.Dialer 的 DialContext 方法
+// The WithDialFunc method sets the function that opens connections and returns the Dialer instance, such as the DialContext method of a net.Dialer with a custom timeout
+func (d *Dialer) WithDialFunc(fn DialContextFunc) *Dialer {
+	if fn != nil {
+		d.dial = fn
+	}
+	return d
+}
+
+// WithAddresses 方法设置额外的候选地址并返回 Dialer 实例，重试时在 DialContext 传入的地址之后轮流使用这些地址
+// The WithAddresses method sets additional candidate addresses and returns the Dialer instance, retries take turns on these addresses after the address passed to DialContext
+func (d *Dialer) WithAddresses(addresses ...string) *Dialer {
+	d.addresses = append([]string(nil), addresses...)
+	return d
+}
+
+// candidates 方法返回本次拨号的候选地址，address 总是第一个，重复的地址只保留一个
+// The candidates method returns the candidate addresses of a dial, address always comes first and duplicates are kept once
+func (d *Dialer) candidates(address string) []string {
+	candidates := make([]string, 0, len(d.addresses)+1)
+	candidates = append(candidates, address)
+	for _, addr := range d.addresses {
+		if addr == "" || containsString(candidates, addr) {
+			continue
+		}
+		candidates = append(candidates, addr)
+	}
+	return candidates
+}
+
+// Dial 方法使用 context.Background 建立连接
+// The Dial method opens a connection with context.Background
+func (d *Dialer) Dial(network, address string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, address)
+}
+
+// DialContext 方法建立连接，并在遇到可重试的错误时轮流使用候选地址重试
+// 上下文结束时返回上下文的错误，重试次数用完或错误不可重试时返回最后一次拨号的错误
+// The DialContext method opens a connection and retries on retryable errors, taking turns on the candidate addresses
+// It returns the context error when the context is done, and the error of the last dial when the attempts run out or the error cannot be retried
+func (d *Dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	candidates := d.candidates(address)
+
+	var (
+		attempts int
+		lastErr  error
+	)
+
+	result := d.retry.TryOnConflictContext(ctx, func() (any, error) {
+		addr := candidates[attempts%len(candidates)]
+		attempts++
+
+		conn, err := d.dial(ctx, network, addr)
+		if err != nil {
+			lastErr = err
+			return nil, err
+		}
+		return conn, nil
+	})
+
+	if result.IsSuccess() {
+		return result.Data().(net.Conn), nil
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	return nil, result.TryError()
+}
+
+// isRetryableDialError 函数判断拨号的错误是否可以重试：拨号阶段的网络错误、连接被重置、超时和 DNS 临时错误可以重试，上下文的错误和地址错误不可以重试
+// 拨号阶段的 *net.OpError 不依赖具体平台的错误码，Windows 上的 WSAECONNREFUSED 与 Unix 上的 ECONNREFUSED 一样可以重试
+// The isRetryableDialError function reports whether a dial error can be retried: network errors of the dial phase, reset connections, timeouts and temporary DNS failures can, context errors and address errors cannot
+// A dial-phase *net.OpError does not depend on platform errnos, so WSAECONNREFUSED on Windows is retried just like ECONNREFUSED on Unix
+func isRetryableDialError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTemporary || dnsErr.IsTimeout
+	}
+
+	var addrErr *net.AddrError
+	var networkErr net.UnknownNetworkError
+	if errors.As(err, &addrErr) || errors.As(err, &networkErr) {
+		return false
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+
+	if isConnErrno(err) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
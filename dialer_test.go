@@ -0,0 +1,143 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestDialerConfig() *Config {
+	return NewConfig().
+		WithInitDelay(time.Millisecond).
+		WithBackOffFunc(func(int64) time.Duration { return 0 })
+}
+
+// refusedAddress 函数返回一个拒绝连接的地址
+// The refusedAddress function returns an address that refuses connections
+func refusedAddress(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+	return addr
+}
+
+func TestDialer_RotatesAddresses(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer ln.Close()
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	var dialed []string
+	base := &net.Dialer{}
+	d := NewDialer(newTestDialerConfig()).
+		WithAddresses(ln.Addr().String()).
+		WithDialFunc(func(ctx context.Context, network, address string) (net.Conn, error) {
+			dialed = append(dialed, address)
+			return base.DialContext(ctx, network, address)
+		})
+
+	refused := refusedAddress(t)
+	conn, err := d.DialContext(context.Background(), "tcp", refused)
+	assert.NoError(t, err)
+	assert.NotNil(t, conn)
+	conn.Close()
+
+	assert.Equal(t, []string{refused, ln.Addr().String()}, dialed)
+}
+
+func TestDialer_Candidates(t *testing.T) {
+	d := NewDialer(nil).WithAddresses("b:1", "", "a:1", "b:1")
+	assert.Equal(t, []string{"a:1", "b:1"}, d.candidates("a:1"))
+	assert.Equal(t, []string{"c:1", "b:1", "a:1"}, d.candidates("c:1"))
+}
+
+func TestDialer_AttemptsExceeded(t *testing.T) {
+	calls := 0
+	base := &net.Dialer{}
+	d := NewDialer(newTestDialerConfig().WithAttempts(3)).WithDialFunc(func(ctx context.Context, network, address string) (net.Conn, error) {
+		calls++
+		return base.DialContext(ctx, network, address)
+	})
+
+	// 不检查平台相关的错误码，只检查拨号被重试的次数
+	// The platform specific errno is not checked, only how many times the dial was retried
+	_, err := d.Dial("tcp", refusedAddress(t))
+	var opErr *net.OpError
+	assert.ErrorAs(t, err, &opErr)
+	assert.Equal(t, 3, calls)
+}
+
+func TestDialer_Classification(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		retryable bool
+	}{
+		{name: "refused", err: &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, retryable: true},
+		{name: "refused windows", err: &net.OpError{Op: "dial", Err: os.NewSyscallError("connectex", syscall.Errno(10061))}, retryable: true},
+		{name: "reset", err: fmt.Errorf("wrapped: %w", syscall.ECONNRESET), retryable: true},
+		{name: "timeout", err: &net.OpError{Op: "dial", Err: os.ErrDeadlineExceeded}, retryable: true},
+		{name: "dns temporary", err: &net.DNSError{Err: "server misbehaving", IsTemporary: true}, retryable: true},
+		{name: "dns timeout", err: &net.DNSError{Err: "timeout", IsTimeout: true}, retryable: true},
+		{name: "dns not found", err: &net.DNSError{Err: "no such host", IsNotFound: true}, retryable: false},
+		{name: "address", err: &net.OpError{Op: "dial", Err: &net.AddrError{Err: "missing port in address", Addr: "backend"}}, retryable: false},
+		{name: "unknown network", err: &net.OpError{Op: "dial", Err: net.UnknownNetworkError("tcp5")}, retryable: false},
+		{name: "not dial", err: &net.OpError{Op: "read", Err: errors.New("use of closed network connection")}, retryable: false},
+		{name: "canceled", err: &net.OpError{Op: "dial", Err: context.Canceled}, retryable: false},
+		{name: "other", err: errors.New("unknown network"), retryable: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			d := NewDialer(newTestDialerConfig().WithAttempts(3)).WithDialFunc(func(context.Context, string, string) (net.Conn, error) {
+				calls++
+				return nil, tt.err
+			})
+
+			_, err := d.DialContext(context.Background(), "tcp", "backend:5432")
+			assert.Equal(t, tt.err, err)
+			if tt.retryable {
+				assert.Equal(t, 3, calls)
+			} else {
+				assert.Equal(t, 1, calls)
+			}
+		})
+	}
+}
+
+func TestDialer_ContextDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	calls := 0
+	d := NewDialer(NewConfig().WithAttempts(1000).WithInitDelay(10 * time.Millisecond).WithBackOffFunc(FixedBackoff).WithJitter(0)).
+		WithDialFunc(func(context.Context, string, string) (net.Conn, error) {
+			calls++
+			return nil, syscall.ECONNREFUSED
+		})
+
+	start := time.Now()
+	_, err := d.DialContext(ctx, "tcp", "backend:5432")
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Greater(t, calls, 0)
+	assert.Less(t, calls, 1000)
+}
@@ -0,0 +1,263 @@
+package retry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// builtinBackoffFuncs 是可以按名称序列化的内置退避函数
+// builtinBackoffFuncs are the built-in backoff functions that can be serialized by name
+var builtinBackoffFuncs = map[string]BackoffFunc{
+	"fixed":       FixedBackoff,
+	"exponential": ExponentialBackoff,
+	"random":      RandomBackoff,
+}
+
+// backoffFuncName 函数返回内置退避函数的名称，不是内置函数时返回 false
+// The backoffFuncName function returns the name of a built-in backoff function, or false if it is not built in
+func backoffFuncName(fn BackoffFunc) (string, bool) {
+	ptr := reflect.ValueOf(fn).Pointer()
+	for name, builtin := range builtinBackoffFuncs {
+		if reflect.ValueOf(builtin).Pointer() == ptr {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// builtinBackoffNames 函数返回所有内置退避函数的名称，按字母顺序排列
+// The builtinBackoffNames function returns the names of every built-in backoff function in alphabetical order
+func builtinBackoffNames() []string {
+	names := make([]string, 0, len(builtinBackoffFuncs))
+	for name := range builtinBackoffFuncs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// configFields 结构体是 Config 可序列化部分的表示，nil 表示该字段未设置
+// The configFields struct represents the serializable part of a Config, nil means the field is not set
+type configFields struct {
+	Attempts            *uint64           `json:"attempts,omitempty"`
+	AttemptsByErrorCode map[string]uint64 `json:"attempts_by_error_code,omitempty"`
+	Delay               *string           `json:"delay,omitempty"`
+	MinDelay            *string           `json:"min_delay,omitempty"`
+	MaxDelay            *string           `json:"max_delay,omitempty"`
+	BaseUnit            *string           `json:"base_unit,omitempty"`
+	Timeout             *string           `json:"timeout,omitempty"`
+	Factor              *float64          `json:"factor,omitempty"`
+	Jitter              *float64          `json:"jitter,omitempty"`
+	Backoff             *string           `json:"backoff,omitempty"`
+	BackoffFunc         *string           `json:"backoff_func,omitempty"`
+	Detail              *bool             `json:"detail,omitempty"`
+	Immediate           *bool             `json:"immediate,omitempty"`
+	Seed                *int64            `json:"seed,omitempty"`
+}
+
+// MarshalJSON 方法将 Config 序列化为 JSON，上下文、回调函数和函数类型的字段无法序列化，会被忽略
+// 通过 WithBackoffSpec 设置的退避策略序列化为 backoff，内置的退避函数（FixedBackoff、ExponentialBackoff、RandomBackoff）序列化为 backoff_func
+// 其他的退避函数和退避策略工厂函数无法序列化，此时返回包装了 ErrorBackoffNotSerializable 的错误，而不是静默地丢弃它们
+// The MarshalJSON method serializes the Config to JSON, the context, callback and function fields cannot be serialized and are ignored
+// A backoff set through WithBackoffSpec is serialized as backoff, and the built-in backoff functions (FixedBackoff, ExponentialBackoff, RandomBackoff) as backoff_func
+// Other backoff functions and backoff factories cannot be serialized, in which case an error wrapping ErrorBackoffNotSerializable is returned instead of silently dropping them
+func (c *Config) MarshalJSON() ([]byte, error) {
+	durationString := func(d time.Duration) *string {
+		s := d.String()
+		return &s
+	}
+
+	fields := configFields{
+		Attempts: &c.attempts,
+		Delay:    durationString(c.delay),
+		BaseUnit: durationString(c.baseUnit),
+		Factor:   &c.factor,
+		Jitter:   &c.jitter,
+		Detail:   &c.detail,
+	}
+	if len(c.attemptsByCode) > 0 {
+		fields.AttemptsByErrorCode = c.attemptsByCode
+	}
+	if c.minDelay > 0 {
+		fields.MinDelay = durationString(c.minDelay)
+	}
+	if c.maxDelay > 0 {
+		fields.MaxDelay = durationString(c.maxDelay)
+	}
+	if c.timeout > 0 {
+		fields.Timeout = durationString(c.timeout)
+	}
+	switch {
+	case c.backoffSpec != nil:
+		spec := c.backoffSpec.String()
+		fields.Backoff = &spec
+	case c.backoffFactory != nil:
+		return nil, fmt.Errorf("%w: backoff factory", ErrorBackoffNotSerializable)
+	case c.backoffFunc != nil:
+		name, ok := backoffFuncName(c.backoffFunc)
+		if !ok {
+			return nil, fmt.Errorf("%w: backoff function", ErrorBackoffNotSerializable)
+		}
+		fields.BackoffFunc = &name
+	}
+	if c.seeded {
+		fields.Seed = &c.seed
+	}
+	if c.immediate {
+		fields.Immediate = &c.immediate
+	}
+
+	return json.Marshal(fields)
+}
+
+// UnmarshalJSON 方法从 JSON 中读取配置，只有出现的字段会覆盖当前的值
+// 未知的字段和无效的值都会作为 ConfigError 返回，此时 Config 不会被修改
+// The UnmarshalJSON method reads the configuration from JSON, only the fields present override the current values
+// Unknown fields and invalid values are returned as a ConfigError, in which case the Config is left unchanged
+func (c *Config) UnmarshalJSON(data []byte) error {
+	var fields configFields
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&fields); err != nil {
+		return &ConfigError{Problems: []string{err.Error()}}
+	}
+
+	return c.applyFields(&fields, func(field string) string { return field }, nil)
+}
+
+// applyFields 方法检查并应用配置字段，name 函数用于在错误信息中显示字段的名称，problems 是调用方已经发现的问题
+// 没有任何问题时才会修改 Config，否则返回列出所有问题的 ConfigError
+// The applyFields method checks and applies the configuration fields, the name function is used to show field names in error messages, problems are those already found by the caller
+// The Config is only modified when there is no problem at all, otherwise a ConfigError listing every problem is returned
+func (c *Config) applyFields(fields *configFields, name func(string) string, problems []string) error {
+	addProblem := func(field, problem string) {
+		if problem != "" {
+			problems = append(problems, name(field)+": "+problem)
+		}
+	}
+
+	// 解析时间字段，minimum 为允许的最小值
+	// Parse a duration field, minimum is the smallest allowed value
+	parseDuration := func(field string, value *string, minimum time.Duration) *time.Duration {
+		if value == nil {
+			return nil
+		}
+		d, err := time.ParseDuration(*value)
+		if err != nil {
+			addProblem(field, fmt.Sprintf("invalid duration %q", *value))
+			return nil
+		}
+		if problem := checkDuration(d, minimum); problem != "" {
+			addProblem(field, problem)
+			return nil
+		}
+		return &d
+	}
+
+	if fields.Attempts != nil {
+		addProblem("attempts", checkAttempts(*fields.Attempts))
+	}
+
+	delay := parseDuration("delay", fields.Delay, 1)
+	minDelay := parseDuration("min_delay", fields.MinDelay, 0)
+	maxDelay := parseDuration("max_delay", fields.MaxDelay, 0)
+	baseUnit := parseDuration("base_unit", fields.BaseUnit, 1)
+	timeout := parseDuration("timeout", fields.Timeout, 0)
+
+	// 合并后的最小延迟时间不能大于最大延迟时间，没有出现的一方使用当前的值
+	// The merged minimum delay must not exceed the maximum delay, the side that is absent uses the current value
+	if minDelay != nil || maxDelay != nil {
+		mergedMin, mergedMax := c.minDelay, c.maxDelay
+		if minDelay != nil {
+			mergedMin = *minDelay
+		}
+		if maxDelay != nil {
+			mergedMax = *maxDelay
+		}
+		addProblem("min_delay", checkDelayRange(mergedMin, mergedMax))
+	}
+
+	if fields.Factor != nil {
+		addProblem("factor", checkNonNegative(*fields.Factor))
+	}
+	if fields.Jitter != nil {
+		addProblem("jitter", checkNonNegative(*fields.Jitter))
+	}
+
+	var spec *BackoffSpec
+	if fields.Backoff != nil {
+		var err error
+		if spec, err = ParseBackoff(*fields.Backoff); err != nil {
+			addProblem("backoff", err.Error())
+		}
+	}
+
+	var backoffFunc BackoffFunc
+	if fields.BackoffFunc != nil {
+		switch fn, ok := builtinBackoffFuncs[*fields.BackoffFunc]; {
+		case fields.Backoff != nil:
+			addProblem("backoff_func", "cannot be combined with backoff")
+		case !ok:
+			addProblem("backoff_func", fmt.Sprintf("unknown function %q, expected one of %s", *fields.BackoffFunc, strings.Join(builtinBackoffNames(), ", ")))
+		default:
+			backoffFunc = fn
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ConfigError{Problems: problems}
+	}
+
+	// 所有字段都有效，应用到 Config 中
+	// All fields are valid, apply them to the Config
+	if fields.Attempts != nil {
+		c.WithAttempts(*fields.Attempts)
+	}
+	if fields.AttemptsByErrorCode != nil {
+		c.attemptsByCode = fields.AttemptsByErrorCode
+	}
+	if delay != nil {
+		c.delay = *delay
+	}
+	if minDelay != nil {
+		c.minDelay = *minDelay
+	}
+	if maxDelay != nil {
+		c.maxDelay = *maxDelay
+	}
+	if baseUnit != nil {
+		c.baseUnit = *baseUnit
+	}
+	if timeout != nil {
+		c.timeout = *timeout
+	}
+	if fields.Factor != nil {
+		c.factor = *fields.Factor
+	}
+	if fields.Jitter != nil {
+		c.jitter = *fields.Jitter
+	}
+	if spec != nil {
+		c.WithBackoffSpec(spec)
+	}
+	if backoffFunc != nil {
+		c.WithBackoffFactory(nil).WithBackOffFunc(backoffFunc)
+	}
+	if fields.Detail != nil {
+		c.detail = *fields.Detail
+	}
+	if fields.Seed != nil {
+		c.WithSeed(*fields.Seed)
+	}
+	if fields.Immediate != nil {
+		c.immediate = *fields.Immediate
+	}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package retry
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_MarshalJSON(t *testing.T) {
+	cfg := NewConfig().
+		WithAttempts(5).
+		WithInitDelay(200 * time.Millisecond).
+		WithMaxDelay(30 * time.Second).
+		WithTimeout(time.Minute).
+		WithAttemptsByErrorCode(map[string]uint64{"ECONNRESET": 2}).
+		WithBackoffSpec(MustParseBackoff("exponential(base=100ms,max=10s,jitter=full)")).
+		WithSeed(7).
+		WithImmediate(true)
+
+	data, err := json.Marshal(cfg)
+	assert.NoError(t, err)
+
+	decoded := NewConfig()
+	assert.NoError(t, json.Unmarshal(data, decoded))
+
+	assert.Equal(t, uint64(5), decoded.attempts)
+	assert.Equal(t, 200*time.Millisecond, decoded.delay)
+	assert.Equal(t, 30*time.Second, decoded.maxDelay)
+	assert.Equal(t, time.Minute, decoded.timeout)
+	assert.Equal(t, map[string]uint64{"ECONNRESET": 2}, decoded.attemptsByCode)
+	assert.Equal(t, "exponential(base=100ms,max=10s,jitter=full)", decoded.BackoffSpec().String())
+	assert.True(t, decoded.seeded)
+	assert.Equal(t, int64(7), decoded.seed)
+	assert.True(t, decoded.immediate)
+
+	again, err := json.Marshal(decoded)
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(data), string(again))
+}
+
+func TestConfig_MarshalJSONBackoffFunc(t *testing.T) {
+	data, err := json.Marshal(FixConfig())
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"backoff_func":"fixed"`)
+
+	// 内置的退避函数在往返之后得到相同的延迟时间
+	// A built-in backoff function gives the same delays after a round trip
+	decoded := NewConfig()
+	assert.NoError(t, json.Unmarshal(data, decoded))
+	name, ok := backoffFuncName(decoded.backoffFunc)
+	assert.True(t, ok)
+	assert.Equal(t, "fixed", name)
+	assert.Equal(t, 0.0, decoded.factor)
+	assert.Equal(t, 0.0, decoded.jitter)
+
+	// 其他的退避函数和工厂函数返回错误，而不是被丢弃
+	// Other backoff functions and factories return an error instead of being dropped
+	_, err = json.Marshal(NewConfig().WithBackOffFunc(func(int64) time.Duration { return 0 }))
+	assert.ErrorIs(t, err, ErrorBackoffNotSerializable)
+	_, err = json.Marshal(NewConfig().WithBackoffFactory(NewFullJitterFactory(time.Millisecond, time.Second)))
+	assert.ErrorIs(t, err, ErrorBackoffNotSerializable)
+
+	var cerr *ConfigError
+	err = json.Unmarshal([]byte(`{"backoff_func": "linear"}`), NewConfig())
+	assert.True(t, errors.As(err, &cerr))
+	assert.Contains(t, err.Error(), "exponential, fixed, random")
+	err = json.Unmarshal([]byte(`{"backoff_func": "fixed", "backoff": "fixed(1s)"}`), NewConfig())
+	assert.True(t, errors.As(err, &cerr))
+	assert.Contains(t, err.Error(), "backoff_func")
+}
+
+func TestConfig_UnmarshalJSONMergedDelayRange(t *testing.T) {
+	// 只出现一方时与当前的值比较
+	// When only one side is present it is compared with the current value
+	var cerr *ConfigError
+	cfg := NewConfig().WithMaxDelay(time.Second)
+	err := json.Unmarshal([]byte(`{"min_delay": "2s"}`), cfg)
+	assert.True(t, errors.As(err, &cerr))
+	assert.Contains(t, err.Error(), "min_delay")
+	assert.Equal(t, time.Duration(0), cfg.minDelay)
+
+	cfg = NewConfig().WithMinDelay(2 * time.Second)
+	err = json.Unmarshal([]byte(`{"max_delay": "1s"}`), cfg)
+	assert.True(t, errors.As(err, &cerr))
+	assert.Equal(t, time.Duration(0), cfg.maxDelay)
+
+	assert.NoError(t, json.Unmarshal([]byte(`{"max_delay": "3s"}`), cfg))
+	assert.Equal(t, 3*time.Second, cfg.maxDelay)
+}
+
+func TestConfig_UnmarshalJSONPartial(t *testing.T) {
+	cfg := NewConfig()
+	assert.NoError(t, json.Unmarshal([]byte(`{"attempts": 7, "jitter": 0}`), cfg))
+
+	assert.Equal(t, uint64(7), cfg.attempts)
+	assert.Equal(t, 0.0, cfg.jitter)
+	assert.Equal(t, defaultDelay, cfg.delay)
+	assert.Equal(t, defaultFactor, cfg.factor)
+}
+
+func TestConfig_UnmarshalJSONErrors(t *testing.T) {
+	cfg := NewConfig()
+	err := json.Unmarshal([]byte(`{
+		"attempts": 100000,
+		"delay": "soon",
+		"factor": -1,
+		"min_delay": "2s",
+		"max_delay": "1s",
+		"backoff": "linear(1s)"
+	}`), cfg)
+
+	var cerr *ConfigError
+	assert.True(t, errors.As(err, &cerr))
+	assert.Len(t, cerr.Problems, 5)
+	assert.Contains(t, cerr.Problems[0], "attempts")
+	assert.Contains(t, cerr.Problems[1], "delay")
+	assert.Contains(t, cerr.Problems[2], "min_delay")
+	assert.Contains(t, cerr.Problems[3], "factor")
+	assert.Contains(t, cerr.Problems[4], "backoff")
+
+	// 出现错误时配置不会被修改
+	// The configuration is left unchanged on error
+	assert.Equal(t, uint64(defaultAttempts), cfg.attempts)
+	assert.Equal(t, defaultFactor, cfg.factor)
+
+	err = json.Unmarshal([]byte(`{"retries": 3}`), cfg)
+	assert.True(t, errors.As(err, &cerr))
+	assert.Contains(t, err.Error(), "retries")
+}
@@ -0,0 +1,133 @@
+package retry
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// LoadConfigFromEnv 函数使用默认配置，并从带有指定前缀的环境变量中读取配置
+// The LoadConfigFromEnv function starts from the default configuration and reads settings from environment variables with the specified prefix
+func LoadConfigFromEnv(prefix string) (*Config, error) {
+	conf := NewConfig()
+	if err := conf.LoadEnv(prefix); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+// LoadEnv 方法从带有指定前缀的环境变量中读取配置，例如前缀为 PAYMENTS_RETRY 时读取 PAYMENTS_RETRY_ATTEMPTS
+// 支持的变量有 ATTEMPTS、ATTEMPTS_BY_ERROR_CODE（格式为 CODE=N,CODE=N）、DELAY、MIN_DELAY、MAX_DELAY、BASE_UNIT、
+// TIMEOUT、FACTOR、JITTER、BACKOFF、BACKOFF_FUNC（fixed、exponential 或 random）、DETAIL、IMMEDIATE 和 SEED，无效的值会作为 ConfigError 返回，此时 Config 不会被修改
+// The LoadEnv method reads the configuration from environment variables with the specified prefix, e.g. PAYMENTS_RETRY_ATTEMPTS for the prefix PAYMENTS_RETRY
+// The supported variables are ATTEMPTS, ATTEMPTS_BY_ERROR_CODE (formatted as CODE=N,CODE=N), DELAY, MIN_DELAY, MAX_DELAY, BASE_UNIT,
+// TIMEOUT, FACTOR, JITTER, BACKOFF, BACKOFF_FUNC (fixed, exponential or random), DETAIL, IMMEDIATE and SEED, invalid values are returned as a ConfigError, in which case the Config is left unchanged
+func (c *Config) LoadEnv(prefix string) error {
+	name := func(field string) string {
+		key := strings.ToUpper(field)
+		if prefix == "" {
+			return key
+		}
+		return strings.TrimSuffix(prefix, "_") + "_" + key
+	}
+
+	var fields configFields
+	var problems []string
+	addProblem := func(field, format string, args ...any) {
+		problems = append(problems, name(field)+": "+fmt.Sprintf(format, args...))
+	}
+
+	lookup := func(field string) (string, bool) {
+		v, ok := os.LookupEnv(name(field))
+		return strings.TrimSpace(v), ok
+	}
+
+	if v, ok := lookup("attempts"); ok {
+		if n, err := strconv.ParseUint(v, 10, 64); err != nil {
+			addProblem("attempts", "invalid number %q", v)
+		} else {
+			fields.Attempts = &n
+		}
+	}
+
+	if v, ok := lookup("attempts_by_error_code"); ok {
+		fields.AttemptsByErrorCode = make(map[string]uint64)
+		for _, pair := range strings.Split(v, ",") {
+			if pair = strings.TrimSpace(pair); pair == "" {
+				continue
+			}
+			code, count, found := strings.Cut(pair, "=")
+			n, err := strconv.ParseUint(strings.TrimSpace(count), 10, 64)
+			if !found || strings.TrimSpace(code) == "" || err != nil {
+				addProblem("attempts_by_error_code", "invalid entry %q, expected CODE=N", pair)
+				continue
+			}
+			fields.AttemptsByErrorCode[strings.TrimSpace(code)] = n
+		}
+	}
+
+	// 时间和策略描述字段保持字符串形式，由 applyFields 统一检查
+	// Duration and spec fields stay as strings and are checked by applyFields
+	for _, item := range []struct {
+		field  string
+		target **string
+	}{
+		{"delay", &fields.Delay},
+		{"min_delay", &fields.MinDelay},
+		{"max_delay", &fields.MaxDelay},
+		{"base_unit", &fields.BaseUnit},
+		{"timeout", &fields.Timeout},
+		{"backoff", &fields.Backoff},
+		{"backoff_func", &fields.BackoffFunc},
+	} {
+		if v, ok := lookup(item.field); ok {
+			value := v
+			*item.target = &value
+		}
+	}
+
+	for _, item := range []struct {
+		field  string
+		target **float64
+	}{
+		{"factor", &fields.Factor},
+		{"jitter", &fields.Jitter},
+	} {
+		if v, ok := lookup(item.field); ok {
+			if f, err := strconv.ParseFloat(v, 64); err != nil {
+				addProblem(item.field, "invalid number %q", v)
+			} else {
+				*item.target = &f
+			}
+		}
+	}
+
+	for _, item := range []struct {
+		field  string
+		target **bool
+	}{
+		{"detail", &fields.Detail},
+		{"immediate", &fields.Immediate},
+	} {
+		if v, ok := lookup(item.field); ok {
+			if b, err := strconv.ParseBool(v); err != nil {
+				addProblem(item.field, "invalid boolean %q", v)
+			} else {
+				*item.target = &b
+			}
+		}
+	}
+
+	if v, ok := lookup("seed"); ok {
+		if n, err := strconv.ParseInt(v, 10, 64); err != nil {
+			addProblem("seed", "invalid number %q", v)
+		} else {
+			fields.Seed = &n
+		}
+	}
+
+	// 解析阶段的问题和检查阶段的问题一起返回
+	// Problems from parsing are returned together with problems from checking
+	return c.applyFields(&fields, name, problems)
+}
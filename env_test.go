@@ -0,0 +1,58 @@
+package retry
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadConfigFromEnv(t *testing.T) {
+	t.Setenv("PAYMENTS_RETRY_ATTEMPTS", "6")
+	t.Setenv("PAYMENTS_RETRY_DELAY", "250ms")
+	t.Setenv("PAYMENTS_RETRY_MAX_DELAY", "5s")
+	t.Setenv("PAYMENTS_RETRY_FACTOR", "1.5")
+	t.Setenv("PAYMENTS_RETRY_TIMEOUT", "1m")
+	t.Setenv("PAYMENTS_RETRY_BACKOFF", "fixed(1s)x2")
+	t.Setenv("PAYMENTS_RETRY_ATTEMPTS_BY_ERROR_CODE", "ECONNRESET=2, 503=1")
+	t.Setenv("PAYMENTS_RETRY_DETAIL", "true")
+	t.Setenv("PAYMENTS_RETRY_IMMEDIATE", "1")
+
+	cfg, err := LoadConfigFromEnv("PAYMENTS_RETRY")
+	assert.NoError(t, err)
+
+	assert.Equal(t, uint64(6), cfg.attempts)
+	assert.Equal(t, 250*time.Millisecond, cfg.delay)
+	assert.Equal(t, 5*time.Second, cfg.maxDelay)
+	assert.Equal(t, 1.5, cfg.factor)
+	assert.Equal(t, time.Minute, cfg.timeout)
+	assert.Equal(t, "fixed(delay=1s)x2", cfg.BackoffSpec().String())
+	assert.Equal(t, map[string]uint64{"ECONNRESET": 2, "503": 1}, cfg.attemptsByCode)
+	assert.True(t, cfg.detail)
+	assert.True(t, cfg.immediate)
+
+	// 前缀末尾的下划线是可选的
+	// The trailing underscore of the prefix is optional
+	cfg, err = LoadConfigFromEnv("PAYMENTS_RETRY_")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(6), cfg.attempts)
+}
+
+func TestLoadConfigFromEnvErrors(t *testing.T) {
+	t.Setenv("JOBS_ATTEMPTS", "many")
+	t.Setenv("JOBS_ATTEMPTS_BY_ERROR_CODE", "ECONNRESET")
+	t.Setenv("JOBS_JITTER", "-1")
+	t.Setenv("JOBS_DETAIL", "maybe")
+
+	cfg, err := LoadConfigFromEnv("JOBS")
+	assert.Nil(t, cfg)
+
+	var cerr *ConfigError
+	assert.True(t, errors.As(err, &cerr))
+	assert.Len(t, cerr.Problems, 4)
+	assert.Contains(t, err.Error(), "JOBS_ATTEMPTS:")
+	assert.Contains(t, err.Error(), "JOBS_ATTEMPTS_BY_ERROR_CODE:")
+	assert.Contains(t, err.Error(), "JOBS_JITTER:")
+	assert.Contains(t, err.Error(), "JOBS_DETAIL:")
+}
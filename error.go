@@ -1,6 +1,10 @@
 package retry
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
 
 var (
 	// ErrorRetryIf 表示重试检查函数的结果为FALSE的错误
@@ -15,6 +19,66 @@ var (
 	// ErrorRetryAttemptsByErrorExceeded represents an error when the retry attempts exceeded the limit due to a specific error
 	ErrorRetryAttemptsByErrorExceeded = errors.New("retry attempts by spec error exceeded")
 
+	// ErrorRetryBackoffStopped 表示退避策略要求停止重试的错误
+	// ErrorRetryBackoffStopped represents an error when the backoff strategy asks to stop retrying
+	ErrorRetryBackoffStopped = errors.New("retry stopped by backoff")
+
+	// ErrorRetryResultRejected 表示执行成功但返回值被结果检查函数拒绝，需要重试
+	// ErrorRetryResultRejected represents an error when the execution succeeded but the returned value was rejected by the result check function and needs a retry
+	ErrorRetryResultRejected = errors.New("retry result rejected")
+
+	// ErrorPolicyNotFound 表示注册表中没有指定名称的策略
+	// ErrorPolicyNotFound represents an error when the registry has no policy with the specified name
+	ErrorPolicyNotFound = errors.New("retry policy not found")
+
+	// ErrorPolicyNameEmpty 表示策略的名称为空
+	// ErrorPolicyNameEmpty represents an error when the policy name is empty
+	ErrorPolicyNameEmpty = errors.New("retry policy name is empty")
+
+	// ErrorConflict 表示比较并交换时版本已经被修改，UpdateOnConflict 的 cas 函数返回包装了它的错误时会重试
+	// ErrorConflict represents a version that was modified before the compare-and-swap, UpdateOnConflict retries when its cas function returns an error wrapping it
+	ErrorConflict = errors.New("retry optimistic update conflict")
+
+	// ErrorConflictAttemptsExceeded 表示冲突的重试次数超过限制，UpdateOnConflict 放弃更新
+	// ErrorConflictAttemptsExceeded represents an error when the retries on conflicts exceeded the limit and UpdateOnConflict gave up
+	ErrorConflictAttemptsExceeded = errors.New("retry conflict attempts exceeded")
+
+	// ErrorChecksumMismatch 表示拼接后的数据流的校验和与期望的不一致
+	// ErrorChecksumMismatch represents an error when the checksum of the stitched stream does not match the expected one
+	ErrorChecksumMismatch = errors.New("retry checksum mismatch")
+
+	// ErrorCircuitOpen 表示主机的熔断器处于打开状态，请求没有被发送
+	// ErrorCircuitOpen represents an error when the circuit breaker of the host is open and the request was not sent
+	ErrorCircuitOpen = errors.New("retry circuit breaker is open")
+
+	// ErrorRetryBudgetExhausted 表示主机的重试预算已经用完，不再重试
+	// ErrorRetryBudgetExhausted represents an error when the retry budget of the host is exhausted and no more retries are made
+	ErrorRetryBudgetExhausted = errors.New("retry budget exhausted")
+
+	// ErrorTaskPanicked 表示被监督的任务发生了 panic，panic 的值包含在错误信息中
+	// ErrorTaskPanicked represents an error when a supervised task panicked, the panic value is included in the message
+	ErrorTaskPanicked = errors.New("retry task panicked")
+
+	// ErrorRestartIntensityExceeded 表示监督树在时间窗口内重新启动子任务的次数超过限制，监督树停止并把错误交给上一级
+	// ErrorRestartIntensityExceeded represents an error when a supervision tree restarted children more often than allowed within the period, the tree stops and escalates to its parent
+	ErrorRestartIntensityExceeded = errors.New("retry restart intensity exceeded")
+
+	// ErrorJobNotFound 表示队列中没有指定的任务
+	// ErrorJobNotFound represents an error when the job is not in the queue
+	ErrorJobNotFound = errors.New("retry job not found")
+
+	// ErrorQueueClosed 表示队列已经关闭
+	// ErrorQueueClosed represents an error when the queue is closed
+	ErrorQueueClosed = errors.New("retry queue is closed")
+
+	// ErrorBackoffNotSerializable 表示配置的退避函数或退避策略工厂函数无法序列化，只有退避策略描述和内置的退避函数可以序列化
+	// ErrorBackoffNotSerializable represents an error when the configured backoff function or factory cannot be serialized, only backoff specs and the built-in backoff functions can
+	ErrorBackoffNotSerializable = errors.New("retry backoff cannot be serialized")
+
+	// ErrorQueueLocked 表示队列目录已经被另一个打开的队列锁定
+	// ErrorQueueLocked represents an error when the queue directory is locked by another open queue
+	ErrorQueueLocked = errors.New("retry queue is locked")
+
 	// ErrorExecErrByIndexOutOfBound 表示由于索引越界导致的执行错误
 	// ErrorExecErrByIndexOutOfBound represents an execution error caused by index out of bound
 	ErrorExecErrByIndexOutOfBound = errors.New("exec error by index out of bound")
@@ -23,3 +87,60 @@ var (
 	// ErrorExecErrNotFound represents an error when the execution error is not found
 	ErrorExecErrNotFound = errors.New("exec error not found")
 )
+
+// ConfigError 表示配置中存在无效的值，Problems 列出了所有的问题
+// ConfigError represents invalid values in a configuration, Problems lists every problem
+type ConfigError struct {
+	Problems []string // 所有的问题 All problems
+}
+
+// Error 方法返回错误的描述
+// The Error method returns the description of the error
+func (e *ConfigError) Error() string {
+	return "invalid retry config: " + strings.Join(e.Problems, "; ")
+}
+
+// causeError 同时包装一个哨兵错误和导致它的错误，errors.Is 对两者都成立，errors.As 可以取得导致它的错误
+// 它代替 Go 1.20 之前不支持的多个 %w
+// causeError wraps a sentinel error together with the error that caused it, errors.Is matches both and errors.As reaches the cause
+// It stands in for multiple %w verbs, which are not supported before Go 1.20
+type causeError struct {
+	sentinel error
+	cause    error
+	msg      string
+}
+
+// newCauseError 函数创建一个 causeError，format 和 args 生成错误信息
+// The newCauseError function creates a causeError whose message is built from format and args
+func newCauseError(sentinel, cause error, format string, args ...any) error {
+	return &causeError{sentinel: sentinel, cause: cause, msg: fmt.Sprintf(format, args...)}
+}
+
+// Error 方法返回错误的描述
+// The Error method returns the description of the error
+func (e *causeError) Error() string {
+	return e.msg
+}
+
+// Is 方法判断 target 是否是包装的哨兵错误
+// The Is method reports whether target is the wrapped sentinel error
+func (e *causeError) Is(target error) bool {
+	return errors.Is(e.sentinel, target)
+}
+
+// Unwrap 方法返回导致错误的原因
+// The Unwrap method returns the error that caused it
+func (e *causeError) Unwrap() error {
+	return e.cause
+}
+
+// isConnErrno 函数判断错误链中是否包含 connErrnos 中的错误码，即连接被重置、拒绝或者断开
+// The isConnErrno function reports whether the error chain contains one of connErrnos, that is a reset, refused or broken connection
+func isConnErrno(err error) bool {
+	for _, errno := range connErrnos {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,208 @@
+package retry
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 默认的熔断器和重试预算参数
+// Default circuit breaker and retry budget parameters
+const (
+	defaultBreakerThreshold = 5                // 连续失败多少次后打开熔断器 Consecutive failures before the circuit opens
+	defaultBreakerCooldown  = 30 * time.Second // 熔断器打开后多久允许一次探测请求 How long the circuit stays open before a probe is allowed
+	defaultBudgetTokens     = 10               // 重试预算的令牌上限 Maximum tokens of the retry budget
+	defaultBudgetRatio      = 0.1              // 每次成功的请求归还的令牌数 Tokens returned by every successful request
+	defaultHostIdleTimeout  = 10 * time.Minute // 空闲多久之后熔断器关闭的主机状态被删除 How long a host with a closed circuit stays idle before its state is removed
+)
+
+// CircuitState 类型表示熔断器的状态
+// The CircuitState type represents the state of a circuit breaker
+type CircuitState int
+
+const (
+	CircuitClosed   CircuitState = iota // 关闭，请求正常发送 Closed, requests are sent normally
+	CircuitOpen                         // 打开，请求直接失败 Open, requests fail immediately
+	CircuitHalfOpen                     // 半开，只允许一个探测请求 Half open, only one probe request is allowed
+)
+
+// String 方法返回熔断器状态的名称
+// The String method returns the name of the circuit state
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// HostState 结构体是一个主机的熔断器和重试预算的快照，用于调试
+// The HostState struct is a snapshot of the circuit breaker and retry budget of a host, used for debugging
+type HostState struct {
+	Host     string       // 主机 Host
+	Circuit  CircuitState // 熔断器的状态 State of the circuit breaker
+	Failures int          // 连续失败的次数 Number of consecutive failures
+	OpenedAt time.Time    // 熔断器最后一次打开的时间 Time the circuit breaker last opened
+	Tokens   float64      // 剩余的重试令牌，未启用重试预算时为 -1 Retry tokens left, -1 when the retry budget is disabled
+}
+
+// outcome 类型表示一次请求对熔断器和重试预算的影响
+// The outcome type represents the effect of one request on the circuit breaker and the retry budget
+type outcome int
+
+const (
+	outcomeSuccess outcome = iota // 主机正常响应 The host responded normally
+	outcomeFailure                // 连接错误或可重试的状态码 Connection error or retryable status code
+	outcomeIgnored                // 请求被取消，不能说明主机的状态 The request was cancelled and says nothing about the host
+)
+
+// hostGuard 结构体保存一个主机的熔断器和重试预算，可以被多个 goroutine 并发使用
+// The hostGuard struct keeps the circuit breaker and retry budget of a host and is safe for concurrent use by multiple goroutines
+type hostGuard struct {
+	host string
+
+	threshold int           // 连续失败的阈值，0 表示不启用熔断器 Consecutive failure threshold, 0 disables the circuit breaker
+	cooldown  time.Duration // 熔断器打开的时间 How long the circuit stays open
+	maxTokens float64       // 令牌上限，0 表示不启用重试预算 Maximum tokens, 0 disables the retry budget
+	ratio     float64       // 每次成功归还的令牌数 Tokens returned per success
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	probing  bool // 半开状态下是否已经有探测请求在进行 Whether a probe is in flight in the half open state
+	tokens   float64
+	lastUsed time.Time // 最后一次发送请求或记录结果的时间 Time of the last request sent or outcome recorded
+	now      func() time.Time
+}
+
+// newHostGuard 函数创建一个新的 hostGuard 实例，重试预算开始时是满的
+// The newHostGuard function creates a new hostGuard instance, the retry budget starts full
+func newHostGuard(host string, threshold int, cooldown time.Duration, maxTokens, ratio float64, now func() time.Time) *hostGuard {
+	return &hostGuard{
+		host:      host,
+		threshold: threshold,
+		cooldown:  cooldown,
+		maxTokens: maxTokens,
+		ratio:     ratio,
+		tokens:    maxTokens,
+		lastUsed:  now(),
+		now:       now,
+	}
+}
+
+// acquire 方法判断是否可以发送一次请求，retry 表示这是一次重试，需要消耗一个令牌，空的 hostGuard 总是允许发送
+// 熔断器打开时返回 ErrorCircuitOpen，令牌不足时返回 ErrorRetryBudgetExhausted
+// The acquire method reports whether a request may be sent, retry means this is a retry that consumes one token, a nil hostGuard always allows sending
+// It returns ErrorCircuitOpen when the circuit is open and ErrorRetryBudgetExhausted when there are not enough tokens
+func (g *hostGuard) acquire(retry bool) error {
+	if g == nil {
+		return nil
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.lastUsed = g.now()
+
+	// 先检查重试预算，令牌不足时不改变熔断器的状态
+	// Check the retry budget first so that the circuit state is untouched when tokens run out
+	if retry && g.maxTokens > 0 && g.tokens < 1 {
+		return fmt.Errorf("retry host %q: %w", g.host, ErrorRetryBudgetExhausted)
+	}
+
+	switch g.state {
+	case CircuitOpen:
+		if g.now().Sub(g.openedAt) < g.cooldown {
+			return fmt.Errorf("retry host %q: %w", g.host, ErrorCircuitOpen)
+		}
+		g.state = CircuitHalfOpen
+		g.probing = true
+	case CircuitHalfOpen:
+		if g.probing {
+			return fmt.Errorf("retry host %q: %w", g.host, ErrorCircuitOpen)
+		}
+		g.probing = true
+	}
+
+	if retry && g.maxTokens > 0 {
+		g.tokens--
+	}
+
+	return nil
+}
+
+// record 方法记录一次请求的结果，更新熔断器的状态并在成功时归还令牌
+// The record method records the outcome of a request, updating the circuit state and returning tokens on success
+func (g *hostGuard) record(result outcome) {
+	if g == nil {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.lastUsed = g.now()
+	if g.state == CircuitHalfOpen {
+		g.probing = false
+	}
+
+	switch result {
+	case outcomeSuccess:
+		g.state = CircuitClosed
+		g.failures = 0
+		if g.maxTokens > 0 {
+			g.tokens += g.ratio
+			if g.tokens > g.maxTokens {
+				g.tokens = g.maxTokens
+			}
+		}
+
+	case outcomeFailure:
+		g.failures++
+		if g.threshold > 0 && (g.state == CircuitHalfOpen || g.failures >= g.threshold) {
+			g.state = CircuitOpen
+			g.openedAt = g.now()
+		}
+	}
+}
+
+// idle 方法判断主机是否已经空闲超过 timeout 并且熔断器是关闭的，这样的状态可以被删除，下一次请求时重新创建
+// The idle method reports whether the host has been idle for longer than timeout with a closed circuit, such state can be removed and is created again on the next request
+func (g *hostGuard) idle(timeout time.Duration) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.state == CircuitClosed && g.now().Sub(g.lastUsed) >= timeout
+}
+
+// snapshot 方法返回当前状态的快照
+// The snapshot method returns a snapshot of the current state
+func (g *hostGuard) snapshot() HostState {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	state := HostState{
+		Host:     g.host,
+		Circuit:  g.state,
+		Failures: g.failures,
+		OpenedAt: g.openedAt,
+		Tokens:   -1,
+	}
+
+	// 冷却时间已过的熔断器在下一次请求时会进入半开状态
+	// A circuit whose cooldown has passed goes half open on the next request
+	if state.Circuit == CircuitOpen && g.now().Sub(g.openedAt) >= g.cooldown {
+		state.Circuit = CircuitHalfOpen
+	}
+	if g.maxTokens > 0 {
+		state.Tokens = g.tokens
+	}
+
+	return state
+}
@@ -0,0 +1,122 @@
+package retry
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircuitState_String(t *testing.T) {
+	assert.Equal(t, "closed", CircuitClosed.String())
+	assert.Equal(t, "open", CircuitOpen.String())
+	assert.Equal(t, "half-open", CircuitHalfOpen.String())
+	assert.Equal(t, "unknown", CircuitState(42).String())
+}
+
+func TestHostGuard_CircuitBreaker(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	g := newHostGuard("api", 2, time.Minute, 0, 0, func() time.Time { return now })
+
+	assert.NoError(t, g.acquire(false))
+	g.record(outcomeFailure)
+	assert.Equal(t, CircuitClosed, g.snapshot().Circuit)
+
+	// 成功的请求会清零连续失败的次数
+	// A successful request resets the consecutive failures
+	g.record(outcomeSuccess)
+	g.record(outcomeFailure)
+	assert.Equal(t, 1, g.snapshot().Failures)
+
+	g.record(outcomeFailure)
+	state := g.snapshot()
+	assert.Equal(t, CircuitOpen, state.Circuit)
+	assert.Equal(t, now, state.OpenedAt)
+	assert.True(t, errors.Is(g.acquire(false), ErrorCircuitOpen))
+
+	// 冷却时间过后只允许一个探测请求
+	// Only one probe is allowed after the cooldown
+	now = now.Add(time.Minute)
+	assert.Equal(t, CircuitHalfOpen, g.snapshot().Circuit)
+	assert.NoError(t, g.acquire(false))
+	assert.True(t, errors.Is(g.acquire(false), ErrorCircuitOpen))
+
+	// 探测失败时熔断器重新打开
+	// The circuit opens again when the probe fails
+	g.record(outcomeFailure)
+	assert.Equal(t, CircuitOpen, g.snapshot().Circuit)
+
+	// 被取消的探测请求允许下一个探测请求
+	// A cancelled probe lets the next probe through
+	now = now.Add(time.Minute)
+	assert.NoError(t, g.acquire(false))
+	g.record(outcomeIgnored)
+	assert.NoError(t, g.acquire(false))
+
+	// 探测成功时熔断器关闭
+	// The circuit closes when the probe succeeds
+	g.record(outcomeSuccess)
+	state = g.snapshot()
+	assert.Equal(t, CircuitClosed, state.Circuit)
+	assert.Equal(t, 0, state.Failures)
+	assert.NoError(t, g.acquire(false))
+}
+
+func TestHostGuard_CircuitBreakerDisabled(t *testing.T) {
+	g := newHostGuard("api", 0, time.Minute, 0, 0, time.Now)
+	for i := 0; i < 100; i++ {
+		assert.NoError(t, g.acquire(false))
+		g.record(outcomeFailure)
+	}
+	assert.Equal(t, CircuitClosed, g.snapshot().Circuit)
+	assert.Equal(t, float64(-1), g.snapshot().Tokens)
+}
+
+func TestHostGuard_RetryBudget(t *testing.T) {
+	g := newHostGuard("api", 0, 0, 2, 0.5, time.Now)
+	assert.Equal(t, float64(2), g.snapshot().Tokens)
+
+	// 第一次请求不消耗令牌
+	// First attempts do not consume tokens
+	assert.NoError(t, g.acquire(false))
+	assert.NoError(t, g.acquire(true))
+	assert.NoError(t, g.acquire(true))
+	assert.True(t, errors.Is(g.acquire(true), ErrorRetryBudgetExhausted))
+	assert.NoError(t, g.acquire(false))
+	assert.Equal(t, float64(0), g.snapshot().Tokens)
+
+	// 成功的请求归还令牌，但不超过上限
+	// Successful requests return tokens, up to the maximum
+	g.record(outcomeSuccess)
+	assert.True(t, errors.Is(g.acquire(true), ErrorRetryBudgetExhausted))
+	g.record(outcomeSuccess)
+	assert.NoError(t, g.acquire(true))
+	for i := 0; i < 10; i++ {
+		g.record(outcomeSuccess)
+	}
+	assert.Equal(t, float64(2), g.snapshot().Tokens)
+}
+
+func TestHostGuard_Idle(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	g := newHostGuard("api", 1, time.Hour, 0, 0, func() time.Time { return now })
+
+	assert.NoError(t, g.acquire(false))
+	now = now.Add(time.Minute)
+	assert.False(t, g.idle(2*time.Minute))
+	now = now.Add(time.Minute)
+	assert.True(t, g.idle(2*time.Minute))
+
+	// 熔断器打开的主机即使空闲也不会被删除
+	// Hosts with an open circuit are never idle, however long they were unused
+	g.record(outcomeFailure)
+	now = now.Add(time.Hour)
+	assert.False(t, g.idle(2*time.Minute))
+}
+
+func TestHostGuard_Nil(t *testing.T) {
+	var g *hostGuard
+	assert.NoError(t, g.acquire(true))
+	g.record(outcomeFailure)
+}
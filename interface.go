@@ -1,6 +1,9 @@
 package retry
 
-import "time"
+import (
+	"math/rand"
+	"time"
+)
 
 // Callback 接口用于定义重试回调函数
 // The Callback interface is used to define the retry callback function.
@@ -10,6 +13,46 @@ type Callback interface {
 	OnRetry(count int64, delay time.Duration, err error)
 }
 
+// Backoff 接口定义了有状态的退避策略，每次调用 TryOnConflict 时都会通过 BackoffFactory 创建一个新的实例
+// The Backoff interface defines a stateful backoff strategy, a new instance is created through BackoffFactory for each TryOnConflict call
+type Backoff interface {
+	// Next 方法根据已执行的次数（从 1 开始）和最后一次的错误返回下一次重试前的延迟时间，返回 false 表示停止重试
+	// The Next method returns the delay before the next retry based on the number of executions so far (starting at 1) and the last error, returning false stops retrying
+	Next(attempt int64, lastErr error) (time.Duration, bool)
+
+	// Reset 方法将退避策略恢复到初始状态
+	// The Reset method restores the backoff strategy to its initial state
+	Reset()
+}
+
+// RandomizedBackoff 接口由需要随机数的退避策略实现，TryOnConflict 会为每次调用传入一个独立的随机数生成器
+// The RandomizedBackoff interface is implemented by backoff strategies that need random numbers, TryOnConflict passes a dedicated generator for each call
+type RandomizedBackoff interface {
+	Backoff
+
+	// SetRand 方法设置退避策略使用的随机数生成器，该生成器只在本次调用中使用，不需要加锁
+	// The SetRand method sets the random number generator used by the backoff, the generator is only used within this call and needs no locking
+	SetRand(rng *rand.Rand)
+}
+
+// ErrorCoder 接口由带有错误码的错误实现，默认的错误码函数使用它来获取按错误码的重试次数
+// The ErrorCoder interface is implemented by errors that carry an error code, the default error code function uses it to look up retry budgets by code
+type ErrorCoder interface {
+	// Code 方法返回错误码
+	// The Code method returns the error code
+	Code() string
+}
+
+// RetryAfterError 接口由带有重试等待提示的错误实现，例如带有 Retry-After 头的 HTTP 响应，下一次重试前的等待时间不会小于该提示
+// The RetryAfterError interface is implemented by errors that carry a retry wait hint, such as HTTP responses with a Retry-After header, the wait before the next retry is never shorter than the hint
+type RetryAfterError interface {
+	error
+
+	// RetryAfter 方法返回下一次重试前至少需要等待的时间
+	// The RetryAfter method returns the minimum wait before the next retry
+	RetryAfter() time.Duration
+}
+
 // RetryResult 接口定义了执行结果的相关方法
 // The RetryResult interface defines methods related to execution results
 type RetryResult = interface {
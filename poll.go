@@ -0,0 +1,68 @@
+package retry
+
+import "context"
+
+// ConditionFunc 类型定义了轮询的条件函数类型，done 为 true 时轮询结束，返回错误时由配置中的 RetryIfFunc 判断是否可以容忍
+// The ConditionFunc type defines the condition function type of a poll, the poll ends when done is true, and returned errors are tolerated when the configured RetryIfFunc allows it
+type ConditionFunc = func(ctx context.Context) (done bool, err error)
+
+// Poll 函数反复检查 cond 直到它返回 true，检查的间隔由 conf 的退避策略决定，conf 为空时使用默认配置，配置会被复制
+// 第一次检查总是立即进行，配置的超时时间限制整个轮询并传递给 cond 的上下文，配置的回调函数在每次等待之前被调用，条件未满足时的错误为 ErrorRetryResultRejected
+// cond 返回的错误在 RetryIfFunc 允许时被容忍并继续轮询，否则立即停止，例如使用 classify.Transient 只容忍临时错误
+// 条件满足时结果成功，错误不被容忍时 TryError 返回这个错误，上下文结束时返回上下文的错误，检查次数用完时返回 ErrorRetryAttemptsExceeded
+// 配置中的 WithRetryIfResult 函数与条件一起生效：只有 cond 返回 true 并且该函数不要求重试时轮询才成功，函数收到的返回值是 done
+// The Poll function checks cond repeatedly until it returns true, the interval between checks follows the backoff of conf, the default configuration is used when conf is nil and the configuration is copied
+// The first check always happens immediately, the configured timeout bounds the whole poll and is passed on in the context of cond, and the configured callback is called before every wait, with ErrorRetryResultRejected as the error when the condition is not met yet
+// Errors returned by cond are tolerated and the poll goes on when RetryIfFunc allows it, otherwise it stops immediately, for example classify.Transient tolerates only transient errors
+// The result succeeds once the condition is met, TryError returns the error when it is not tolerated, the context error when the context is done and ErrorRetryAttemptsExceeded when the checks run out
+// A WithRetryIfResult function in the configuration applies together with the condition: the poll only succeeds when cond returns true and the function does not ask for a retry, and the value the function receives is done
+func Poll(ctx context.Context, conf *Config, cond ConditionFunc) *Result {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	// newImmediateRetry 清除了结果检查函数，因此先从调用方的配置中取出
+	// newImmediateRetry clears the result check function, so take it from the caller's configuration first
+	var retryIfResult RetryIfResultFunc
+	if conf != nil {
+		retryIfResult = conf.retryIfResultFunc
+	}
+
+	r := newImmediateRetry(conf, defaultRetryIfFunc)
+	r.config.WithRetryIfResult(func(data any, err error) bool {
+		if err == nil && data == false {
+			return true
+		}
+		return retryIfResult != nil && retryIfResult(data, err)
+	})
+
+	// 超时时间由 Poll 处理，这样 cond 也能看到截止时间
+	// The timeout is handled by Poll so that cond sees the deadline as well
+	if timeout := r.config.timeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+		r.config.WithTimeout(0)
+	}
+
+	var lastErr error
+	result := r.TryOnConflictContext(ctx, func() (any, error) {
+		done, err := cond(ctx)
+		if err != nil {
+			lastErr = err
+			return nil, err
+		}
+		return done, nil
+	})
+
+	if result.IsSuccess() || ctx.Err() != nil {
+		return result
+	}
+
+	// 不被容忍的错误直接作为结果的错误，调用方不需要开启详细信息就能知道失败的原因
+	// An error that is not tolerated becomes the error of the result, so callers know why it failed without enabling details
+	if result.TryError() == ErrorRetryIf && lastErr != nil {
+		result.tryError = lastErr
+	}
+	return result
+}
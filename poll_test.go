@@ -0,0 +1,135 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newPollConfig() *Config {
+	return NewConfig().
+		WithInitDelay(time.Hour).
+		WithBackOffFunc(func(int64) time.Duration { return 0 }).
+		WithMaxDelay(time.Millisecond).
+		WithAttempts(10)
+}
+
+func TestPoll_Done(t *testing.T) {
+	cb := &delayCallback{}
+	checks := 0
+	start := time.Now()
+
+	// 第一次检查立即进行，之后的间隔被最大延迟时间限制
+	// The first check happens immediately and later intervals are capped by the maximum delay
+	result := Poll(context.Background(), newPollConfig().WithCallback(cb), func(ctx context.Context) (bool, error) {
+		checks++
+		return checks == 3, nil
+	})
+	assert.True(t, result.IsSuccess())
+	assert.Nil(t, result.TryError())
+	assert.Equal(t, 3, checks)
+	assert.Equal(t, int64(3), result.Count())
+	assert.Len(t, cb.delays, 2)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestPoll_TransientAndFatal(t *testing.T) {
+	errTransient := errors.New("transient")
+	errFatal := errors.New("fatal")
+	cfg := newPollConfig().WithRetryIfFunc(func(err error) bool { return errors.Is(err, errTransient) })
+
+	// 临时错误被容忍
+	// Transient errors are tolerated
+	checks := 0
+	result := Poll(context.Background(), cfg, func(ctx context.Context) (bool, error) {
+		checks++
+		if checks < 3 {
+			return false, errTransient
+		}
+		return true, nil
+	})
+	assert.True(t, result.IsSuccess())
+	assert.Equal(t, 3, checks)
+
+	// 致命错误立即停止轮询并作为结果的错误
+	// Fatal errors stop the poll immediately and become the error of the result
+	checks = 0
+	result = Poll(context.Background(), cfg, func(ctx context.Context) (bool, error) {
+		checks++
+		if checks == 2 {
+			return false, errFatal
+		}
+		return false, nil
+	})
+	assert.False(t, result.IsSuccess())
+	assert.Equal(t, errFatal, result.TryError())
+	assert.Equal(t, 2, checks)
+}
+
+func TestPoll_RetryIfResult(t *testing.T) {
+	var checks, seen int
+	conf := newPollConfig().WithRetryIfResult(func(data any, err error) bool {
+		seen++
+		return seen < 3
+	})
+
+	// 条件满足后配置的结果检查函数仍然可以要求继续轮询
+	// The configured result check can still ask to keep polling after the condition is met
+	result := Poll(context.Background(), conf, func(ctx context.Context) (bool, error) {
+		checks++
+		return true, nil
+	})
+	assert.True(t, result.IsSuccess())
+	assert.Equal(t, 3, checks)
+
+	// 条件未满足时结果检查函数不能让轮询提前成功
+	// The result check cannot make the poll succeed before the condition is met
+	checks = 0
+	conf = newPollConfig().WithAttempts(4).WithRetryIfResult(func(data any, err error) bool { return false })
+	result = Poll(context.Background(), conf, func(ctx context.Context) (bool, error) {
+		checks++
+		return false, nil
+	})
+	assert.False(t, result.IsSuccess())
+	assert.Equal(t, ErrorRetryAttemptsExceeded, result.TryError())
+	assert.Equal(t, 4, checks)
+}
+
+func TestPoll_AttemptsExceeded(t *testing.T) {
+	checks := 0
+	result := Poll(context.Background(), newPollConfig().WithAttempts(4), func(ctx context.Context) (bool, error) {
+		checks++
+		return false, nil
+	})
+	assert.Equal(t, ErrorRetryAttemptsExceeded, result.TryError())
+	assert.Equal(t, false, result.Data())
+	assert.Equal(t, 4, checks)
+}
+
+func TestPoll_Timeout(t *testing.T) {
+	cfg := newPollConfig().WithAttempts(10000).WithTimeout(50 * time.Millisecond)
+
+	var deadline bool
+	result := Poll(context.Background(), cfg, func(ctx context.Context) (bool, error) {
+		_, deadline = ctx.Deadline()
+		return false, nil
+	})
+	assert.Equal(t, context.DeadlineExceeded, result.TryError())
+	assert.True(t, deadline)
+}
+
+func TestPoll_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	checks := 0
+	result := Poll(ctx, newPollConfig(), func(ctx context.Context) (bool, error) {
+		checks++
+		return true, nil
+	})
+	assert.Equal(t, context.Canceled, result.TryError())
+	assert.Equal(t, 0, checks)
+}
@@ -0,0 +1,729 @@
+package retry
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 队列目录中日志文件和锁文件的名称
+// Names of the journal file and the lock file in the queue directory
+const (
+	queueJournalName = "journal.log"
+	queueLockName    = "queue.lock"
+)
+
+// 运行时压缩日志的最少记录数，日志的记录数还需要超过存活任务数的两倍
+// Minimum number of records before the journal is compacted at runtime, the journal must also hold more than twice as many records as live jobs
+const queueCompactRecords = 1024
+
+// 日志记录的操作
+// Operations of the journal records
+const (
+	journalPut    = "put"    // 写入等待执行的任务 Writes a pending job
+	journalDead   = "dead"   // 把任务移到死信存储 Moves a job to the dead-letter store
+	journalDelete = "delete" // 删除任务 Deletes a job
+)
+
+// JobError 结构体记录任务一次执行的错误
+// The JobError struct records the error of one execution of a job
+type JobError struct {
+	Attempt int64     `json:"attempt"`        // 第几次执行 Which execution it was
+	At      time.Time `json:"at"`             // 失败的时间 Time of the failure
+	Error   string    `json:"error"`          // 错误信息 Error message
+	Code    string    `json:"code,omitempty"` // 配置的错误码函数返回的错误码 Error code returned by the configured error code function
+}
+
+// Job 结构体是持久化队列中的一个任务
+// The Job struct is a job in the persistent queue
+type Job struct {
+	ID          string     `json:"id"`                // 任务的唯一标识 Unique identifier of the job
+	Type        string     `json:"type"`              // 任务的类型，决定使用哪个处理函数和配置 Type of the job, selecting the handler and configuration
+	Payload     []byte     `json:"payload,omitempty"` // 任务的数据 Data of the job
+	Attempts    int64      `json:"attempts"`          // 已经失败的次数 Number of failed executions so far
+	NextAttempt time.Time  `json:"next_attempt"`      // 下一次执行的时间 Time of the next execution
+	CreatedAt   time.Time  `json:"created_at"`        // 加入队列的时间 Time the job was enqueued
+	Errors      []JobError `json:"errors,omitempty"`  // 所有失败的记录 History of every failure
+}
+
+// clone 方法返回任务的副本
+// The clone method returns a copy of the job
+func (j *Job) clone() *Job {
+	c := *j
+	c.Payload = append([]byte(nil), j.Payload...)
+	c.Errors = append([]JobError(nil), j.Errors...)
+	return &c
+}
+
+// JobHandler 类型定义了执行任务的函数类型，返回错误时按任务类型的配置重试
+// The JobHandler type defines the function type that runs a job, errors are retried following the configuration of the job type
+type JobHandler = func(ctx context.Context, job Job) error
+
+// jobHandler 结构体保存一种任务类型的处理函数和重试策略
+// The jobHandler struct keeps the handler and retry policy of a job type
+type jobHandler struct {
+	retry   *Retry
+	handler JobHandler
+}
+
+// journalFile 接口是队列写入日志文件需要的方法，由 *os.File 实现
+// The journalFile interface is the methods the queue needs to write the journal file, implemented by *os.File
+type journalFile interface {
+	io.WriteCloser
+	Stat() (os.FileInfo, error)
+	Sync() error
+	Truncate(size int64) error
+}
+
+// journalRecord 结构体是日志文件中的一行
+// The journalRecord struct is one line of the journal file
+type journalRecord struct {
+	Op  string `json:"op"`
+	Job *Job   `json:"job,omitempty"`
+	ID  string `json:"id,omitempty"`
+}
+
+// Queue 结构体是一个保存在本地目录中的持久化重试队列，进程重启后任务从保存的下一次执行时间继续，可以被多个 goroutine 并发使用
+// 所有的变化都追加到目录中的日志文件并立即同步到磁盘，打开队列时重放并压缩日志，运行中日志的记录数超过阈值时也会压缩
+// 目录中的锁文件保证同一时间只有一个队列打开同一个目录，另一个 OpenQueue 返回 ErrorQueueLocked
+// 任务至少执行一次：执行中进程崩溃或队列停止时，任务会在下一次运行时重新执行
+// The Queue struct is a persistent retry queue kept in a local directory whose jobs continue from their saved next execution time after a restart, and is safe for concurrent use by multiple goroutines
+// Every change is appended to the journal file in the directory and synced to disk immediately, the journal is replayed and compacted when the queue is opened, and compacted again at runtime once it holds too many records
+// A lock file in the directory ensures only one queue opens a directory at a time, another OpenQueue returns ErrorQueueLocked
+// Jobs run at least once: a job interrupted by a crash or by stopping the queue runs again on the next run
+type Queue struct {
+	path      string
+	lock      *os.File
+	file      journalFile
+	failed    error
+	records   int
+	compactAt int
+
+	mu       sync.Mutex
+	handlers map[string]*jobHandler
+	pending  map[string]*Job
+	dead     map[string]*Job
+	inflight map[string]bool
+	changed  chan struct{}
+	closed   bool
+	now      func() time.Time
+}
+
+// OpenQueue 函数打开 dir 目录中的队列，目录不存在时创建，日志末尾不完整的一行会被忽略
+// The OpenQueue function opens the queue in the dir directory, creating the directory when it does not exist, and an incomplete last line of the journal is ignored
+func OpenQueue(dir string) (*Queue, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("retry queue %s: %w", dir, err)
+	}
+
+	lock, err := lockQueueDir(filepath.Join(dir, queueLockName))
+	if err != nil {
+		return nil, err
+	}
+
+	q := &Queue{
+		path:      filepath.Join(dir, queueJournalName),
+		lock:      lock,
+		compactAt: queueCompactRecords,
+		handlers:  make(map[string]*jobHandler),
+		pending:   make(map[string]*Job),
+		dead:      make(map[string]*Job),
+		inflight:  make(map[string]bool),
+		changed:   make(chan struct{}),
+		now:       time.Now,
+	}
+
+	if err := q.replay(); err != nil {
+		_ = unlockQueueDir(lock)
+		return nil, err
+	}
+	if err := q.compact(); err != nil {
+		_ = unlockQueueDir(lock)
+		return nil, err
+	}
+
+	return q, nil
+}
+
+// replay 方法读取日志文件并恢复队列的状态
+// The replay method reads the journal file and restores the state of the queue
+func (q *Queue) replay() error {
+	f, err := os.Open(q.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("retry queue %s: %w", q.path, err)
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+
+		// 没有换行符的最后一行是写入时崩溃留下的，忽略它
+		// A last line without a newline was left by a crash while writing, ignore it
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("retry queue %s: %w", q.path, err)
+		}
+
+		data = bytes.TrimSpace(data)
+		if len(data) == 0 {
+			continue
+		}
+
+		var rec journalRecord
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return fmt.Errorf("retry queue %s line %d: %w", q.path, line, err)
+		}
+		q.apply(&rec)
+	}
+}
+
+// apply 方法把一条日志记录应用到队列的状态
+// The apply method applies a journal record to the state of the queue
+func (q *Queue) apply(rec *journalRecord) {
+	switch rec.Op {
+	case journalPut:
+		if rec.Job != nil {
+			delete(q.dead, rec.Job.ID)
+			q.pending[rec.Job.ID] = rec.Job
+		}
+	case journalDead:
+		if rec.Job != nil {
+			delete(q.pending, rec.Job.ID)
+			q.dead[rec.Job.ID] = rec.Job
+		}
+	case journalDelete:
+		delete(q.pending, rec.ID)
+		delete(q.dead, rec.ID)
+	}
+}
+
+// compact 方法把当前的状态写入新的日志文件并替换旧的日志，然后打开它用于追加，调用时需要持有锁
+// 替换之前失败时旧的日志保持不变，替换之后无法打开新的日志时队列不再接受写入
+// The compact method writes the current state to a new journal file that replaces the old one, then opens it for appending, the lock must be held
+// A failure before the replacement leaves the old journal untouched, and when the new journal cannot be opened after the replacement the queue refuses further writes
+func (q *Queue) compact() error {
+	tmp := q.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("retry queue %s: %w", tmp, err)
+	}
+
+	records := make([]*journalRecord, 0, len(q.pending)+len(q.dead))
+	for _, job := range sortedJobs(q.pending) {
+		records = append(records, &journalRecord{Op: journalPut, Job: job})
+	}
+	for _, job := range sortedJobs(q.dead) {
+		records = append(records, &journalRecord{Op: journalDead, Job: job})
+	}
+
+	w := bufio.NewWriter(f)
+	for _, rec := range records {
+		if err = writeRecord(w, rec); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, q.path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("retry queue %s: %w", q.path, err)
+	}
+	syncDir(filepath.Dir(q.path))
+
+	if q.file != nil {
+		_ = q.file.Close()
+		q.file = nil
+	}
+	file, err := os.OpenFile(q.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		q.failed = fmt.Errorf("retry queue %s: %w", q.path, err)
+		return q.failed
+	}
+	q.file = file
+	q.records = len(records)
+	return nil
+}
+
+// append 方法追加一条日志记录并同步到磁盘，调用时需要持有锁
+// 写入或同步失败时把日志截断回写入之前的长度，避免不完整的一行和之后的记录连在一起，截断也失败时队列不再接受写入
+// The append method appends a journal record and syncs it to disk, the lock must be held
+// When writing or syncing fails the journal is truncated back to its length before the write, so a partial line never joins the records after it, and when truncating fails too the queue refuses further writes
+func (q *Queue) append(rec *journalRecord) error {
+	if q.failed != nil {
+		return q.failed
+	}
+
+	var buf bytes.Buffer
+	if err := writeRecord(&buf, rec); err != nil {
+		return err
+	}
+
+	info, err := q.file.Stat()
+	if err != nil {
+		return fmt.Errorf("retry queue %s: %w", q.path, err)
+	}
+
+	if _, err = q.file.Write(buf.Bytes()); err == nil {
+		err = q.file.Sync()
+	}
+	if err != nil {
+		if truncErr := q.file.Truncate(info.Size()); truncErr != nil {
+			q.failed = fmt.Errorf("retry queue %s: journal left incomplete: %w", q.path, truncErr)
+		}
+		return fmt.Errorf("retry queue %s: %w", q.path, err)
+	}
+
+	q.apply(rec)
+	q.records++
+
+	// 记录数超过阈值时压缩日志，压缩失败时旧的日志仍然完整，下一次追加时再试
+	// Compact the journal once it holds too many records, a failed compaction leaves the old journal intact and is tried again on the next append
+	if q.records >= q.compactAt && q.records > 2*(len(q.pending)+len(q.dead)) {
+		_ = q.compact()
+	}
+	return nil
+}
+
+// notify 方法唤醒所有等待变化的 worker，调用时需要持有锁
+// The notify method wakes every worker waiting for a change, the lock must be held
+func (q *Queue) notify() {
+	close(q.changed)
+	q.changed = make(chan struct{})
+}
+
+// Handle 方法设置一种任务类型的处理函数和重试配置并返回 Queue 实例，conf 为空时使用默认配置，配置会被复制
+// 配置的重试次数限制任务执行的次数，超时时间限制每一次执行，RetryIfFunc 拒绝的错误让任务立即进入死信存储，回调函数在每次安排重试时被调用
+// The Handle method sets the handler and retry configuration of a job type and returns the Queue instance, using the default configuration when conf is nil, and the configuration is copied
+// The configured attempts limit the executions of a job, the timeout bounds every execution, errors rejected by RetryIfFunc move the job to the dead-letter store immediately, and the callback is called whenever a retry is scheduled
+// 每次失败都会创建新的退避策略，并用之前的执行次数重放 Next，让有状态的退避策略得到和连续重试相同的延迟时间，重放时之前的错误为 nil
+// 按错误和错误码的重试次数在最近一次放回队列之后计算，之前的错误按错误信息和错误码与本次的错误比较
+// Every failure creates a new backoff and replays Next with the earlier execution counts, so stateful backoffs give the same delays as back-to-back retries, and the earlier errors are nil during the replay
+// The attempts by error and by error code count since the job was last requeued, comparing earlier errors with the current one by error message and error code
+func (q *Queue) Handle(jobType string, conf *Config, handler JobHandler) *Queue {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.handlers[jobType] = &jobHandler{retry: newImmediateRetry(conf, defaultRetryIfFunc), handler: handler}
+	q.notify()
+	return q
+}
+
+// Enqueue 方法把一个任务加入队列并返回它的标识，任务在写入磁盘之后才返回，没有处理函数的任务类型会一直等待
+// The Enqueue method adds a job to the queue and returns its identifier, it returns only after the job is on disk, and jobs of a type without a handler keep waiting
+func (q *Queue) Enqueue(jobType string, payload []byte) (string, error) {
+	id, err := newJobID()
+	if err != nil {
+		return "", err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return "", ErrorQueueClosed
+	}
+
+	now := q.now()
+	job := &Job{
+		ID:          id,
+		Type:        jobType,
+		Payload:     append([]byte(nil), payload...),
+		NextAttempt: now,
+		CreatedAt:   now,
+	}
+	if err := q.append(&journalRecord{Op: journalPut, Job: job}); err != nil {
+		return "", err
+	}
+
+	q.notify()
+	return id, nil
+}
+
+// Run 方法使用 workers 个 goroutine 执行到期的任务，直到上下文结束，workers 小于 1 时使用 1 个
+// 上下文结束时返回上下文的错误，队列关闭时返回 ErrorQueueClosed，写入日志失败时返回该错误
+// The Run method runs due jobs with workers goroutines until the context is done, using 1 when workers is less than 1
+// It returns the context error when the context is done, ErrorQueueClosed when the queue is closed, and the error when writing the journal fails
+func (q *Queue) Run(ctx context.Context, workers int) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := q.work(runCtx); err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}()
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// work 方法是一个 worker 的循环，没有到期的任务时等待下一个任务到期或者队列发生变化
+// The work method is the loop of a worker, waiting for the next job to become due or the queue to change when nothing is due
+func (q *Queue) work(ctx context.Context) error {
+	for {
+		job, h, wait, changed, err := q.take()
+		if err != nil {
+			return err
+		}
+
+		if job != nil {
+			if err := q.process(ctx, job, h); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var (
+			tr      *time.Timer
+			timeout <-chan time.Time
+		)
+		if wait > 0 {
+			tr = time.NewTimer(wait)
+			timeout = tr.C
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-changed:
+		case <-timeout:
+		}
+		if tr != nil {
+			tr.Stop()
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+// take 方法取出最早到期的任务，没有到期的任务时返回距离下一个任务到期的时间，没有等待的任务时返回 0
+// The take method takes the earliest due job, returning the time until the next job is due when nothing is due, or 0 when nothing is waiting
+func (q *Queue) take() (*Job, *jobHandler, time.Duration, <-chan struct{}, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return nil, nil, 0, nil, ErrorQueueClosed
+	}
+
+	var next *Job
+	for id, job := range q.pending {
+		if q.inflight[id] || q.handlers[job.Type] == nil {
+			continue
+		}
+		if next == nil || jobBefore(job, next) {
+			next = job
+		}
+	}
+
+	if next == nil {
+		return nil, nil, 0, q.changed, nil
+	}
+	if wait := next.NextAttempt.Sub(q.now()); wait > 0 {
+		return nil, nil, wait, q.changed, nil
+	}
+
+	q.inflight[next.ID] = true
+	return next.clone(), q.handlers[next.Type], 0, nil, nil
+}
+
+// process 方法执行一次任务并记录结果：成功时删除任务，失败时安排下一次执行或者移到死信存储
+// The process method runs a job once and records the outcome: the job is deleted on success, and on failure the next execution is scheduled or the job moves to the dead-letter store
+func (q *Queue) process(ctx context.Context, job *Job, h *jobHandler) error {
+	conf := h.retry.config
+
+	attemptCtx := ctx
+	if conf.timeout > 0 {
+		var cancel context.CancelFunc
+		attemptCtx, cancel = context.WithTimeout(ctx, conf.timeout)
+		defer cancel()
+	}
+	err := runTask(attemptCtx, func(ctx context.Context) error { return h.handler(ctx, *job.clone()) })
+
+	q.mu.Lock()
+	delete(q.inflight, job.ID)
+	q.notify()
+
+	// 队列关闭、任务已经不在队列中或者 Run 停止时不记录这次执行，任务下一次会重新执行
+	// Nothing is recorded when the queue is closed, the job left the queue or Run stopped, so the job runs again next time
+	if _, ok := q.pending[job.ID]; !ok || q.closed || (err != nil && ctx.Err() != nil) {
+		q.mu.Unlock()
+		return nil
+	}
+
+	if err == nil {
+		err = q.append(&journalRecord{Op: journalDelete, ID: job.ID})
+		q.mu.Unlock()
+		return err
+	}
+
+	now := q.now()
+	job.Attempts++
+	job.Errors = append(job.Errors, JobError{Attempt: job.Attempts, At: now, Error: err.Error(), Code: conf.errorCodeFunc(err)})
+
+	var (
+		delay time.Duration
+		retry = conf.retryIfFunc(err) && uint64(job.Attempts) < conf.attempts && jobWithinBudget(conf, job, err)
+	)
+	if retry {
+		delay, retry = jobDelay(h.retry, job, err)
+	}
+
+	rec := &journalRecord{Op: journalDead, Job: job}
+	if retry {
+		job.NextAttempt = now.Add(delay)
+		rec.Op = journalPut
+	}
+	appendErr := q.append(rec)
+	q.mu.Unlock()
+
+	if appendErr != nil {
+		return appendErr
+	}
+	if retry {
+		conf.callback.OnRetry(job.Attempts, delay, err)
+	}
+	return nil
+}
+
+// Jobs 方法返回所有等待执行的任务的副本，按下一次执行的时间排序
+// The Jobs method returns copies of every pending job, sorted by the time of the next execution
+func (q *Queue) Jobs() []Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return copyJobs(sortedJobs(q.pending))
+}
+
+// DeadLetters 方法返回死信存储中所有任务的副本，包括完整的错误记录，按下一次执行的时间排序
+// The DeadLetters method returns copies of every job in the dead-letter store, including the full error history, sorted by the time of the next execution
+func (q *Queue) DeadLetters() []Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return copyJobs(sortedJobs(q.dead))
+}
+
+// Requeue 方法把死信存储中的任务放回队列并立即执行，失败的次数被清零，错误记录被保留
+// The Requeue method moves a job from the dead-letter store back to the queue to run immediately, the failure count is reset and the error history is kept
+func (q *Queue) Requeue(id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return ErrorQueueClosed
+	}
+	job, ok := q.dead[id]
+	if !ok {
+		return fmt.Errorf("retry job %q: %w", id, ErrorJobNotFound)
+	}
+
+	job = job.clone()
+	job.Attempts = 0
+	job.NextAttempt = q.now()
+	if err := q.append(&journalRecord{Op: journalPut, Job: job}); err != nil {
+		return err
+	}
+
+	q.notify()
+	return nil
+}
+
+// Discard 方法从死信存储中删除任务
+// The Discard method deletes a job from the dead-letter store
+func (q *Queue) Discard(id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return ErrorQueueClosed
+	}
+	if _, ok := q.dead[id]; !ok {
+		return fmt.Errorf("retry job %q: %w", id, ErrorJobNotFound)
+	}
+	return q.append(&journalRecord{Op: journalDelete, ID: id})
+}
+
+// Close 方法关闭队列和日志文件，正在执行的任务的结果不再被记录，Run 返回 ErrorQueueClosed
+// The Close method closes the queue and the journal file, the outcome of running jobs is no longer recorded and Run returns ErrorQueueClosed
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return nil
+	}
+	q.closed = true
+	q.notify()
+
+	var err error
+	if q.file != nil {
+		err = q.file.Close()
+	}
+	if unlockErr := unlockQueueDir(q.lock); err == nil {
+		err = unlockErr
+	}
+	return err
+}
+
+// jobDelay 函数在新的退避策略上重放之前的执行次数，然后计算下一次执行前的延迟时间
+// The jobDelay function replays the earlier execution counts on a new backoff, then calculates the delay before the next execution
+func jobDelay(r *Retry, job *Job, err error) (time.Duration, bool) {
+	bo := r.newBackoff()
+	for attempt := int64(1); attempt < job.Attempts; attempt++ {
+		if _, ok := bo.Next(attempt, nil); !ok {
+			return 0, false
+		}
+	}
+	return r.nextDelay(bo, job.Attempts, err)
+}
+
+// jobWithinBudget 函数判断任务按错误和错误码的失败次数是否仍在配置的重试次数之内，任务的错误记录中已经包含本次的错误
+// The jobWithinBudget function reports whether the failures of a job by error and by error code are still within the configured attempts, the error history of the job already includes the current error
+// 只计算最近一次放回队列之后的失败
+// Only the failures since the job was last requeued count
+func jobWithinBudget(conf *Config, job *Job, err error) bool {
+	history := job.Errors
+	if n := int(job.Attempts); n < len(history) {
+		history = history[len(history)-n:]
+	}
+	last := history[len(history)-1]
+
+	if limit, ok := conf.attemptsByError[err]; ok {
+		var count uint64
+		for _, e := range history {
+			if e.Error == last.Error {
+				count++
+			}
+		}
+		if count > limit {
+			return false
+		}
+	}
+
+	if last.Code != "" {
+		if limit, ok := conf.attemptsByCode[last.Code]; ok {
+			var count uint64
+			for _, e := range history {
+				if e.Code == last.Code {
+					count++
+				}
+			}
+			if count > limit {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// writeRecord 函数把一条日志记录写成一行 JSON
+// The writeRecord function writes a journal record as one line of JSON
+func writeRecord(w io.Writer, rec *journalRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// syncDir 函数把目录同步到磁盘，让重命名持久化，不支持的平台上忽略错误
+// The syncDir function syncs the directory to disk so that renames are durable, errors are ignored on platforms without support
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		_ = d.Sync()
+		_ = d.Close()
+	}
+}
+
+// newJobID 函数生成一个随机的任务标识
+// The newJobID function generates a random job identifier
+func newJobID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("retry job id: %w", err)
+	}
+	return hex.EncodeToString(b[:]), nil
+}
+
+// jobBefore 函数判断任务 a 是否应该在任务 b 之前执行
+// The jobBefore function reports whether job a should run before job b
+func jobBefore(a, b *Job) bool {
+	if !a.NextAttempt.Equal(b.NextAttempt) {
+		return a.NextAttempt.Before(b.NextAttempt)
+	}
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	return a.ID < b.ID
+}
+
+// sortedJobs 函数返回按执行顺序排列的任务
+// The sortedJobs function returns the jobs in execution order
+func sortedJobs(jobs map[string]*Job) []*Job {
+	sorted := make([]*Job, 0, len(jobs))
+	for _, job := range jobs {
+		sorted = append(sorted, job)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return jobBefore(sorted[i], sorted[j]) })
+	return sorted
+}
+
+// copyJobs 函数返回任务的副本
+// The copyJobs function returns copies of the jobs
+func copyJobs(jobs []*Job) []Job {
+	copies := make([]Job, len(jobs))
+	for i, job := range jobs {
+		copies[i] = *job.clone()
+	}
+	return copies
+}
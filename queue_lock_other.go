@@ -0,0 +1,80 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd && !windows
+
+package retry
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// lockQueueDir 函数以独占方式创建队列目录中的锁文件并写入当前进程的 PID，文件已经存在时返回 ErrorQueueLocked
+// 进程崩溃后留下的锁文件中的 PID 对应的进程已经不存在，这样的锁文件被当作过期的锁删除后重新创建
+// The lockQueueDir function creates the lock file in the queue directory exclusively and writes the PID of the current process into it, returning ErrorQueueLocked when the file already exists
+// A lock file left behind by a crash holds the PID of a process that no longer exists, and such a stale lock is removed and created again
+func lockQueueDir(path string) (*os.File, error) {
+	f, err := createQueueLock(path)
+	if errors.Is(err, os.ErrExist) && isStaleQueueLock(path) {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("retry queue %s: %w", path, err)
+		}
+		f, err = createQueueLock(path)
+	}
+	if errors.Is(err, os.ErrExist) {
+		return nil, fmt.Errorf("retry queue %s: %w", path, ErrorQueueLocked)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("retry queue %s: %w", path, err)
+	}
+	return f, nil
+}
+
+// createQueueLock 函数以独占方式创建锁文件并写入当前进程的 PID
+// The createQueueLock function creates the lock file exclusively and writes the PID of the current process into it
+func createQueueLock(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.WriteString(strconv.Itoa(os.Getpid()) + "\n"); err != nil {
+		_ = f.Close()
+		_ = os.Remove(path)
+		return nil, err
+	}
+	return f, nil
+}
+
+// isStaleQueueLock 函数判断锁文件是否过期，只有能读出 PID 并且确认对应的进程已经不存在时才是过期的
+// The isStaleQueueLock function reports whether the lock file is stale, which is only the case when a PID can be read and its process is known to be gone
+func isStaleQueueLock(path string) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	pid, err := strconv.Atoi(string(bytes.TrimSpace(data)))
+	if err != nil || pid <= 0 || pid == os.Getpid() {
+		return false
+	}
+
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	defer p.Release()
+
+	err = p.Signal(syscall.Signal(0))
+	return errors.Is(err, os.ErrProcessDone) || errors.Is(err, syscall.ESRCH)
+}
+
+// unlockQueueDir 函数关闭并删除锁文件
+// The unlockQueueDir function closes and removes the lock file
+func unlockQueueDir(f *os.File) error {
+	err := f.Close()
+	if removeErr := os.Remove(f.Name()); err == nil {
+		err = removeErr
+	}
+	return err
+}
@@ -0,0 +1,34 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package retry
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// lockQueueDir 函数使用 flock 锁定队列目录中的锁文件，进程退出时操作系统会释放锁，已经被锁定时返回 ErrorQueueLocked
+// The lockQueueDir function locks the lock file in the queue directory with flock, the operating system releases the lock when the process exits, and it returns ErrorQueueLocked when already locked
+func lockQueueDir(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("retry queue %s: %w", path, err)
+	}
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		_ = f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, fmt.Errorf("retry queue %s: %w", path, ErrorQueueLocked)
+		}
+		return nil, fmt.Errorf("retry queue %s: %w", path, err)
+	}
+	return f, nil
+}
+
+// unlockQueueDir 函数释放锁文件，关闭文件时 flock 随之释放
+// The unlockQueueDir function releases the lock file, closing the file releases the flock
+func unlockQueueDir(f *os.File) error {
+	return f.Close()
+}
@@ -0,0 +1,44 @@
+//go:build windows
+
+package retry
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	lockfileFailImmediately = 0x1  // LOCKFILE_FAIL_IMMEDIATELY
+	lockfileExclusiveLock   = 0x2  // LOCKFILE_EXCLUSIVE_LOCK
+	errorLockViolation      = 0x21 // ERROR_LOCK_VIOLATION
+)
+
+var procLockFileEx = syscall.NewLazyDLL("kernel32.dll").NewProc("LockFileEx")
+
+// lockQueueDir 函数使用 LockFileEx 锁定队列目录中的锁文件，进程退出时操作系统会释放锁，已经被锁定时返回 ErrorQueueLocked
+// The lockQueueDir function locks the lock file in the queue directory with LockFileEx, the operating system releases the lock when the process exits, and it returns ErrorQueueLocked when already locked
+func lockQueueDir(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("retry queue %s: %w", path, err)
+	}
+
+	ol := new(syscall.Overlapped)
+	r1, _, errno := procLockFileEx.Call(f.Fd(), lockfileExclusiveLock|lockfileFailImmediately, 0, 1, 0, uintptr(unsafe.Pointer(ol)))
+	if r1 == 0 {
+		_ = f.Close()
+		if errno == syscall.Errno(errorLockViolation) {
+			return nil, fmt.Errorf("retry queue %s: %w", path, ErrorQueueLocked)
+		}
+		return nil, fmt.Errorf("retry queue %s: %w", path, errno)
+	}
+	return f, nil
+}
+
+// unlockQueueDir 函数释放锁文件，关闭文件时锁随之释放
+// The unlockQueueDir function releases the lock file, closing the file releases the lock
+func unlockQueueDir(f *os.File) error {
+	return f.Close()
+}
@@ -0,0 +1,426 @@
+package retry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newQueueConfig() *Config {
+	return NewConfig().
+		WithInitDelay(time.Millisecond).
+		WithBackOffFunc(func(int64) time.Duration { return 0 })
+}
+
+// runQueue 函数运行队列直到 ready 返回 true，然后停止队列
+// The runQueue function runs the queue until ready returns true, then stops it
+func runQueue(t *testing.T, q *Queue, ready func() bool) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- q.Run(ctx, 2) }()
+
+	assert.Eventually(t, ready, 2*time.Second, time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		assert.Equal(t, context.Canceled, err)
+	case <-time.After(time.Second):
+		t.Fatal("queue did not stop")
+	}
+}
+
+func TestQueue_Success(t *testing.T) {
+	q, err := OpenQueue(t.TempDir())
+	assert.NoError(t, err)
+	defer q.Close()
+
+	var (
+		mu       sync.Mutex
+		payloads []string
+	)
+	q.Handle("webhook", newQueueConfig(), func(ctx context.Context, job Job) error {
+		mu.Lock()
+		defer mu.Unlock()
+		payloads = append(payloads, string(job.Payload))
+		return nil
+	})
+
+	_, err = q.Enqueue("webhook", []byte("hello"))
+	assert.NoError(t, err)
+	assert.Len(t, q.Jobs(), 1)
+
+	runQueue(t, q, func() bool { return len(q.Jobs()) == 0 })
+	assert.Equal(t, []string{"hello"}, payloads)
+	assert.Empty(t, q.DeadLetters())
+}
+
+func TestQueue_DeadLetterAndRequeue(t *testing.T) {
+	q, err := OpenQueue(t.TempDir())
+	assert.NoError(t, err)
+	defer q.Close()
+
+	cb := &delayCallback{}
+	var (
+		mu   sync.Mutex
+		fail = true
+	)
+	q.Handle("webhook", newQueueConfig().WithAttempts(3).WithCallback(cb), func(ctx context.Context, job Job) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if fail {
+			return errors.New("503 service unavailable")
+		}
+		return nil
+	})
+
+	id, err := q.Enqueue("webhook", []byte("hello"))
+	assert.NoError(t, err)
+
+	runQueue(t, q, func() bool { return len(q.DeadLetters()) == 1 })
+	dead := q.DeadLetters()[0]
+	assert.Equal(t, id, dead.ID)
+	assert.Equal(t, int64(3), dead.Attempts)
+	assert.Len(t, dead.Errors, 3)
+	assert.Equal(t, "503 service unavailable", dead.Errors[2].Error)
+	assert.Equal(t, int64(3), dead.Errors[2].Attempt)
+	assert.Len(t, cb.delays, 2)
+	assert.Empty(t, q.Jobs())
+
+	// 放回队列后清零失败的次数，保留错误记录
+	// Requeueing resets the failure count and keeps the error history
+	mu.Lock()
+	fail = false
+	mu.Unlock()
+	assert.NoError(t, q.Requeue(id))
+	assert.Empty(t, q.DeadLetters())
+	jobs := q.Jobs()
+	assert.Len(t, jobs, 1)
+	assert.Equal(t, int64(0), jobs[0].Attempts)
+	assert.Len(t, jobs[0].Errors, 3)
+
+	runQueue(t, q, func() bool { return len(q.Jobs()) == 0 })
+	assert.ErrorIs(t, q.Requeue(id), ErrorJobNotFound)
+}
+
+func TestQueue_FatalError(t *testing.T) {
+	errFatal := errors.New("400 bad request")
+	q, err := OpenQueue(t.TempDir())
+	assert.NoError(t, err)
+	defer q.Close()
+
+	q.Handle("webhook", newQueueConfig().WithAttempts(5).WithRetryIfFunc(func(err error) bool { return !errors.Is(err, errFatal) }),
+		func(ctx context.Context, job Job) error { return errFatal })
+
+	_, err = q.Enqueue("webhook", nil)
+	assert.NoError(t, err)
+
+	runQueue(t, q, func() bool { return len(q.DeadLetters()) == 1 })
+	assert.Equal(t, int64(1), q.DeadLetters()[0].Attempts)
+}
+
+func TestQueue_StatefulBackoff(t *testing.T) {
+	q, err := OpenQueue(t.TempDir())
+	assert.NoError(t, err)
+	defer q.Close()
+
+	var (
+		mu       sync.Mutex
+		backoffs []*recordingBackoff
+	)
+	factory := func() Backoff {
+		mu.Lock()
+		defer mu.Unlock()
+		bo := &recordingBackoff{}
+		backoffs = append(backoffs, bo)
+		return bo
+	}
+	q.Handle("webhook", newQueueConfig().WithAttempts(3).WithBackoffFactory(factory),
+		func(ctx context.Context, job Job) error { return errors.New("timeout") })
+
+	_, err = q.Enqueue("webhook", nil)
+	assert.NoError(t, err)
+
+	// 每次失败都在新的退避策略上重放之前的执行次数
+	// Every failure replays the earlier execution counts on a new backoff
+	runQueue(t, q, func() bool { return len(q.DeadLetters()) == 1 })
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Len(t, backoffs, 2)
+	assert.Equal(t, []int64{1}, backoffs[0].attempts)
+	assert.Equal(t, []int64{1, 2}, backoffs[1].attempts)
+}
+
+func TestQueue_AttemptsByError(t *testing.T) {
+	errBusy := errors.New("busy")
+	q, err := OpenQueue(t.TempDir())
+	assert.NoError(t, err)
+	defer q.Close()
+
+	q.Handle("busy", newQueueConfig().WithAttempts(10).WithAttemptsByError(map[error]uint64{errBusy: 1}),
+		func(ctx context.Context, job Job) error { return errBusy })
+	q.Handle("reset", newQueueConfig().WithAttempts(10).WithAttemptsByErrorCode(map[string]uint64{"ECONNRESET": 2}),
+		func(ctx context.Context, job Job) error { return &codeError{code: "ECONNRESET"} })
+
+	busy, err := q.Enqueue("busy", nil)
+	assert.NoError(t, err)
+	_, err = q.Enqueue("reset", nil)
+	assert.NoError(t, err)
+
+	// 按错误和错误码的失败次数在多次执行之间累计
+	// Failures by error and by error code accumulate across executions
+	runQueue(t, q, func() bool { return len(q.DeadLetters()) == 2 })
+	for _, job := range q.DeadLetters() {
+		if job.ID == busy {
+			assert.Equal(t, int64(2), job.Attempts)
+		} else {
+			assert.Equal(t, int64(3), job.Attempts)
+			assert.Equal(t, "ECONNRESET", job.Errors[0].Code)
+		}
+	}
+
+	// 放回队列后重新计算
+	// The count starts over after a requeue
+	assert.NoError(t, q.Requeue(busy))
+	runQueue(t, q, func() bool { return len(q.DeadLetters()) == 2 })
+	for _, job := range q.DeadLetters() {
+		if job.ID == busy {
+			assert.Equal(t, int64(2), job.Attempts)
+			assert.Len(t, job.Errors, 4)
+		}
+	}
+}
+
+func TestQueue_Persistence(t *testing.T) {
+	dir := t.TempDir()
+	q, err := OpenQueue(dir)
+	assert.NoError(t, err)
+
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	q.now = func() time.Time { return now }
+
+	q.Handle("webhook", newQueueConfig().WithAttempts(2).WithInitDelay(time.Hour).WithMaxDelay(time.Hour),
+		func(ctx context.Context, job Job) error { return errors.New("timeout") })
+
+	deadID, err := q.Enqueue("webhook", []byte("dead"))
+	assert.NoError(t, err)
+
+	// 两次失败后进入死信存储
+	// It moves to the dead-letter store after two failures
+	runQueue(t, q, func() bool { return len(q.Jobs()) == 1 && q.Jobs()[0].Attempts == 1 })
+	now = now.Add(time.Hour)
+	runQueue(t, q, func() bool { return len(q.DeadLetters()) == 1 })
+
+	pendingID, err := q.Enqueue("email", []byte("pending"))
+	assert.NoError(t, err)
+	assert.NoError(t, q.Close())
+	assert.NoError(t, q.Close())
+
+	// 重新打开后恢复等待的任务和死信
+	// Pending jobs and dead letters are restored after reopening
+	q, err = OpenQueue(dir)
+	assert.NoError(t, err)
+	defer q.Close()
+
+	jobs := q.Jobs()
+	assert.Len(t, jobs, 1)
+	assert.Equal(t, pendingID, jobs[0].ID)
+	assert.Equal(t, "email", jobs[0].Type)
+	assert.Equal(t, []byte("pending"), jobs[0].Payload)
+	assert.True(t, jobs[0].NextAttempt.Equal(now))
+
+	dead := q.DeadLetters()
+	assert.Len(t, dead, 1)
+	assert.Equal(t, deadID, dead[0].ID)
+	assert.Len(t, dead[0].Errors, 2)
+
+	assert.NoError(t, q.Discard(deadID))
+	assert.Empty(t, q.DeadLetters())
+	assert.ErrorIs(t, q.Discard(deadID), ErrorJobNotFound)
+}
+
+func TestQueue_Journal(t *testing.T) {
+	dir := t.TempDir()
+	q, err := OpenQueue(dir)
+	assert.NoError(t, err)
+	id, err := q.Enqueue("webhook", []byte("hello"))
+	assert.NoError(t, err)
+	assert.NoError(t, q.Close())
+
+	// 写入时崩溃留下的不完整的一行被忽略
+	// An incomplete line left by a crash while writing is ignored
+	path := filepath.Join(dir, queueJournalName)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	assert.NoError(t, err)
+	_, err = f.WriteString(`{"op":"delete","id":"` + id)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	q, err = OpenQueue(dir)
+	assert.NoError(t, err)
+	assert.Len(t, q.Jobs(), 1)
+	assert.NoError(t, q.Close())
+
+	// 中间损坏的一行返回错误
+	// A corrupt line in the middle returns an error
+	assert.NoError(t, os.WriteFile(path, []byte("not json\n"), 0o644))
+	_, err = OpenQueue(dir)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "line 1")
+}
+
+// faultyJournal 在写入一半的数据后返回错误，模拟磁盘写满
+// faultyJournal returns an error after writing half the data, simulating a full disk
+type faultyJournal struct {
+	*os.File
+	truncateErr error
+}
+
+func (f *faultyJournal) Write(p []byte) (int, error) {
+	n, _ := f.File.Write(p[:len(p)/2])
+	return n, errors.New("no space left on device")
+}
+
+func (f *faultyJournal) Truncate(size int64) error {
+	if f.truncateErr != nil {
+		return f.truncateErr
+	}
+	return f.File.Truncate(size)
+}
+
+func TestQueue_PartialWrite(t *testing.T) {
+	dir := t.TempDir()
+	q, err := OpenQueue(dir)
+	assert.NoError(t, err)
+
+	first, err := q.Enqueue("webhook", []byte("first"))
+	assert.NoError(t, err)
+
+	// 失败的写入被截断，之后的记录仍然可以重放
+	// The failed write is truncated, so the records after it still replay
+	file := q.file.(*os.File)
+	q.file = &faultyJournal{File: file}
+	_, err = q.Enqueue("webhook", []byte("lost"))
+	assert.Error(t, err)
+	assert.Len(t, q.Jobs(), 1)
+
+	q.file = file
+	third, err := q.Enqueue("webhook", []byte("third"))
+	assert.NoError(t, err)
+	assert.NoError(t, q.Close())
+
+	q, err = OpenQueue(dir)
+	assert.NoError(t, err)
+	jobs := q.Jobs()
+	assert.Len(t, jobs, 2)
+	assert.ElementsMatch(t, []string{first, third}, []string{jobs[0].ID, jobs[1].ID})
+
+	// 截断也失败时队列不再接受写入
+	// When truncating fails too the queue refuses further writes
+	file = q.file.(*os.File)
+	q.file = &faultyJournal{File: file, truncateErr: errors.New("read-only file system")}
+	_, err = q.Enqueue("webhook", nil)
+	assert.Error(t, err)
+
+	q.file = file
+	_, err = q.Enqueue("webhook", nil)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "read-only file system")
+	assert.NoError(t, q.Close())
+}
+
+func TestQueue_Lock(t *testing.T) {
+	dir := t.TempDir()
+	q, err := OpenQueue(dir)
+	assert.NoError(t, err)
+
+	// 同一个目录同一时间只能被打开一次
+	// A directory can only be opened once at a time
+	_, err = OpenQueue(dir)
+	assert.ErrorIs(t, err, ErrorQueueLocked)
+
+	assert.NoError(t, q.Close())
+	q, err = OpenQueue(dir)
+	assert.NoError(t, err)
+	assert.NoError(t, q.Close())
+}
+
+func TestQueue_RuntimeCompaction(t *testing.T) {
+	dir := t.TempDir()
+	q, err := OpenQueue(dir)
+	assert.NoError(t, err)
+	q.compactAt = 8
+
+	kept, err := q.Enqueue("webhook", []byte("kept"))
+	assert.NoError(t, err)
+	for i := 0; i < 50; i++ {
+		id, err := q.Enqueue("webhook", nil)
+		assert.NoError(t, err)
+		q.mu.Lock()
+		assert.NoError(t, q.append(&journalRecord{Op: journalDelete, ID: id}))
+		q.mu.Unlock()
+	}
+
+	// 运行中压缩让日志的长度保持在阈值附近
+	// Compacting at runtime keeps the journal near the threshold
+	data, err := os.ReadFile(filepath.Join(dir, queueJournalName))
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, bytes.Count(data, []byte("\n")), 8)
+
+	_, err = q.Enqueue("webhook", []byte("last"))
+	assert.NoError(t, err)
+	assert.NoError(t, q.Close())
+
+	q, err = OpenQueue(dir)
+	assert.NoError(t, err)
+	defer q.Close()
+	jobs := q.Jobs()
+	assert.Len(t, jobs, 2)
+	assert.Equal(t, kept, jobs[0].ID)
+	assert.Equal(t, []byte("last"), jobs[1].Payload)
+}
+
+func TestQueue_UnhandledType(t *testing.T) {
+	q, err := OpenQueue(t.TempDir())
+	assert.NoError(t, err)
+	defer q.Close()
+
+	done := make(chan struct{})
+	q.Handle("webhook", newQueueConfig(), func(ctx context.Context, job Job) error {
+		close(done)
+		return nil
+	})
+
+	_, err = q.Enqueue("email", nil)
+	assert.NoError(t, err)
+	_, err = q.Enqueue("webhook", nil)
+	assert.NoError(t, err)
+
+	runQueue(t, q, func() bool {
+		select {
+		case <-done:
+			return len(q.Jobs()) == 1
+		default:
+			return false
+		}
+	})
+	assert.Equal(t, "email", q.Jobs()[0].Type)
+}
+
+func TestQueue_Closed(t *testing.T) {
+	q, err := OpenQueue(t.TempDir())
+	assert.NoError(t, err)
+	assert.NoError(t, q.Close())
+
+	_, err = q.Enqueue("webhook", nil)
+	assert.ErrorIs(t, err, ErrorQueueClosed)
+	assert.ErrorIs(t, q.Run(context.Background(), 1), ErrorQueueClosed)
+	assert.ErrorIs(t, q.Requeue("x"), ErrorQueueClosed)
+}
@@ -0,0 +1,111 @@
+package retry
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// seedCounter 用于为每个随机数生成器生成不同的种子
+	// seedCounter is used to generate a different seed for each random number generator
+	seedCounter uint64
+
+	// randPool 缓存包级别使用的随机数生成器，每个 P 都可以无锁地获取一个独立的实例
+	// randPool caches the random number generators used at package level, each P can get its own instance without locking
+	randPool = sync.Pool{
+		New: func() any {
+			return newRand(newSeed())
+		},
+	}
+)
+
+// splitMix64 是一个轻量的 rand.Source64 实现，状态只有 8 个字节，创建和使用都不需要加锁
+// splitMix64 is a lightweight rand.Source64 implementation with only 8 bytes of state, it needs no locking to create or use
+type splitMix64 struct {
+	state uint64
+}
+
+// Seed 方法设置随机数生成器的种子
+// The Seed method sets the seed of the random number generator
+func (s *splitMix64) Seed(seed int64) {
+	s.state = uint64(seed)
+}
+
+// Uint64 方法返回下一个 64 位随机数
+// The Uint64 method returns the next 64-bit random number
+func (s *splitMix64) Uint64() uint64 {
+	s.state += 0x9e3779b97f4a7c15
+	z := s.state
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+// Int63 方法返回下一个非负的 63 位随机数
+// The Int63 method returns the next non-negative 63-bit random number
+func (s *splitMix64) Int63() int64 {
+	return int64(s.Uint64() >> 1)
+}
+
+// newRand 函数使用指定的种子创建一个新的随机数生成器，返回的实例不是并发安全的
+// The newRand function creates a new random number generator with the specified seed, the returned instance is not safe for concurrent use
+func newRand(seed int64) *rand.Rand {
+	return rand.New(&splitMix64{state: uint64(seed)})
+}
+
+// newSeed 函数返回一个新的随机种子，每次调用的结果都不相同
+// The newSeed function returns a new random seed, which is different for every call
+func newSeed() int64 {
+	return time.Now().UnixNano() ^ int64(atomic.AddUint64(&seedCounter, 0x9e3779b97f4a7c15))
+}
+
+// randInt63n 从随机数生成器池中返回 [0, n) 范围内的随机数，n 小于等于 0 时返回 0
+// randInt63n returns a random number in [0, n) from the generator pool, or 0 when n is less than or equal to 0
+func randInt63n(n int64) int64 {
+	if n <= 0 {
+		return 0
+	}
+
+	rng := randPool.Get().(*rand.Rand)
+	v := rng.Int63n(n)
+	randPool.Put(rng)
+
+	return v
+}
+
+// rngInt63n 使用指定的随机数生成器返回 [0, n) 范围内的随机数，rng 为 nil 时使用随机数生成器池
+// rngInt63n returns a random number in [0, n) using the specified generator, falling back to the generator pool when rng is nil
+func rngInt63n(rng *rand.Rand, n int64) int64 {
+	if rng == nil {
+		return randInt63n(n)
+	}
+	if n <= 0 {
+		return 0
+	}
+	return rng.Int63n(n)
+}
+
+// rngDuration 使用指定的随机数生成器返回 [0, d] 范围内的随机时间，rng 为 nil 时使用随机数生成器池
+// rngDuration returns a random duration in [0, d] using the specified generator, falling back to the generator pool when rng is nil
+func rngDuration(rng *rand.Rand, d time.Duration) time.Duration {
+	// 随机范围包含 d，d 为 MaxInt64 时不能再加一，否则溢出为负数，结果总是 0
+	// The random range includes d, which cannot be widened by one when d is MaxInt64, otherwise it overflows to a negative number and the result is always 0
+	n := int64(d)
+	if n < math.MaxInt64 {
+		n++
+	}
+	return time.Duration(rngInt63n(rng, n))
+}
+
+// rngFloat64 使用指定的随机数生成器返回 [0, 1) 范围内的随机数，rng 为 nil 时使用随机数生成器池
+// rngFloat64 returns a random number in [0, 1) using the specified generator, falling back to the generator pool when rng is nil
+func rngFloat64(rng *rand.Rand) float64 {
+	if rng == nil {
+		rng = randPool.Get().(*rand.Rand)
+		defer randPool.Put(rng)
+	}
+	return rng.Float64()
+}
@@ -0,0 +1,115 @@
+package retry
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewRand(t *testing.T) {
+	r1 := newRand(42)
+	r2 := newRand(42)
+	for i := 0; i < 100; i++ {
+		assert.Equal(t, r1.Int63(), r2.Int63())
+	}
+
+	r3 := newRand(43)
+	assert.NotEqual(t, newRand(42).Int63(), r3.Int63())
+}
+
+func TestNewSeed(t *testing.T) {
+	seeds := make(map[int64]struct{})
+	for i := 0; i < 1000; i++ {
+		seeds[newSeed()] = struct{}{}
+	}
+	assert.Len(t, seeds, 1000)
+}
+
+func TestRandInt63n(t *testing.T) {
+	assert.Equal(t, int64(0), randInt63n(0))
+	assert.Equal(t, int64(0), randInt63n(-1))
+	assert.Equal(t, int64(0), rngInt63n(newRand(1), 0))
+
+	for i := 0; i < 1000; i++ {
+		v := randInt63n(10)
+		assert.GreaterOrEqual(t, v, int64(0))
+		assert.Less(t, v, int64(10))
+
+		f := rngFloat64(nil)
+		assert.GreaterOrEqual(t, f, 0.0)
+		assert.Less(t, f, 1.0)
+	}
+}
+
+func TestNewRandomBackoff(t *testing.T) {
+	b1 := NewRandomBackoff(newRand(7))
+	b2 := NewRandomBackoff(newRand(7))
+	for i := 0; i < 100; i++ {
+		assert.Equal(t, b1(10), b2(10))
+	}
+	assert.Equal(t, defaultDelay, b1(0))
+}
+
+func TestRetry_Seed(t *testing.T) {
+	run := func(r *Retry) []time.Duration {
+		bo := r.newBackoff()
+		delays := make([]time.Duration, 0, 10)
+		for attempt := int64(1); attempt <= 10; attempt++ {
+			delay, ok := r.nextDelay(bo, attempt, nil)
+			assert.True(t, ok)
+			delays = append(delays, delay)
+		}
+		return delays
+	}
+
+	r := New(NewConfig().WithSeed(2024))
+	first := run(r)
+	assert.Equal(t, first, run(r))
+	assert.Equal(t, first, run(New(NewConfig().WithSeed(2024))))
+
+	r = New(NewConfig().WithSeed(2024).WithBackoffFactory(NewDecorrelatedJitterFactory(time.Millisecond, time.Second)))
+	assert.Equal(t, run(r), run(r))
+}
+
+func BenchmarkLockedRandParallel(b *testing.B) {
+	var mu sync.Mutex
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			mu.Lock()
+			_ = rng.Int63n(100)
+			mu.Unlock()
+		}
+	})
+}
+
+func BenchmarkRandInt63nParallel(b *testing.B) {
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_ = randInt63n(100)
+		}
+	})
+}
+
+func BenchmarkRandomBackoffParallel(b *testing.B) {
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_ = RandomBackoff(5)
+		}
+	})
+}
+
+func BenchmarkRetryNextDelayParallel(b *testing.B) {
+	r := New(NewConfig())
+	b.RunParallel(func(pb *testing.PB) {
+		bo := r.newBackoff()
+		var attempt int64
+		for pb.Next() {
+			attempt = attempt%10 + 1
+			_, _ = r.nextDelay(bo, attempt, nil)
+		}
+	})
+}
@@ -0,0 +1,172 @@
+package retry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+)
+
+// errReaderClosed 表示从已经关闭的 ResumableReader 读取
+// errReaderClosed represents a read from a closed ResumableReader
+var errReaderClosed = errors.New("retry: read from closed reader")
+
+// ReopenFunc 类型定义了从指定偏移量重新打开数据流的函数类型，例如带 Range 头的 HTTP 请求
+// The ReopenFunc type defines the function type that reopens a stream at the given offset, such as an HTTP request with a Range header
+type ReopenFunc = func(ctx context.Context, offset int64) (io.ReadCloser, error)
+
+// ResumableReader 结构体是一个可以断点续传的 io.ReadCloser，读取出错时从已经交付的最后一个字节之后重新打开数据流
+// 每次 Read 调用有自己的重试次数，读取到数据之后重新计算，上下文的错误不会重试，它不能被多个 goroutine 并发使用
+// The ResumableReader struct is a resumable io.ReadCloser that reopens the stream right after the last delivered byte when a read fails
+// Every Read call has its own attempts, which start over once data is read, context errors are not retried, and it must not be used by multiple goroutines concurrently
+type ResumableReader struct {
+	ctx      context.Context
+	reopen   ReopenFunc
+	retry    *Retry
+	body     io.ReadCloser
+	offset   int64
+	hash     hash.Hash
+	expected []byte
+	err      error // 之后的 Read 调用都会返回的错误 Error returned by every later Read call
+}
+
+// NewResumableReader 函数创建一个新的 ResumableReader 实例，第一次 Read 时从偏移量 0 打开数据流
+// conf 为空时使用默认配置，第一次打开总是立即进行，配置会被复制
+// The NewResumableReader function creates a new ResumableReader instance that opens the stream at offset 0 on the first Read
+// The default configuration is used when conf is nil, the first open always happens immediately and the configuration is copied
+func NewResumableReader(ctx context.Context, reopen ReopenFunc, conf *Config) *ResumableReader {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	return &ResumableReader{
+		ctx:    ctx,
+		reopen: reopen,
+		retry: newImmediateRetry(conf, func(err error) bool {
+			return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+		}),
+	}
+}
+
+// WithChecksum 方法设置校验拼接后的数据流的哈希和期望的校验和并返回 ResumableReader 实例，读取到末尾时校验和不一致会返回 ErrorChecksumMismatch
+// The WithChecksum method sets the hash that verifies the stitched stream and the expected checksum and returns the ResumableReader instance, ErrorChecksumMismatch is returned at the end of the stream when they do not match
+func (r *ResumableReader) WithChecksum(h hash.Hash, expected []byte) *ResumableReader {
+	r.hash = h
+	r.expected = append([]byte(nil), expected...)
+	return r
+}
+
+// Offset 方法返回已经交付给调用者的字节数
+// The Offset method returns the number of bytes delivered to the caller
+func (r *ResumableReader) Offset() int64 {
+	return r.offset
+}
+
+// Read 方法读取数据，出错时关闭当前的数据流并从 Offset 重新打开，已经读取到的数据会先交付给调用者
+// The Read method reads data, closing the current stream and reopening it at Offset when a read fails, data already read is delivered to the caller first
+func (r *ResumableReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	var (
+		n       int
+		eof     bool
+		lastErr error
+	)
+
+	result := r.retry.TryOnConflictContext(r.ctx, func() (any, error) {
+		if r.body == nil {
+			body, err := r.reopen(r.ctx, r.offset)
+			if err != nil {
+				lastErr = err
+				return nil, err
+			}
+			r.body = body
+		}
+
+		m, err := r.body.Read(p)
+		if m > 0 {
+			n = m
+			r.offset += int64(m)
+			if r.hash != nil {
+				_, _ = r.hash.Write(p[:m])
+			}
+		}
+
+		switch {
+		case err == io.EOF:
+			eof = true
+		case err != nil:
+			// 数据流已经不可用，下一次读取时重新打开，已经读取到的数据先交付给调用者
+			// The stream is unusable and is reopened on the next read, data already read is delivered to the caller first
+			r.closeBody()
+			if m == 0 {
+				lastErr = err
+				return nil, err
+			}
+		}
+
+		return nil, nil
+	})
+
+	if !result.IsSuccess() {
+		r.closeBody()
+		if err := r.ctx.Err(); err != nil {
+			r.err = err
+		} else if lastErr != nil {
+			r.err = lastErr
+		} else {
+			r.err = result.TryError()
+		}
+		return 0, r.err
+	}
+
+	if eof {
+		r.closeBody()
+		r.err = r.verify()
+		return n, r.err
+	}
+
+	return n, nil
+}
+
+// verify 方法在数据流结束时检查校验和，一致时返回 io.EOF
+// The verify method checks the checksum at the end of the stream, returning io.EOF when it matches
+func (r *ResumableReader) verify() error {
+	if r.hash == nil {
+		return io.EOF
+	}
+	if sum := r.hash.Sum(nil); !bytes.Equal(sum, r.expected) {
+		return fmt.Errorf("%w: got %x, want %x", ErrorChecksumMismatch, sum, r.expected)
+	}
+	return io.EOF
+}
+
+// closeBody 方法关闭当前的数据流
+// The closeBody method closes the current stream
+func (r *ResumableReader) closeBody() {
+	if r.body != nil {
+		_ = r.body.Close()
+		r.body = nil
+	}
+}
+
+// Close 方法关闭当前的数据流，之后的 Read 调用会返回错误
+// The Close method closes the current stream, later Read calls return an error
+func (r *ResumableReader) Close() error {
+	var err error
+	if r.body != nil {
+		err = r.body.Close()
+		r.body = nil
+	}
+	if r.err == nil || r.err == io.EOF {
+		r.err = errReaderClosed
+	}
+	return err
+}
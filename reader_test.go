@@ -0,0 +1,149 @@
+package retry
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// flakySource 结构体模拟一个每次连接只能读取有限字节数的数据源
+// The flakySource struct simulates a source that only serves a limited number of bytes per connection
+type flakySource struct {
+	data    []byte
+	limit   int     // 每次连接最多读取的字节数 Maximum bytes served per connection
+	offsets []int64 // 每次打开时的偏移量 Offset of every open
+	openErr []error // 按顺序返回的打开错误 Open errors returned in order
+	closed  int
+}
+
+func (s *flakySource) open(_ context.Context, offset int64) (io.ReadCloser, error) {
+	s.offsets = append(s.offsets, offset)
+	if len(s.openErr) > 0 {
+		err := s.openErr[0]
+		s.openErr = s.openErr[1:]
+		return nil, err
+	}
+	return &flakyBody{source: s, data: s.data[offset:], limit: s.limit}, nil
+}
+
+type flakyBody struct {
+	source *flakySource
+	data   []byte
+	limit  int
+}
+
+func (b *flakyBody) Read(p []byte) (int, error) {
+	if len(b.data) == 0 {
+		return 0, io.EOF
+	}
+	if b.limit == 0 {
+		return 0, errors.New("connection reset by peer")
+	}
+	n := len(p)
+	if n > b.limit {
+		n = b.limit
+	}
+	n = copy(p[:n], b.data)
+	b.data = b.data[n:]
+	b.limit -= n
+	return n, nil
+}
+
+func (b *flakyBody) Close() error {
+	b.source.closed++
+	return nil
+}
+
+func newTestReaderConfig() *Config {
+	return NewConfig().
+		WithInitDelay(time.Millisecond).
+		WithBackOffFunc(func(int64) time.Duration { return 0 })
+}
+
+func TestResumableReader(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 100)
+	source := &flakySource{data: data, limit: 300}
+
+	r := NewResumableReader(context.Background(), source.open, newTestReaderConfig())
+	got, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, data, got)
+	assert.Equal(t, int64(len(data)), r.Offset())
+
+	// 每次中断后从已经交付的最后一个字节之后继续
+	// Every interruption resumes right after the last delivered byte
+	assert.Equal(t, []int64{0, 300, 600, 900}, source.offsets)
+	assert.Equal(t, 4, source.closed)
+
+	assert.NoError(t, r.Close())
+	_, err = r.Read(make([]byte, 1))
+	assert.Error(t, err)
+}
+
+func TestResumableReader_Checksum(t *testing.T) {
+	data := bytes.Repeat([]byte("abcdef"), 500)
+	sum := sha256.Sum256(data)
+
+	source := &flakySource{data: data, limit: 1000}
+	r := NewResumableReader(context.Background(), source.open, newTestReaderConfig()).WithChecksum(sha256.New(), sum[:])
+	got, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, data, got)
+
+	source = &flakySource{data: data, limit: 1000}
+	r = NewResumableReader(context.Background(), source.open, newTestReaderConfig()).WithChecksum(sha256.New(), []byte("wrong"))
+	_, err = io.ReadAll(r)
+	assert.True(t, errors.Is(err, ErrorChecksumMismatch))
+}
+
+func TestResumableReader_OpenRetry(t *testing.T) {
+	data := []byte("hello world")
+	e := errors.New("503 service unavailable")
+	source := &flakySource{data: data, limit: 100, openErr: []error{e, e}}
+
+	r := NewResumableReader(context.Background(), source.open, newTestReaderConfig().WithAttempts(3))
+	got, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, data, got)
+
+	// 重试次数用完时返回最后一次的错误，之后的 Read 调用返回同样的错误
+	// The last error is returned when the attempts run out, and later Read calls return the same error
+	source = &flakySource{data: data, limit: 100, openErr: []error{e, e, e}}
+	r = NewResumableReader(context.Background(), source.open, newTestReaderConfig().WithAttempts(3))
+	n, err := r.Read(make([]byte, 10))
+	assert.Equal(t, 0, n)
+	assert.Equal(t, e, err)
+	_, err = r.Read(make([]byte, 10))
+	assert.Equal(t, e, err)
+	assert.Len(t, source.offsets, 3)
+}
+
+func TestResumableReader_AttemptsPerRead(t *testing.T) {
+	// 每次连接只读取一个字节，但每次读取都有进展，因此不会用完重试次数
+	// Every connection serves only one byte, but every read makes progress, so the attempts never run out
+	data := []byte("resumable")
+	source := &flakySource{data: data, limit: 1}
+
+	r := NewResumableReader(context.Background(), source.open, newTestReaderConfig().WithAttempts(2))
+	got, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, data, got)
+	assert.Len(t, source.offsets, len(data))
+}
+
+func TestResumableReader_ContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	source := &flakySource{data: []byte("hello"), limit: 100}
+
+	r := NewResumableReader(ctx, source.open, newTestReaderConfig())
+	cancel()
+
+	_, err := r.Read(make([]byte, 10))
+	assert.Equal(t, context.Canceled, err)
+}
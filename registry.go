@@ -0,0 +1,174 @@
+package retry
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// 预先注册的策略名称
+// Names of the pre-registered policies
+const (
+	PolicyDefault = "default" // DefaultConfig 对应的策略 Policy for DefaultConfig
+	PolicyFix     = "fix"     // FixConfig 对应的策略 Policy for FixConfig
+)
+
+// policy 结构体保存注册的配置模板和根据它创建的 Retry 实例
+// The policy struct keeps a registered configuration template and the Retry instance created from it
+type policy struct {
+	config *Config
+	retry  *Retry
+}
+
+// Registry 结构体将策略名称映射到配置模板，可以被多个 goroutine 并发使用
+// The Registry struct maps policy names to configuration templates and is safe for concurrent use by multiple goroutines
+type Registry struct {
+	mu       sync.RWMutex
+	policies map[string]*policy
+}
+
+// NewRegistry 函数创建一个新的 Registry 实例，并预先注册 DefaultConfig 和 FixConfig
+// The NewRegistry function creates a new Registry instance with DefaultConfig and FixConfig pre-registered
+func NewRegistry() *Registry {
+	r := &Registry{policies: make(map[string]*policy)}
+	r.MustRegister(PolicyDefault, DefaultConfig())
+	r.MustRegister(PolicyFix, FixConfig())
+	return r
+}
+
+// newPolicy 函数检查配置并创建一个策略，保存的是配置的副本
+// The newPolicy function validates the configuration and creates a policy, keeping a copy of the configuration
+func newPolicy(name string, conf *Config) (*policy, error) {
+	if name == "" {
+		return nil, ErrorPolicyNameEmpty
+	}
+	if conf == nil {
+		conf = NewConfig()
+	}
+	if err := conf.Validate(); err != nil {
+		return nil, fmt.Errorf("retry policy %q: %w", name, err)
+	}
+
+	conf = conf.Clone()
+	return &policy{config: conf, retry: New(conf)}, nil
+}
+
+// Register 方法检查配置并以指定的名称注册，已存在的同名策略会被替换
+// 替换不会影响正在使用旧策略执行的调用
+// The Register method validates the configuration and registers it under the specified name, replacing any existing policy with the same name
+// Replacing does not affect calls already running under the old policy
+func (r *Registry) Register(name string, conf *Config) error {
+	p, err := newPolicy(name, conf)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.policies[name] = p
+	r.mu.Unlock()
+
+	return nil
+}
+
+// swap 方法在一次加锁中注册 set 中的所有策略并删除 remove 中的策略，返回被替换或删除的旧策略
+// The swap method registers every policy in set and removes the policies in remove under a single lock, returning the old policies that were replaced or removed
+func (r *Registry) swap(set map[string]*policy, remove []string) map[string]*policy {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	old := make(map[string]*policy, len(set)+len(remove))
+	for name, p := range set {
+		if prev, ok := r.policies[name]; ok {
+			old[name] = prev
+		}
+		r.policies[name] = p
+	}
+	for _, name := range remove {
+		if prev, ok := r.policies[name]; ok {
+			old[name] = prev
+			delete(r.policies, name)
+		}
+	}
+
+	return old
+}
+
+// MustRegister 方法注册策略，注册失败时 panic
+// The MustRegister method registers the policy and panics if registration fails
+func (r *Registry) MustRegister(name string, conf *Config) {
+	if err := r.Register(name, conf); err != nil {
+		panic(err)
+	}
+}
+
+// Unregister 方法删除指定名称的策略，返回该策略是否存在
+// The Unregister method removes the policy with the specified name and reports whether it existed
+func (r *Registry) Unregister(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, ok := r.policies[name]
+	delete(r.policies, name)
+	return ok
+}
+
+// lookup 方法返回指定名称的策略，不存在时返回 ErrorPolicyNotFound
+// The lookup method returns the policy with the specified name, or ErrorPolicyNotFound if it does not exist
+func (r *Registry) lookup(name string) (*policy, error) {
+	r.mu.RLock()
+	p, ok := r.policies[name]
+	r.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrorPolicyNotFound, name)
+	}
+	return p, nil
+}
+
+// Config 方法返回指定名称的策略配置的副本
+// The Config method returns a copy of the configuration of the named policy
+func (r *Registry) Config(name string) (*Config, error) {
+	p, err := r.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	return p.config.Clone(), nil
+}
+
+// Retry 方法返回指定名称的策略对应的 Retry 实例
+// The Retry method returns the Retry instance of the named policy
+func (r *Registry) Retry(name string) (*Retry, error) {
+	p, err := r.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	return p.retry, nil
+}
+
+// Names 方法返回所有已注册的策略名称，按字母顺序排列
+// The Names method returns the names of all registered policies in alphabetical order
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.policies))
+	for name := range r.policies {
+		names = append(names, name)
+	}
+	r.mu.RUnlock()
+
+	sort.Strings(names)
+	return names
+}
+
+// Do 方法使用指定名称的策略执行 fn 函数
+// 策略不存在时不会执行 fn，返回的结果中 TryError 为包装了 ErrorPolicyNotFound 的错误
+// The Do method executes the fn function under the named policy
+// When the policy does not exist fn is not executed, and the TryError of the returned result wraps ErrorPolicyNotFound
+func (r *Registry) Do(name string, fn RetryableFunc) RetryResult {
+	p, err := r.lookup(name)
+	if err != nil {
+		result := NewResult()
+		result.tryError = err
+		return result
+	}
+	return p.retry.TryOnConflict(fn)
+}
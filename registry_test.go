@@ -0,0 +1,101 @@
+package retry
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry_Defaults(t *testing.T) {
+	r := NewRegistry()
+	assert.Equal(t, []string{PolicyDefault, PolicyFix}, r.Names())
+
+	cfg, err := r.Config(PolicyFix)
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0, cfg.factor)
+	assert.Equal(t, 0.0, cfg.jitter)
+}
+
+func TestRegistry_Register(t *testing.T) {
+	r := NewRegistry()
+
+	cfg := NewConfig().WithInitDelay(time.Millisecond).WithBackOffFunc(func(int64) time.Duration { return 0 }).WithAttempts(2)
+	assert.NoError(t, r.Register("payment-write", cfg))
+	assert.Equal(t, []string{PolicyDefault, PolicyFix, "payment-write"}, r.Names())
+
+	// 注册保存的是配置的副本
+	// Registration keeps a copy of the configuration
+	cfg.WithAttempts(5)
+
+	result := r.Do("payment-write", func() (any, error) {
+		return nil, errors.New("test")
+	})
+	assert.Equal(t, ErrorRetryAttemptsExceeded, result.TryError())
+	assert.Equal(t, int64(2), result.Count())
+
+	registered, err := r.Config("payment-write")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), registered.attempts)
+
+	retry, err := r.Retry("payment-write")
+	assert.NoError(t, err)
+	assert.NotNil(t, retry)
+
+	assert.True(t, r.Unregister("payment-write"))
+	assert.False(t, r.Unregister("payment-write"))
+}
+
+func TestRegistry_Errors(t *testing.T) {
+	r := NewRegistry()
+
+	err := r.Register("bad", NewConfig().WithAttempts(100000))
+	var cerr *ConfigError
+	assert.True(t, errors.As(err, &cerr))
+	assert.Contains(t, err.Error(), `"bad"`)
+
+	assert.ErrorIs(t, r.Register("", NewConfig()), ErrorPolicyNameEmpty)
+	assert.Panics(t, func() { r.MustRegister("", NewConfig()) })
+
+	called := false
+	result := r.Do("idempotent-read", func() (any, error) {
+		called = true
+		return nil, nil
+	})
+	assert.False(t, called)
+	assert.False(t, result.IsSuccess())
+	assert.ErrorIs(t, result.TryError(), ErrorPolicyNotFound)
+	assert.Contains(t, result.TryError().Error(), "idempotent-read")
+
+	_, err = r.Config("idempotent-read")
+	assert.ErrorIs(t, err, ErrorPolicyNotFound)
+	_, err = r.Retry("idempotent-read")
+	assert.ErrorIs(t, err, ErrorPolicyNotFound)
+}
+
+func TestRegistry_Concurrent(t *testing.T) {
+	r := NewRegistry()
+	cfg := NewConfig().WithInitDelay(time.Millisecond)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			assert.NoError(t, r.Register("background-job", cfg))
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			_ = r.Do("background-job", func() (any, error) { return "ok", nil })
+			_ = r.Names()
+		}
+	}()
+
+	wg.Wait()
+}
@@ -1,6 +1,8 @@
 package retry
 
 import (
+	"context"
+	"errors"
 	"math/rand"
 	"time"
 )
@@ -82,21 +84,135 @@ func (r *Result) Count() int64 {
 type RetryableFunc = func() (any, error)
 
 // Retry 结构体用于定义重试的配置
+// Retry 持有创建时配置的快照，之后修改原配置不会影响它；每次调用的状态（退避策略、随机数生成器、按错误的重试次数）都是独立的，
+// 因此同一个 *Retry 可以被多个 goroutine 并发使用，前提是配置中的回调函数、重试条件函数和退避函数本身是并发安全的
 // The Retry struct is used to define the retry configuration
+// A Retry holds a snapshot of the configuration taken at creation, later changes to the original configuration do not affect it; the state of each call (backoff, random generator, retries by error) is independent,
+// so the same *Retry is safe for concurrent use by multiple goroutines, provided the callback, retry condition and backoff functions in the configuration are themselves safe for concurrent use
 type Retry struct {
 	config *Config // 重试的配置 Retry configuration
 }
 
 // New 函数用于创建一个新的 Retry 实例。它接受一个 Config 结构体作为参数，该结构体包含了重试的配置信息。
+// New 会保存配置的一个副本，无效的值只在副本中被替换为默认值，原配置不会被修改。
 // The New function is used to create a new Retry instance. It accepts a Config structure as a parameter, which contains the configuration information for retrying.
+// New keeps a copy of the configuration, invalid values are only replaced with defaults in the copy and the original configuration is never modified.
 func New(conf *Config) *Retry {
-	conf = isConfigValid(conf)
-	return &Retry{config: conf}
+	if conf != nil {
+		conf = conf.Clone()
+	}
+	return &Retry{config: isConfigValid(conf)}
+}
+
+// NewStrict 函数检查配置并创建一个新的 Retry 实例，配置无效时返回列出所有问题的 ConfigError，而不是替换为默认值
+// conf 为 nil 时使用默认配置
+// The NewStrict function validates the configuration and creates a new Retry instance, returning a ConfigError listing every problem instead of substituting defaults when it is invalid
+// The default configuration is used when conf is nil
+func NewStrict(conf *Config) (*Retry, error) {
+	if conf == nil {
+		conf = NewConfig()
+	}
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+	return New(conf), nil
+}
+
+// newBackoff 方法创建一个新的退避策略实例，如果没有设置退避策略工厂函数，则适配配置中的退避函数
+// The newBackoff method creates a new backoff instance, adapting the backoff function of the configuration if no backoff factory is set
+// 如果退避策略实现了 RandomizedBackoff 接口，则为其设置一个本次调用专用的随机数生成器
+// If the backoff implements the RandomizedBackoff interface, a generator dedicated to this call is set on it
+func (r *Retry) newBackoff() Backoff {
+	var bo Backoff
+	if r.config.backoffFactory != nil {
+		bo = r.config.backoffFactory()
+	}
+	if bo == nil {
+		bo = newLegacyBackoff(r.config.backoffFunc, r.config.jitter, r.config.factor, r.config.baseUnit)
+	}
+
+	if rb, ok := bo.(RandomizedBackoff); ok {
+		rb.SetRand(r.newRand())
+	}
+
+	return bo
+}
+
+// newRand 方法为一次调用创建一个新的随机数生成器，如果配置了固定的种子，则使用该种子
+// The newRand method creates a new random number generator for one call, using the fixed seed if one is configured
+func (r *Retry) newRand() *rand.Rand {
+	if r.config.seeded {
+		return newRand(r.config.seed)
+	}
+	return newRand(newSeed())
+}
+
+// newImmediateRetry 函数根据配置的副本创建立即开始第一次执行的 Retry 实例，只有 retryable 和配置中的 RetryIfFunc 都同意时才重试
+// 它用于包装连接、请求等由调用方发起的操作，第一次执行不应该等待初始延迟时间
+// 这些操作的返回值（例如响应和连接）需要由包装自己释放，因此副本中的结果检查函数被清除，被拒绝的返回值不会泄漏
+// The newImmediateRetry function creates a Retry instance from a copy of the configuration whose first execution starts immediately, retrying only when both retryable and the configured RetryIfFunc agree
+// It is used to wrap operations started by callers, such as connections and requests, whose first execution should not wait for the initial delay
+// The values returned by these operations (such as responses and connections) must be released by the wrapper itself, so the result check function is cleared in the copy and rejected values never leak
+func newImmediateRetry(conf *Config, retryable RetryIfFunc) *Retry {
+	if conf == nil {
+		conf = NewConfig()
+	}
+	conf = conf.Clone()
+
+	retryIf := conf.retryIfFunc
+	if retryIf == nil {
+		retryIf = defaultRetryIfFunc
+	}
+
+	conf.WithImmediate(true).WithRetryIfResult(nil).WithRetryIfFunc(func(err error) bool {
+		return retryable(err) && retryIf(err)
+	})
+	return New(conf)
+}
+
+// retryOnResult 方法使用配置中的结果检查函数判断是否需要根据返回值和错误重试，没有配置时返回 false
+// The retryOnResult method uses the configured result check function to report whether a retry is needed based on the returned value and error, returning false when none is configured
+func (r *Retry) retryOnResult(data any, err error) bool {
+	return r.config.retryIfResultFunc != nil && r.config.retryIfResultFunc(data, err)
+}
+
+// nextDelay 方法计算下一次重试前的延迟时间：退避策略的延迟时间加上配置中的延迟时间，并限制在最小和最大延迟时间之间
+// The nextDelay method calculates the delay before the next retry: the backoff delay plus the configured delay, clamped between the minimum and maximum delay
+// 如果错误带有 RetryAfter 提示（例如 HTTP 的 Retry-After 头），延迟时间不会小于该提示，但仍然不会超过最大延迟时间
+// If the error carries a RetryAfter hint (such as the HTTP Retry-After header), the delay is never shorter than the hint, but still never exceeds the maximum delay
+func (r *Retry) nextDelay(bo Backoff, attempt int64, err error) (time.Duration, bool) {
+	next, ok := bo.Next(attempt, err)
+	if !ok {
+		return 0, false
+	}
+
+	delay := r.config.clampDelay(addDelay(next, r.config.delay))
+	if hint, ok := retryAfter(err); ok && hint > delay {
+		delay = r.config.clampDelay(hint)
+	}
+
+	return delay, true
+}
+
+// retryAfter 函数从错误链中获取 RetryAfter 提示
+// The retryAfter function gets the RetryAfter hint from the error chain
+func retryAfter(err error) (time.Duration, bool) {
+	var hint RetryAfterError
+	if err != nil && errors.As(err, &hint) {
+		return hint.RetryAfter(), true
+	}
+	return 0, false
 }
 
 // TryOnConflict 方法尝试执行 fn 函数，如果遇到冲突则进行重试
 // The TryOnConflict method attempts to execute the fn function, and retries if a conflict is encountered
 func (r *Retry) TryOnConflict(fn RetryableFunc) *Result {
+	return r.TryOnConflictContext(r.config.ctx, fn)
+}
+
+// TryOnConflictContext 方法与 TryOnConflict 相同，但使用 ctx 代替配置中的上下文，适合按请求传入上下文的场景
+// The TryOnConflictContext method is the same as TryOnConflict but uses ctx instead of the configured context, which suits callers passing a context per request
+func (r *Retry) TryOnConflictContext(ctx context.Context, fn RetryableFunc) *Result {
 	// 如果 fn 函数为空，则返回 nil。这是因为没有函数可以执行，所以没有必要进行重试。
 	// If the fn function is null, return nil. This is because there is no function to execute, so there is no need to retry.
 	if fn == nil {
@@ -104,8 +220,16 @@ func (r *Retry) TryOnConflict(fn RetryableFunc) *Result {
 	}
 
 	// 创建一个新的定时器，定时器的延迟时间是 Config 中配置的延迟时间。定时器用于控制重试的间隔。
+	// 如果配置了立即执行，则第一次执行不需要等待。
 	// Create a new timer. The delay time of the timer is the delay time configured in Config. The timer is used to control the interval between retries.
-	tr := time.NewTimer(r.config.delay)
+	// If immediate execution is configured, the first execution does not wait.
+	// 第一次等待同样受最小和最大延迟时间的限制
+	// The first wait is also bounded by the minimum and maximum delay
+	firstDelay := r.config.clampDelay(r.config.delay)
+	if r.config.immediate {
+		firstDelay = 0
+	}
+	tr := time.NewTimer(firstDelay)
 
 	// 使用 defer 关键字确保定时器在函数结束时停止，避免资源泄露。
 	// Use the defer keyword to ensure that the timer stops when the function ends, to avoid resource leaks.
@@ -115,19 +239,46 @@ func (r *Retry) TryOnConflict(fn RetryableFunc) *Result {
 	// Create a new Result instance to store the execution result. The Result structure contains the execution result and error information.
 	result := NewResult()
 
+	// 为本次调用创建一个新的退避策略实例，避免不同调用之间共享状态
+	// Create a new backoff instance for this call to avoid sharing state between calls
+	bo := r.newBackoff()
+
+	// 按错误和错误码统计的失败次数，只在需要时创建
+	// Failure counts by error and by error code, only created when needed
+	var errCounts map[error]uint64
+	var codeCounts map[string]uint64
+
+	// 如果配置了总的超时时间，则在配置的上下文上加上超时
+	// If an overall timeout is configured, add it to the configured context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if r.config.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.config.timeout)
+		defer cancel()
+	}
+
 	// 循环尝试执行 fn 函数，直到满足退出条件
 	// Loop to try to execute the fn function until the exit condition is met
 	for {
 		select {
 		// 如果上下文已完成（例如，超时或手动取消），则将上下文的错误设置为结果的错误，并返回结果
 		// If the context is done (for example, timeout or manually cancelled), set the error of the context as the error of the result and return the result
-		case <-r.config.ctx.Done():
-			result.tryError = r.config.ctx.Err()
+		case <-ctx.Done():
+			result.tryError = ctx.Err()
 			return result
 
 		// 如果定时器到时，则尝试执行 fn 函数。定时器的时间间隔由 Config 中的退避函数和抖动决定。
 		// If the timer is up, try to execute the fn function. The time interval of the timer is determined by the backoff function and jitter in Config.
 		case <-tr.C:
+			// 定时器和上下文可能同时就绪，此时以上下文为准
+			// The timer and the context may be ready at the same time, in which case the context wins
+			if err := ctx.Err(); err != nil {
+				result.tryError = err
+				return result
+			}
+
 			// 调用 fn 函数，获取返回的数据和错误
 			// Call the fn function to get the returned data and error
 			data, err := fn()
@@ -136,9 +287,9 @@ func (r *Retry) TryOnConflict(fn RetryableFunc) *Result {
 			// Increase the execution count
 			result.count++
 
-			// 如果没有错误，则返回结果
-			// If there is no error, return the result
-			if err == nil {
+			// 如果没有错误并且返回值不需要重试，则返回结果
+			// If there is no error and the returned value does not need a retry, return the result
+			if err == nil && !r.retryOnResult(data, nil) {
 				// 将数据和错误（此时为 nil）设置到结果中
 				// Set the data and error (which is nil at this time) to the result
 				result.data = data
@@ -149,6 +300,14 @@ func (r *Retry) TryOnConflict(fn RetryableFunc) *Result {
 				return result
 			}
 
+			// 返回值被拒绝时保留这个值，重试次数用完时仍然可以从结果中获取，并使用 ErrorRetryResultRejected 作为本次的错误
+			// When the returned value is rejected, keep it so that it is still available from the result when the attempts run out, and use ErrorRetryResultRejected as the error of this execution
+			rejected := err == nil
+			if rejected {
+				result.data = data
+				err = ErrorRetryResultRejected
+			}
+
 			// 如果需要详细信息，则添加执行错误
 			// If details are needed, add execution errors
 			if r.config.detail {
@@ -159,7 +318,7 @@ func (r *Retry) TryOnConflict(fn RetryableFunc) *Result {
 
 			// 如果不需要重试，则返回结果
 			// If no retry is needed, return the result
-			if !r.config.retryIfFunc(err) {
+			if !rejected && !r.config.retryIfFunc(err) && !r.retryOnResult(data, err) {
 				// 将错误设置到结果中
 				// Set the error to the result
 				result.tryError = ErrorRetryIf
@@ -168,32 +327,36 @@ func (r *Retry) TryOnConflict(fn RetryableFunc) *Result {
 				// Return the result
 				return result
 			}
-			// 计算下一次重试的延迟时间，这里使用了一个随机的抖动和重试次数的乘积作为因子
-			// Calculate the delay time for the next retry, here a random jitter and the product of the number of retries are used as factors
-			delay := int64(rand.Float64()*float64(r.config.jitter) + float64(result.count)*r.config.factor)
-
-			// 如果计算出的延迟时间小于等于 0，则设置为默认的延迟时间
-			// If the calculated delay time is less than or equal to 0, set it to the default delay time
-			if delay <= 0 {
-				delay = defaultDelayNum
-			}
+			// 通过退避策略计算下一次重试的延迟时间，如果退避策略要求停止，则返回结果
+			// Calculate the delay for the next retry through the backoff strategy, and return the result if the backoff asks to stop
+			backoff, ok := r.nextDelay(bo, int64(result.count), err)
+			if !ok {
+				// 将错误设置到结果中，这个错误表示退避策略要求停止重试
+				// Set the error to the result, this error indicates that the backoff strategy asked to stop retrying
+				result.tryError = ErrorRetryBackoffStopped
 
-			// 计算退避时间，这里使用了配置中的退避函数和延迟时间
-			// Calculate the backoff time, here the backoff function and delay time in the configuration are used
-			backoff := r.config.backoffFunc(int64(delay)) + r.config.delay
+				// 返回结果
+				// Return the result
+				return result
+			}
 
 			// 调用配置中的回调函数，传入重试次数、退避时间和错误
 			// Call the callback function in the configuration, passing in the number of retries, backoff time, and error
 			r.config.callback.OnRetry(int64(result.count), backoff, err)
 
-			// 首先，我们检查特定错误的重试次数是否已经超过限制
-			// First, we check if the retry count for a specific error has exceeded the limit
+			// 首先，我们检查特定错误的重试次数是否已经超过限制，错误的计数只在本次调用中有效
+			// First, we check if the retry count for a specific error has exceeded the limit, the error counts only live within this call
 			// 如果错误次数超过限制，则返回结果
 			// If the number of errors exceeds the limit, return the result
-			if errAttempts, ok := r.config.attemptsByError[err]; ok {
-				// 如果特定错误的重试次数已经用完，则返回一个错误，表示按错误类型的重试次数已经超过
-				// If the retry count for a specific error has been used up, return an error indicating that the retry count by error type has been exceeded
-				if errAttempts <= 0 {
+			if limit, ok := r.config.attemptsByError[err]; ok {
+				if errCounts == nil {
+					errCounts = make(map[error]uint64)
+				}
+				errCounts[err]++
+
+				// 如果特定错误的失败次数超过了预算，则返回一个错误，表示按错误类型的重试次数已经超过
+				// If the failures with the specific error exceed the budget, return an error indicating that the retry count by error type has been exceeded
+				if errCounts[err] > limit {
 					// 将错误设置到结果中，这个错误表示特定错误的重试次数已经超过了限制
 					// Set the error to the result, this error indicates that the retry count for a specific error has exceeded the limit
 					result.tryError = ErrorRetryAttemptsByErrorExceeded
@@ -202,11 +365,24 @@ func (r *Retry) TryOnConflict(fn RetryableFunc) *Result {
 					// Return the result, this result includes the number of executions, the last error, and the attempted error
 					return result
 				}
+			}
 
-				// 如果还有剩余的重试次数，则减少一次重试次数，并更新到配置中
-				// If there are remaining retry counts, decrease the retry count by one and update it in the configuration
-				errAttempts--
-				r.config.attemptsByError[err] = errAttempts
+			// 接着，我们检查错误码对应的重试次数是否已经超过限制，错误码的计数只在本次调用中有效
+			// Next, we check if the retry count for the error code has exceeded the limit, the code counts only live within this call
+			if code := r.config.errorCodeFunc(err); code != "" {
+				if limit, ok := r.config.attemptsByCode[code]; ok {
+					if codeCounts == nil {
+						codeCounts = make(map[string]uint64)
+					}
+					codeCounts[code]++
+
+					// 如果错误码的失败次数超过了预算，则返回一个错误，表示按错误类型的重试次数已经超过
+					// If the failures with the code exceed the budget, return an error indicating that the retry count by error type has been exceeded
+					if codeCounts[code] > limit {
+						result.tryError = ErrorRetryAttemptsByErrorExceeded
+						return result
+					}
+				}
 			}
 
 			// 然后，我们检查总的执行次数是否已经超过限制
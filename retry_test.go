@@ -394,3 +394,407 @@ func TestRetry_ConfigEdgeCases(t *testing.T) {
 		})
 	}
 }
+
+type recordBackoff struct {
+	attempts []int64
+	errs     []error
+}
+
+func (b *recordBackoff) Next(attempt int64, lastErr error) (time.Duration, bool) {
+	b.attempts = append(b.attempts, attempt)
+	b.errs = append(b.errs, lastErr)
+	return time.Millisecond, attempt < 2
+}
+
+func (b *recordBackoff) Reset() {}
+
+func TestRetry_BackoffFactory(t *testing.T) {
+	e := errors.New("test")
+	created := 0
+	var backoffs []*recordBackoff
+
+	cfg := NewConfig().WithInitDelay(time.Millisecond).WithAttempts(5).WithBackoffFactory(func() Backoff {
+		created++
+		bo := &recordBackoff{}
+		backoffs = append(backoffs, bo)
+		return bo
+	})
+	r := New(cfg)
+
+	result := r.TryOnConflictVal(func() (any, error) {
+		return nil, e
+	})
+
+	assert.Equal(t, ErrorRetryBackoffStopped, result.TryError())
+	assert.Equal(t, int64(2), result.Count())
+	assert.Equal(t, []int64{1, 2}, backoffs[0].attempts)
+	assert.Equal(t, []error{e, e}, backoffs[0].errs)
+
+	// 每次调用都会创建一个新的退避策略实例
+	// A new backoff instance is created for each call
+	_ = r.TryOnConflictVal(func() (any, error) {
+		return nil, e
+	})
+	assert.Equal(t, 2, created)
+}
+
+func TestRetry_BackoffFactoryNil(t *testing.T) {
+	cfg := NewConfig().WithInitDelay(time.Millisecond).WithAttempts(2).WithBackOffFunc(func(int64) time.Duration {
+		return time.Millisecond
+	}).WithBackoffFactory(func() Backoff { return nil })
+
+	result := New(cfg).TryOnConflictVal(func() (any, error) {
+		return nil, errors.New("test")
+	})
+
+	assert.Equal(t, ErrorRetryAttemptsExceeded, result.TryError())
+	assert.Equal(t, int64(2), result.Count())
+}
+
+type delayCallback struct {
+	delays []time.Duration
+}
+
+func (cb *delayCallback) OnRetry(count int64, delay time.Duration, err error) {
+	cb.delays = append(cb.delays, delay)
+}
+
+func TestRetry_DelayClamp(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     *Config
+		minimum time.Duration
+		maximum time.Duration
+	}{
+		{
+			name:    "max delay",
+			cfg:     NewConfig().WithInitDelay(time.Millisecond).WithBackOffFunc(ExponentialBackoff).WithMaxDelay(5 * time.Millisecond),
+			minimum: 5 * time.Millisecond,
+			maximum: 5 * time.Millisecond,
+		},
+		{
+			name: "min delay",
+			cfg: NewConfig().WithInitDelay(time.Millisecond).WithMinDelay(3 * time.Millisecond).WithBackOffFunc(func(int64) time.Duration {
+				return 0
+			}),
+			minimum: 3 * time.Millisecond,
+			maximum: 3 * time.Millisecond,
+		},
+		{
+			name:    "base unit",
+			cfg:     NewConfig().WithInitDelay(time.Millisecond).WithBackOffFunc(FixedBackoff).WithJitter(0).WithBaseUnit(time.Millisecond),
+			minimum: 2 * time.Millisecond,
+			maximum: 4 * time.Millisecond,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cb := &delayCallback{}
+			result := New(tt.cfg.WithCallback(cb)).TryOnConflictVal(func() (any, error) {
+				return nil, errors.New("test")
+			})
+
+			assert.Equal(t, ErrorRetryAttemptsExceeded, result.TryError())
+			assert.Len(t, cb.delays, defaultAttempts)
+			for _, delay := range cb.delays {
+				assert.GreaterOrEqual(t, delay, tt.minimum)
+				assert.LessOrEqual(t, delay, tt.maximum)
+			}
+		})
+	}
+}
+
+func TestRetry_FirstDelayClamp(t *testing.T) {
+	// 初始延迟时间大于最大延迟时间时，第一次等待被限制为最大延迟时间
+	// When the initial delay exceeds the maximum delay, the first wait is capped at the maximum delay
+	start := time.Now()
+	result := New(NewConfig().WithInitDelay(2 * time.Second).WithMaxDelay(10 * time.Millisecond).WithAttempts(1)).
+		TryOnConflictVal(func() (any, error) { return "ok", nil })
+	assert.True(t, result.IsSuccess())
+	assert.Less(t, time.Since(start), time.Second)
+
+	// 初始延迟时间小于最小延迟时间时，第一次等待至少为最小延迟时间
+	// When the initial delay is below the minimum delay, the first wait lasts at least the minimum delay
+	start = time.Now()
+	result = New(NewConfig().WithInitDelay(time.Millisecond).WithMinDelay(50 * time.Millisecond).WithAttempts(1)).
+		TryOnConflictVal(func() (any, error) { return "ok", nil })
+	assert.True(t, result.IsSuccess())
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+}
+
+type codeError struct {
+	code string
+}
+
+func (e *codeError) Error() string { return "code " + e.code }
+
+func (e *codeError) Code() string { return e.code }
+
+func TestRetry_AttemptsByErrorCode(t *testing.T) {
+	cfg := NewConfig().
+		WithInitDelay(time.Millisecond).
+		WithBackOffFunc(func(int64) time.Duration { return 0 }).
+		WithAttempts(10).
+		WithAttemptsByErrorCode(map[string]uint64{"ECONNRESET": 2})
+	r := New(cfg)
+
+	for i := 0; i < 2; i++ {
+		result := r.TryOnConflictVal(func() (any, error) {
+			return nil, fmt.Errorf("wrapped: %w", &codeError{code: "ECONNRESET"})
+		})
+
+		// 错误码的计数只在一次调用中有效，因此每次调用的结果都相同
+		// Code counts only live within one call, so every call has the same result
+		assert.Equal(t, ErrorRetryAttemptsByErrorExceeded, result.TryError())
+		assert.Equal(t, int64(3), result.Count())
+	}
+
+	cfg.WithErrorCodeFunc(func(error) string { return "" })
+	result := New(cfg).TryOnConflictVal(func() (any, error) {
+		return nil, &codeError{code: "ECONNRESET"}
+	})
+	assert.Equal(t, ErrorRetryAttemptsExceeded, result.TryError())
+	assert.Equal(t, int64(10), result.Count())
+}
+
+func TestRetry_Timeout(t *testing.T) {
+	cfg := NewConfig().WithInitDelay(time.Millisecond).WithAttempts(1000).WithTimeout(50 * time.Millisecond).WithMaxDelay(10 * time.Millisecond)
+
+	start := time.Now()
+	result := New(cfg).TryOnConflictVal(func() (any, error) {
+		return nil, errors.New("test")
+	})
+
+	assert.Equal(t, context.DeadlineExceeded, result.TryError())
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Greater(t, result.Count(), int64(0))
+}
+
+func TestConfig_Clone(t *testing.T) {
+	e := errors.New("test")
+	cfg := NewConfig().WithAttempts(5).WithAttemptsByError(map[error]uint64{e: 1}).WithAttemptsByErrorCode(map[string]uint64{"E": 2})
+
+	clone := cfg.Clone()
+	assert.Equal(t, cfg.attempts, clone.attempts)
+	assert.Equal(t, cfg.attemptsByError, clone.attemptsByError)
+	assert.Equal(t, cfg.attemptsByCode, clone.attemptsByCode)
+
+	// 修改副本不会影响原配置
+	// Modifying the clone does not affect the original
+	clone.WithAttempts(7)
+	clone.attemptsByError[e] = 3
+	clone.attemptsByCode["E"] = 4
+	assert.Equal(t, uint64(5), cfg.attempts)
+	assert.Equal(t, uint64(1), cfg.attemptsByError[e])
+	assert.Equal(t, uint64(2), cfg.attemptsByCode["E"])
+}
+
+func TestRetry_NewSnapshotsConfig(t *testing.T) {
+	cfg := NewConfig().WithInitDelay(time.Millisecond).WithBackOffFunc(func(int64) time.Duration { return 0 }).WithAttempts(2)
+	r := New(cfg)
+
+	// 创建之后修改原配置不会影响 Retry 的行为
+	// Changing the original configuration after creation does not affect the Retry
+	cfg.WithAttempts(5)
+
+	result := r.TryOnConflictVal(func() (any, error) {
+		return nil, errors.New("test")
+	})
+	assert.Equal(t, int64(2), result.Count())
+}
+
+func TestRetry_AttemptsByErrorPerCall(t *testing.T) {
+	e := errors.New("test")
+	m := map[error]uint64{e: 1}
+	cfg := NewConfig().WithInitDelay(time.Millisecond).WithBackOffFunc(func(int64) time.Duration { return 0 }).WithAttemptsByError(m)
+	r := New(cfg)
+
+	for i := 0; i < 3; i++ {
+		result := r.TryOnConflictVal(func() (any, error) {
+			return nil, e
+		})
+
+		// 每次调用都有完整的重试次数，且配置中的映射不会被修改
+		// Every call gets the full budget and the map in the configuration is not modified
+		assert.Equal(t, ErrorRetryAttemptsByErrorExceeded, result.TryError())
+		assert.Equal(t, int64(2), result.Count())
+	}
+	assert.Equal(t, uint64(1), m[e])
+}
+
+func TestRetry_ConcurrentSharedRetry(t *testing.T) {
+	e := errors.New("test")
+	cfg := NewConfig().
+		WithInitDelay(time.Millisecond).
+		WithBackOffFunc(func(int64) time.Duration { return 0 }).
+		WithAttempts(4).
+		WithAttemptsByError(map[error]uint64{e: 2}).
+		WithAttemptsByErrorCode(map[string]uint64{"E": 1}).
+		WithDetail(true)
+	r := New(cfg)
+
+	var wg sync.WaitGroup
+
+	// 并发修改原配置不会与正在执行的调用产生数据竞争
+	// Concurrently modifying the original configuration does not race with running calls
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			cfg.WithAttempts(uint64(i + 1))
+		}
+	}()
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+
+		go func(id int) {
+			defer wg.Done()
+			result := r.TryOnConflictVal(func() (any, error) {
+				if id%3 == 0 {
+					return nil, &codeError{code: "E"}
+				}
+				return nil, e
+			})
+			assert.Equal(t, ErrorRetryAttemptsByErrorExceeded, result.TryError())
+			if id%3 == 0 {
+				assert.Equal(t, int64(2), result.Count())
+			} else {
+				assert.Equal(t, int64(3), result.Count())
+			}
+		}(i)
+	}
+
+	wg.Wait()
+}
+
+func TestRetry_TryOnConflictWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := New(NewConfig().WithInitDelay(time.Millisecond).WithAttempts(100))
+
+	count := 0
+	result := r.TryOnConflictContext(ctx, func() (any, error) {
+		count++
+		cancel()
+		return nil, errors.New("test")
+	})
+
+	assert.Equal(t, context.Canceled, result.TryError())
+	assert.Equal(t, 1, count)
+
+	// 传入空的上下文时使用 context.Background
+	// A nil context falls back to context.Background
+	result = r.TryOnConflictContext(nil, func() (any, error) { return "lee", nil })
+	assert.True(t, result.IsSuccess())
+	assert.Equal(t, "lee", result.Data())
+}
+
+func TestRetry_Immediate(t *testing.T) {
+	cfg := NewConfig().WithInitDelay(time.Second).WithImmediate(true)
+
+	start := time.Now()
+	result := New(cfg).TryOnConflictVal(func() (any, error) { return "lee", nil })
+
+	assert.True(t, result.IsSuccess())
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+}
+
+type retryAfterError struct {
+	wait time.Duration
+}
+
+func (e *retryAfterError) Error() string { return "retry after " + e.wait.String() }
+
+func (e *retryAfterError) RetryAfter() time.Duration { return e.wait }
+
+func TestRetry_RetryAfter(t *testing.T) {
+	cb := &delayCallback{}
+	cfg := NewConfig().
+		WithInitDelay(time.Millisecond).
+		WithBackOffFunc(func(int64) time.Duration { return 0 }).
+		WithAttempts(3).
+		WithCallback(cb)
+
+	result := New(cfg).TryOnConflictVal(func() (any, error) {
+		return nil, fmt.Errorf("wrapped: %w", &retryAfterError{wait: 20 * time.Millisecond})
+	})
+	assert.Equal(t, ErrorRetryAttemptsExceeded, result.TryError())
+	assert.Equal(t, []time.Duration{20 * time.Millisecond, 20 * time.Millisecond, 20 * time.Millisecond}, cb.delays)
+
+	// 最大延迟时间仍然生效
+	// The maximum delay still applies
+	cb = &delayCallback{}
+	result = New(cfg.WithMaxDelay(5 * time.Millisecond).WithCallback(cb)).TryOnConflictVal(func() (any, error) {
+		return nil, &retryAfterError{wait: time.Hour}
+	})
+	assert.Equal(t, ErrorRetryAttemptsExceeded, result.TryError())
+	assert.Equal(t, []time.Duration{5 * time.Millisecond, 5 * time.Millisecond, 5 * time.Millisecond}, cb.delays)
+}
+
+func TestRetry_RetryIfResult(t *testing.T) {
+	pending := func(data any, err error) bool { return data == "PENDING" }
+	cfg := NewConfig().
+		WithInitDelay(time.Millisecond).
+		WithBackOffFunc(func(int64) time.Duration { return 0 }).
+		WithAttempts(5).
+		WithDetail(true).
+		WithRetryIfResult(pending)
+
+	// 返回值就绪后成功
+	// Succeeds once the value is ready
+	count := 0
+	result := New(cfg).TryOnConflictVal(func() (any, error) {
+		count++
+		if count < 3 {
+			return "PENDING", nil
+		}
+		return "DONE", nil
+	})
+	assert.True(t, result.IsSuccess())
+	assert.Equal(t, "DONE", result.Data())
+	assert.Equal(t, int64(3), result.Count())
+	assert.Equal(t, []error{ErrorRetryResultRejected, ErrorRetryResultRejected}, result.ExecErrors())
+
+	// 重试次数用完时保留最后一次的返回值
+	// The last value is kept when the attempts run out
+	count = 0
+	result = New(cfg).TryOnConflictVal(func() (any, error) {
+		count++
+		return "PENDING", nil
+	})
+	assert.False(t, result.IsSuccess())
+	assert.Equal(t, ErrorRetryAttemptsExceeded, result.TryError())
+	assert.Equal(t, "PENDING", result.Data())
+	assert.Equal(t, 5, count)
+
+	// RetryIfFunc 拒绝的错误可以由结果检查函数重试
+	// Errors rejected by RetryIfFunc can be retried by the result check function
+	errNotFound := errors.New("not found")
+	count = 0
+	result = New(NewConfig().
+		WithInitDelay(time.Millisecond).
+		WithBackOffFunc(func(int64) time.Duration { return 0 }).
+		WithRetryIfFunc(func(error) bool { return false }).
+		WithRetryIfResult(func(data any, err error) bool { return errors.Is(err, errNotFound) })).
+		TryOnConflictVal(func() (any, error) {
+			count++
+			if count < 2 {
+				return nil, errNotFound
+			}
+			return "ok", nil
+		})
+	assert.True(t, result.IsSuccess())
+	assert.Equal(t, "ok", result.Data())
+	assert.Equal(t, 2, count)
+
+	// 结果检查函数不影响 RetryIfFunc 允许的重试
+	// The result check function does not affect the retries allowed by RetryIfFunc
+	count = 0
+	result = New(cfg).TryOnConflictVal(func() (any, error) {
+		count++
+		return nil, errNotFound
+	})
+	assert.Equal(t, ErrorRetryAttemptsExceeded, result.TryError())
+	assert.Equal(t, 5, count)
+}
@@ -0,0 +1,288 @@
+package retry
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// route 结构体保存一条路由规则和它使用的 Transport
+// The route struct keeps one routing rule and the Transport it uses
+type route struct {
+	pattern   string
+	host      string // 主机的模式，为空时匹配所有主机 Host pattern, empty matches every host
+	withPort  bool   // 主机的模式是否包含端口 Whether the host pattern includes the port
+	path      string // 路径的模式，为空时匹配所有路径 Path pattern, empty matches every path
+	transport *Transport
+}
+
+// parseRoute 函数解析路由的模式，第一个 "/" 之前是主机，之后是路径
+// The parseRoute function parses a route pattern, the part before the first "/" is the host and the rest is the path
+func parseRoute(pattern string) (*route, error) {
+	r := &route{pattern: pattern, host: strings.ToLower(pattern)}
+	if i := strings.IndexByte(pattern, '/'); i >= 0 {
+		r.host, r.path = strings.ToLower(pattern[:i]), pattern[i:]
+	}
+	r.withPort = strings.Contains(r.host, ":")
+
+	// path.Match 会对格式错误的模式返回 ErrBadPattern
+	// path.Match returns ErrBadPattern for malformed patterns
+	if _, err := path.Match(r.host, ""); err != nil {
+		return nil, fmt.Errorf("retry route %q: %w", pattern, err)
+	}
+	if _, err := path.Match(r.path, ""); err != nil {
+		return nil, fmt.Errorf("retry route %q: %w", pattern, err)
+	}
+
+	return r, nil
+}
+
+// match 方法判断请求是否匹配这条路由
+// The match method reports whether the request matches this route
+func (r *route) match(req *http.Request) bool {
+	if r.host != "" {
+		host := strings.ToLower(req.URL.Host)
+		if !r.withPort {
+			host = strings.ToLower(req.URL.Hostname())
+		}
+		if ok, _ := path.Match(r.host, host); !ok {
+			return false
+		}
+	}
+
+	if r.path == "" {
+		return true
+	}
+
+	p := req.URL.Path
+	if p == "" {
+		p = "/"
+	}
+	if ok, _ := path.Match(r.path, p); ok {
+		return true
+	}
+
+	// 路径末尾的 "*" 匹配剩下的整个路径，包括其中的 "/"
+	// A trailing "*" in the path matches the rest of the path, including any "/"
+	if strings.HasSuffix(r.path, "*") {
+		for i := len(p) - 1; i > 0; i-- {
+			if p[i] != '/' {
+				continue
+			}
+			if ok, _ := path.Match(r.path, p[:i]); ok {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// RoutingTransport 结构体是一个按主机或路径选择重试策略的 http.RoundTripper，可以被多个 goroutine 并发使用
+// 每个主机都有自己的熔断器和重试预算，一个不稳定的主机不会耗尽其他主机的重试能力
+// 路由按添加的顺序匹配，第一个匹配的路由生效，没有匹配的路由时使用默认的策略，路由需要在开始使用之前添加
+// 熔断器关闭并且空闲超过 WithHostIdleTimeout 的主机状态会被删除，主机的数量因此不会无限增长
+// The RoutingTransport struct is an http.RoundTripper that chooses the retry policy by host or path and is safe for concurrent use by multiple goroutines
+// Every host has its own circuit breaker and retry budget, so one flaky host does not use up the retry capacity of the others
+// Routes are matched in the order they were added and the first match wins, the default policy is used when no route matches, routes must be added before it is used
+// The state of hosts with a closed circuit that stayed idle longer than WithHostIdleTimeout is removed, so the number of hosts does not grow without bound
+type RoutingTransport struct {
+	base     http.RoundTripper
+	fallback *Transport
+	routes   []*route
+
+	threshold int
+	cooldown  time.Duration
+	maxTokens float64
+	ratio     float64
+
+	idleTimeout time.Duration
+	now         func() time.Time
+
+	mu    sync.Mutex
+	hosts map[string]*hostGuard
+	swept time.Time // 最后一次清理空闲主机的时间 Time idle hosts were last removed
+}
+
+// NewRoutingTransport 函数创建一个新的 RoutingTransport 实例，conf 是没有匹配的路由时使用的默认策略
+// 默认情况下，连续失败 5 次后熔断器打开 30 秒，每个主机有 10 个重试令牌，每次成功的请求归还 0.1 个令牌，主机空闲 10 分钟后状态被删除
+// The NewRoutingTransport function creates a new RoutingTransport instance, conf is the default policy used when no route matches
+// By default the circuit opens for 30 seconds after 5 consecutive failures, every host has 10 retry tokens with 0.1 token returned per successful request, and the state of a host is removed after 10 idle minutes
+func NewRoutingTransport(base http.RoundTripper, conf *Config) *RoutingTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	now := time.Now
+	return &RoutingTransport{
+		base:        base,
+		fallback:    NewTransport(base, conf),
+		threshold:   defaultBreakerThreshold,
+		cooldown:    defaultBreakerCooldown,
+		maxTokens:   defaultBudgetTokens,
+		ratio:       defaultBudgetRatio,
+		idleTimeout: defaultHostIdleTimeout,
+		now:         now,
+		hosts:       make(map[string]*hostGuard),
+		swept:       now(),
+	}
+}
+
+// AddRoute 方法检查配置并添加一条路由，pattern 由主机和路径组成，例如 "api.example.com"、"*.example.com/v1/*" 或 "/internal/*"
+// 主机和路径使用 path.Match 的语法，主机的模式包含端口时与请求的主机和端口比较，路径末尾的 "*" 匹配剩下的整个路径
+// The AddRoute method validates the configuration and adds a route, pattern consists of a host and a path, such as "api.example.com", "*.example.com/v1/*" or "/internal/*"
+// Hosts and paths use the path.Match syntax, host patterns with a port are compared against the host and port of the request, and a trailing "*" in the path matches the rest of the path
+func (rt *RoutingTransport) AddRoute(pattern string, conf *Config) error {
+	r, err := parseRoute(pattern)
+	if err != nil {
+		return err
+	}
+
+	if conf == nil {
+		conf = NewConfig()
+	}
+	if err := conf.Validate(); err != nil {
+		return fmt.Errorf("retry route %q: %w", pattern, err)
+	}
+
+	r.transport = NewTransport(rt.base, conf)
+	rt.routes = append(rt.routes, r)
+	return nil
+}
+
+// MustAddRoute 方法与 AddRoute 相同，但在出错时 panic，并返回 RoutingTransport 实例
+// The MustAddRoute method is the same as AddRoute but panics on error, and returns the RoutingTransport instance
+func (rt *RoutingTransport) MustAddRoute(pattern string, conf *Config) *RoutingTransport {
+	if err := rt.AddRoute(pattern, conf); err != nil {
+		panic(err)
+	}
+	return rt
+}
+
+// WithCircuitBreaker 方法设置每个主机的熔断器并返回 RoutingTransport 实例，连续失败 threshold 次后熔断器打开 cooldown 时间，threshold 为 0 时不启用熔断器
+// The WithCircuitBreaker method sets the circuit breaker of every host and returns the RoutingTransport instance, the circuit opens for cooldown after threshold consecutive failures, a threshold of 0 disables the circuit breaker
+func (rt *RoutingTransport) WithCircuitBreaker(threshold int, cooldown time.Duration) *RoutingTransport {
+	if threshold >= 0 && cooldown >= 0 {
+		rt.threshold = threshold
+		rt.cooldown = cooldown
+	}
+	return rt
+}
+
+// WithRetryBudget 方法设置每个主机的重试预算并返回 RoutingTransport 实例，每次重试消耗一个令牌，每次成功的请求归还 ratio 个令牌，最多 tokens 个，tokens 为 0 时不启用重试预算
+// The WithRetryBudget method sets the retry budget of every host and returns the RoutingTransport instance, every retry consumes one token and every successful request returns ratio tokens up to tokens, a tokens of 0 disables the retry budget
+func (rt *RoutingTransport) WithRetryBudget(tokens, ratio float64) *RoutingTransport {
+	if tokens >= 0 && ratio >= 0 {
+		rt.maxTokens = tokens
+		rt.ratio = ratio
+	}
+	return rt
+}
+
+// WithHostIdleTimeout 方法设置主机状态的空闲时间并返回 RoutingTransport 实例，熔断器关闭并且空闲超过 timeout 的主机状态被删除，timeout 为 0 时从不删除
+// 被删除的主机在下一次请求时重新开始，连续失败的次数归零，重试预算是满的
+// The WithHostIdleTimeout method sets the idle timeout of host state and returns the RoutingTransport instance, the state of hosts with a closed circuit idle longer than timeout is removed, a timeout of 0 never removes it
+// A removed host starts over on its next request, with no consecutive failures and a full retry budget
+func (rt *RoutingTransport) WithHostIdleTimeout(timeout time.Duration) *RoutingTransport {
+	if timeout >= 0 {
+		rt.idleTimeout = timeout
+	}
+	return rt
+}
+
+// RoundTrip 方法使用匹配的策略发送请求，并经过请求主机的熔断器和重试预算
+// The RoundTrip method sends the request with the matching policy, going through the circuit breaker and retry budget of the request host
+func (rt *RoutingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return rt.transport(req).roundTrip(req, rt.guard(hostKey(req)))
+}
+
+// transport 方法返回第一个匹配的路由的 Transport，没有匹配的路由时返回默认的 Transport
+// The transport method returns the Transport of the first matching route, or the default Transport when no route matches
+func (rt *RoutingTransport) transport(req *http.Request) *Transport {
+	for _, r := range rt.routes {
+		if r.match(req) {
+			return r.transport
+		}
+	}
+	return rt.fallback
+}
+
+// guard 方法返回主机的 hostGuard，第一次使用时创建，每隔一个空闲时间清理一次空闲的主机
+// The guard method returns the hostGuard of the host, creating it on first use, and removes idle hosts once every idle timeout
+func (rt *RoutingTransport) guard(host string) *hostGuard {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	rt.sweep()
+
+	g, ok := rt.hosts[host]
+	if !ok {
+		g = newHostGuard(host, rt.threshold, rt.cooldown, rt.maxTokens, rt.ratio, rt.now)
+		rt.hosts[host] = g
+	}
+	return g
+}
+
+// sweep 方法删除熔断器关闭并且空闲超过空闲时间的主机，距离上一次清理不到一个空闲时间时什么也不做，调用时需要持有锁
+// The sweep method removes hosts with a closed circuit that stayed idle longer than the idle timeout, doing nothing when less than one idle timeout passed since the last sweep, and must be called with the lock held
+func (rt *RoutingTransport) sweep() {
+	if rt.idleTimeout <= 0 {
+		return
+	}
+	now := rt.now()
+	if now.Sub(rt.swept) < rt.idleTimeout {
+		return
+	}
+	rt.swept = now
+
+	for host, g := range rt.hosts {
+		if g.idle(rt.idleTimeout) {
+			delete(rt.hosts, host)
+		}
+	}
+}
+
+// HostState 方法返回主机的熔断器和重试预算的快照，host 与请求的 URL.Host 相同，例如 "api.example.com:8443"
+// The HostState method returns a snapshot of the circuit breaker and retry budget of the host, host is the same as the URL.Host of the requests, such as "api.example.com:8443"
+func (rt *RoutingTransport) HostState(host string) (HostState, bool) {
+	rt.mu.Lock()
+	g, ok := rt.hosts[strings.ToLower(host)]
+	rt.mu.Unlock()
+
+	if !ok {
+		return HostState{}, false
+	}
+	return g.snapshot(), true
+}
+
+// HostStates 方法返回所有已经请求过的主机的状态快照，按主机排序
+// The HostStates method returns state snapshots of every host requested so far, sorted by host
+func (rt *RoutingTransport) HostStates() []HostState {
+	rt.mu.Lock()
+	guards := make([]*hostGuard, 0, len(rt.hosts))
+	for _, g := range rt.hosts {
+		guards = append(guards, g)
+	}
+	rt.mu.Unlock()
+
+	states := make([]HostState, 0, len(guards))
+	for _, g := range guards {
+		states = append(states, g.snapshot())
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Host < states[j].Host })
+
+	return states
+}
+
+// hostKey 函数返回请求的主机，用于区分每个主机的状态
+// The hostKey function returns the host of the request, used to keep the state of every host apart
+func hostKey(req *http.Request) string {
+	if req.URL.Host != "" {
+		return strings.ToLower(req.URL.Host)
+	}
+	return strings.ToLower(req.Host)
+}
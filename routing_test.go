@@ -0,0 +1,221 @@
+package retry
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoute_Match(t *testing.T) {
+	tests := []struct {
+		pattern string
+		url     string
+		match   bool
+	}{
+		{pattern: "api.example.com", url: "http://api.example.com/v1/users", match: true},
+		{pattern: "api.example.com", url: "http://API.example.com:8080/", match: true},
+		{pattern: "api.example.com", url: "http://web.example.com/", match: false},
+		{pattern: "*.example.com", url: "http://a.b.example.com/", match: true},
+		{pattern: "*.example.com", url: "http://example.com/", match: false},
+		{pattern: "api.example.com:8443", url: "http://api.example.com:8443/", match: true},
+		{pattern: "api.example.com:8443", url: "http://api.example.com/", match: false},
+		{pattern: "api.example.com/v1/*", url: "http://api.example.com/v1/users/42", match: true},
+		{pattern: "api.example.com/v1/*", url: "http://api.example.com/v1/", match: true},
+		{pattern: "api.example.com/v1/*", url: "http://api.example.com/v2/users", match: false},
+		{pattern: "/internal/*", url: "http://any.host/internal/jobs", match: true},
+		{pattern: "/health", url: "http://any.host/health", match: true},
+		{pattern: "/health", url: "http://any.host/health/deep", match: false},
+		{pattern: "/", url: "http://any.host", match: true},
+	}
+
+	for _, tt := range tests {
+		r, err := parseRoute(tt.pattern)
+		assert.NoError(t, err)
+
+		u, _ := url.Parse(tt.url)
+		assert.Equal(t, tt.match, r.match(&http.Request{URL: u}), tt.pattern+" "+tt.url)
+	}
+
+	_, err := parseRoute("api.example.com/[")
+	assert.Error(t, err)
+}
+
+func TestRoutingTransport_AddRoute(t *testing.T) {
+	rt := NewRoutingTransport(nil, nil)
+
+	assert.Error(t, rt.AddRoute("[", nil))
+	err := rt.AddRoute("api.example.com", NewConfig().WithFactor(-1))
+	var configErr *ConfigError
+	assert.True(t, errors.As(err, &configErr))
+	assert.Empty(t, rt.routes)
+
+	assert.Panics(t, func() { rt.MustAddRoute("[", nil) })
+	assert.NotPanics(t, func() { rt.MustAddRoute("api.example.com", nil) })
+	assert.Len(t, rt.routes, 1)
+}
+
+func TestRoutingTransport_Routes(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	host := strings.TrimPrefix(srv.URL, "http://")
+	rt := NewRoutingTransport(nil, newTestTransportConfig().WithAttempts(2)).
+		WithCircuitBreaker(0, 0).
+		MustAddRoute(host+"/critical/*", newTestTransportConfig().WithAttempts(5))
+	client := &http.Client{Transport: rt}
+
+	resp, err := client.Get(srv.URL + "/critical/orders")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, int32(5), atomic.LoadInt32(&calls))
+
+	// 没有匹配的路由时使用默认的策略
+	// The default policy is used when no route matches
+	atomic.StoreInt32(&calls, 0)
+	resp, err = client.Get(srv.URL + "/other")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestRoutingTransport_HostIsolation(t *testing.T) {
+	var flakyCalls, healthyCalls int32
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&flakyCalls, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer flaky.Close()
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&healthyCalls, 1)%2 == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer healthy.Close()
+
+	rt := NewRoutingTransport(nil, newTestTransportConfig().WithAttempts(3)).
+		WithCircuitBreaker(4, time.Hour).
+		WithRetryBudget(10, 0.1)
+	client := &http.Client{Transport: rt}
+
+	// 第一次调用失败 3 次，第二次调用在第 4 次失败后打开熔断器
+	// The first call fails 3 times, the second call opens the circuit after the 4th failure
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(flaky.URL)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+		resp.Body.Close()
+	}
+	assert.Equal(t, int32(4), atomic.LoadInt32(&flakyCalls))
+
+	// 熔断器打开后请求直接失败
+	// Requests fail immediately once the circuit is open
+	_, err := client.Get(flaky.URL)
+	assert.True(t, errors.Is(err, ErrorCircuitOpen))
+	assert.Equal(t, int32(4), atomic.LoadInt32(&flakyCalls))
+
+	// 其他主机不受影响
+	// Other hosts are not affected
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(healthy.URL)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		resp.Body.Close()
+	}
+
+	flakyState, ok := rt.HostState(strings.TrimPrefix(flaky.URL, "http://"))
+	assert.True(t, ok)
+	assert.Equal(t, CircuitOpen, flakyState.Circuit)
+	assert.Equal(t, 4, flakyState.Failures)
+	assert.Equal(t, float64(8), flakyState.Tokens)
+
+	healthyState, ok := rt.HostState(strings.TrimPrefix(healthy.URL, "http://"))
+	assert.True(t, ok)
+	assert.Equal(t, CircuitClosed, healthyState.Circuit)
+	assert.Equal(t, 0, healthyState.Failures)
+	assert.InDelta(t, 7.3, healthyState.Tokens, 1e-9)
+
+	states := rt.HostStates()
+	assert.Len(t, states, 2)
+	assert.True(t, states[0].Host < states[1].Host)
+
+	_, ok = rt.HostState("unknown.example.com")
+	assert.False(t, ok)
+}
+
+func TestRoutingTransport_RetryBudget(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	rt := NewRoutingTransport(nil, newTestTransportConfig().WithAttempts(5)).
+		WithCircuitBreaker(0, 0).
+		WithRetryBudget(3, 0)
+	client := &http.Client{Transport: rt}
+
+	// 重试预算只允许 3 次重试，之后返回最后一次的响应
+	// The retry budget only allows 3 retries, after which the last response is returned
+	resp, err := client.Get(srv.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	resp.Body.Close()
+	assert.Equal(t, int32(4), atomic.LoadInt32(&calls))
+
+	// 预算用完后每次调用只发送一次
+	// Once the budget is used up every call is sent only once
+	resp, err = client.Get(srv.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, int32(5), atomic.LoadInt32(&calls))
+
+	state := rt.HostStates()[0]
+	assert.Equal(t, float64(0), state.Tokens)
+	assert.Equal(t, CircuitClosed, state.Circuit)
+}
+
+func TestRoutingTransport_IdleHosts(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	rt := NewRoutingTransport(nil, nil).WithCircuitBreaker(1, 24*time.Hour).WithHostIdleTimeout(time.Minute)
+	rt.now = func() time.Time { return now }
+	rt.swept = now
+
+	for _, host := range []string{"a.example.com", "b.example.com", "c.example.com"} {
+		assert.NoError(t, rt.guard(host).acquire(false))
+	}
+	rt.guard("c.example.com").record(outcomeFailure)
+
+	// 熔断器关闭并且空闲超过一分钟的主机被删除，熔断器打开的主机被保留
+	// Hosts with a closed circuit idle for over a minute are removed, hosts with an open circuit are kept
+	now = now.Add(30 * time.Second)
+	rt.guard("a.example.com").record(outcomeSuccess)
+	now = now.Add(45 * time.Second)
+	rt.guard("d.example.com")
+
+	var hosts []string
+	for _, state := range rt.HostStates() {
+		hosts = append(hosts, state.Host)
+	}
+	assert.Equal(t, []string{"a.example.com", "c.example.com", "d.example.com"}, hosts)
+
+	// 空闲时间为 0 时从不删除
+	// A timeout of 0 never removes hosts
+	rt.WithHostIdleTimeout(0)
+	now = now.Add(time.Hour)
+	rt.guard("e.example.com")
+	assert.Len(t, rt.HostStates(), 4)
+}
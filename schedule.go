@@ -0,0 +1,100 @@
+package retry
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// 默认的采样次数
+// Default number of samples
+const defaultScheduleSamples = 1000
+
+// ScheduleStep 结构体描述了某一次执行失败后，下一次重试前延迟时间的分布
+// The ScheduleStep struct describes the distribution of the delay before the next retry after a given failed execution
+type ScheduleStep struct {
+	Attempt       int64         // 已执行的次数，从 1 开始 Number of executions so far, starting at 1
+	Samples       int           // 到达这一步的采样次数 Number of samples that reached this step
+	Min           time.Duration // 最小延迟时间 Minimum delay
+	Median        time.Duration // 延迟时间的中位数 Median delay
+	P99           time.Duration // 延迟时间的第 99 百分位数 99th percentile delay
+	Max           time.Duration // 最大延迟时间 Maximum delay
+	CumulativeMax time.Duration // 从开始到这一步为止最坏情况下的累计等待时间 Worst-case cumulative wait from the start up to this step
+}
+
+// Schedule 方法通过对真实的延迟计算流程进行采样（不会等待），预览配置在前 n 次重试中产生的延迟时间
+// n 小于等于 0 时使用配置的重试次数减一，即最后一次执行之后不再等待，samples 小于等于 0 时使用默认的采样次数
+// 累计等待时间包含了第一次执行前的等待时间；当退避策略要求停止时，后续的步骤只统计仍在重试的采样
+// 配置了固定的种子时，每次采样使用由该种子派生的不同种子，结果可以重现，但采样之间仍然不同
+// The Schedule method previews the delays the configuration produces for the first n retries by sampling the real delay pipeline without sleeping
+// When n is less than or equal to 0 the configured attempts minus one are used, since there is no wait after the last execution, and when samples is less than or equal to 0 the default number of samples is used
+// The cumulative wait includes the wait before the first execution; when the backoff asks to stop, later steps only count the samples still retrying
+// With a fixed seed every sample uses a different seed derived from it, so the result is reproducible while the samples still differ
+func (c *Config) Schedule(n int, samples int) []ScheduleStep {
+	// 使用配置的副本，避免修正默认值时修改原始配置
+	// Use a copy of the configuration to avoid modifying the original one while correcting defaults
+	r := &Retry{config: isConfigValid(c.Clone())}
+
+	if n <= 0 {
+		n = int(r.config.attempts) - 1
+	}
+	if samples <= 0 {
+		samples = defaultScheduleSamples
+	}
+
+	// 按步骤收集每次采样的延迟时间
+	// Collect the delay of every sample per step
+	delays := make([][]time.Duration, n)
+	seed := r.config.seed
+	for i := 0; i < samples; i++ {
+		if r.config.seeded {
+			r.config.seed = seed + int64(i)
+		}
+		bo := r.newBackoff()
+		for step := 0; step < n; step++ {
+			delay, ok := r.nextDelay(bo, int64(step+1), nil)
+			if !ok {
+				break
+			}
+			delays[step] = append(delays[step], delay)
+		}
+	}
+
+	steps := make([]ScheduleStep, 0, n)
+	cumulative := r.config.clampDelay(r.config.delay)
+	if r.config.immediate {
+		cumulative = 0
+	}
+	for step, values := range delays {
+		// 所有采样都已经停止，后续的步骤不会发生
+		// All samples have stopped, later steps never happen
+		if len(values) == 0 {
+			break
+		}
+
+		sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+		cumulative = addDelay(cumulative, values[len(values)-1])
+
+		steps = append(steps, ScheduleStep{
+			Attempt:       int64(step + 1),
+			Samples:       len(values),
+			Min:           values[0],
+			Median:        percentile(values, 0.5),
+			P99:           percentile(values, 0.99),
+			Max:           values[len(values)-1],
+			CumulativeMax: cumulative,
+		})
+	}
+
+	return steps
+}
+
+// percentile 返回已排序的延迟时间中第 p 百分位的值（最近秩法）
+// percentile returns the p-th percentile of the sorted delays (nearest-rank method)
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	idx := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	return sorted[idx]
+}
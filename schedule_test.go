@@ -0,0 +1,112 @@
+package retry
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_ScheduleFixed(t *testing.T) {
+	cfg := FixConfig().WithInitDelay(time.Second)
+
+	steps := cfg.Schedule(3, 10)
+	assert.Len(t, steps, 3)
+
+	cumulative := time.Second
+	for i, step := range steps {
+		// FixConfig 的因子和抖动都为 0，因此退避函数的参数总是默认的延迟数值
+		// FixConfig has zero factor and jitter, so the backoff argument is always the default delay number
+		expected := FixedBackoff(defaultDelayNum) + time.Second
+		cumulative += expected
+
+		assert.Equal(t, int64(i+1), step.Attempt)
+		assert.Equal(t, 10, step.Samples)
+		assert.Equal(t, expected, step.Min)
+		assert.Equal(t, expected, step.Median)
+		assert.Equal(t, expected, step.P99)
+		assert.Equal(t, expected, step.Max)
+		assert.Equal(t, cumulative, step.CumulativeMax)
+	}
+}
+
+func TestConfig_ScheduleDistribution(t *testing.T) {
+	cfg := NewConfig().WithMaxDelay(3 * time.Second)
+
+	steps := cfg.Schedule(0, 0)
+	assert.Len(t, steps, defaultAttempts-1)
+
+	for _, step := range steps {
+		assert.Equal(t, defaultScheduleSamples, step.Samples)
+		assert.LessOrEqual(t, step.Min, step.Median)
+		assert.LessOrEqual(t, step.Median, step.P99)
+		assert.LessOrEqual(t, step.P99, step.Max)
+		assert.LessOrEqual(t, step.Max, 3*time.Second)
+		assert.GreaterOrEqual(t, step.Min, defaultDelay)
+	}
+}
+
+func TestConfig_ScheduleStopped(t *testing.T) {
+	cfg := NewConfig().WithBackoffFactory(LimitBackoff(FuncBackoff(FixedBackoff), 2))
+
+	steps := cfg.Schedule(5, 10)
+	assert.Len(t, steps, 1)
+	assert.Equal(t, int64(1), steps[0].Attempt)
+}
+
+func TestConfig_ScheduleDoesNotModifyConfig(t *testing.T) {
+	cfg := NewConfig().WithAttempts(0).WithInitDelay(-1)
+
+	steps := cfg.Schedule(2, 1)
+	assert.Len(t, steps, 2)
+	assert.Equal(t, uint64(0), cfg.attempts)
+	assert.Equal(t, time.Duration(-1), cfg.delay)
+}
+
+func TestConfig_ScheduleMatchesRun(t *testing.T) {
+	cfg := NewConfig().
+		WithInitDelay(time.Millisecond).
+		WithBackOffFunc(func(int64) time.Duration { return 5 * time.Millisecond }).
+		WithAttempts(3)
+
+	// 三次执行之间只有两次等待
+	// Three executions only have two waits between them
+	steps := cfg.Schedule(0, 1)
+	assert.Len(t, steps, 2)
+	worst := steps[len(steps)-1].CumulativeMax
+	assert.Equal(t, 13*time.Millisecond, worst)
+
+	// 比较回调函数记录的延迟时间而不是耗时，结果不受机器负载的影响
+	// The delays recorded by the callback are compared instead of the elapsed time, so the result does not depend on machine load
+	cb := &delayCallback{}
+	result := New(cfg.WithCallback(cb)).TryOnConflictVal(func() (any, error) { return nil, errors.New("test") })
+
+	assert.Equal(t, ErrorRetryAttemptsExceeded, result.TryError())
+	assert.GreaterOrEqual(t, len(cb.delays), len(steps))
+	for i, step := range steps {
+		assert.GreaterOrEqual(t, cb.delays[i], step.Min, "step %d", step.Attempt)
+		assert.LessOrEqual(t, cb.delays[i], step.Max, "step %d", step.Attempt)
+	}
+}
+
+func TestConfig_ScheduleSeeded(t *testing.T) {
+	cfg := NewConfig().WithSeed(42)
+
+	first := cfg.Schedule(2, 100)
+	second := cfg.Schedule(2, 100)
+	assert.Equal(t, first, second)
+	assert.Less(t, first[0].Min, first[0].Max)
+}
+
+func TestPercentile(t *testing.T) {
+	values := make([]time.Duration, 100)
+	for i := range values {
+		values[i] = time.Duration(i + 1)
+	}
+
+	assert.Equal(t, time.Duration(50), percentile(values, 0.5))
+	assert.Equal(t, time.Duration(99), percentile(values, 0.99))
+	assert.Equal(t, time.Duration(1), percentile(values, 0))
+	assert.Equal(t, time.Duration(1), percentile(values[:1], 0.99))
+}
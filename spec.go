@@ -0,0 +1,588 @@
+package retry
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// 退避策略描述字符串中支持的抖动类型
+// Jitter types supported in backoff spec strings
+const (
+	JitterNone  = "none"  // 不添加抖动 No jitter
+	JitterFull  = "full"  // 全抖动 Full jitter
+	JitterEqual = "equal" // 等抖动 Equal jitter
+)
+
+// SpecError 结构体描述了退避策略描述字符串的解析错误
+// The SpecError struct describes a parse error of a backoff spec string
+type SpecError struct {
+	Spec string // 原始的描述字符串 The original spec string
+	Pos  int    // 出错的位置（字节偏移） Position of the error (byte offset)
+	Msg  string // 错误信息 Error message
+}
+
+// Error 方法返回错误的描述
+// The Error method returns the description of the error
+func (e *SpecError) Error() string {
+	return fmt.Sprintf("invalid backoff spec %q at offset %d: %s", e.Spec, e.Pos, e.Msg)
+}
+
+// BackoffSpec 结构体是解析后的退避策略描述，例如 exponential(base=100ms,factor=2,max=30s,jitter=full)
+// 或 chain(fixed(200ms)x3, exponential(base=1s))，String 方法返回规范化的描述字符串
+// The BackoffSpec struct is a parsed backoff policy description, such as exponential(base=100ms,factor=2,max=30s,jitter=full)
+// or chain(fixed(200ms)x3, exponential(base=1s)), the String method returns the normalized spec string
+type BackoffSpec struct {
+	Name     string            // 策略名称 Strategy name
+	Args     map[string]string // 规范化后的参数 Normalized arguments
+	Children []*BackoffSpec    // 子策略，仅用于 chain Child strategies, only used by chain
+	Repeat   int64             // 重复次数，0 表示不限制 Repeat count, 0 means unlimited
+
+	factory BackoffFactory // 根据描述创建的工厂函数 Factory built from the description
+	argPos  map[string]int // 每个参数的值在描述字符串中的位置 Position of the value of every argument in the spec string
+}
+
+// specArgError 结构体表示一个参数的值无效，解析器用它把错误定位到参数的值
+// The specArgError struct represents an invalid argument value, the parser uses it to point the error at the value of the argument
+type specArgError struct {
+	key string
+	msg string
+}
+
+// Error 方法返回错误的描述
+// The Error method returns the description of the error
+func (e *specArgError) Error() string {
+	return e.msg
+}
+
+// argErrorf 函数返回参数 key 的值无效的错误
+// The argErrorf function returns an error for an invalid value of the argument key
+func argErrorf(key, format string, args ...any) error {
+	return &specArgError{key: key, msg: fmt.Sprintf(format, args...)}
+}
+
+// specKind 结构体定义了一种退避策略支持的参数和构建方法
+// The specKind struct defines the arguments supported by a backoff strategy and how to build it
+type specKind struct {
+	params   []string                                        // 按规范顺序排列的参数名 Parameter names in canonical order
+	children bool                                            // 是否接受子策略 Whether child strategies are accepted
+	build    func(spec *BackoffSpec) (BackoffFactory, error) // 构建工厂函数 Builds the factory
+}
+
+// specKinds 定义了所有支持的退避策略
+// specKinds defines all supported backoff strategies
+var specKinds map[string]specKind
+
+func init() {
+	specKinds = map[string]specKind{
+		"fixed":        {params: []string{"delay"}, build: buildFixedSpec},
+		"exponential":  {params: []string{"base", "factor", "max", "jitter"}, build: buildExponentialSpec},
+		"decorrelated": {params: []string{"base", "max"}, build: buildDecorrelatedSpec},
+		"random":       {params: []string{"max", "min"}, build: buildRandomSpec},
+		"chain":        {children: true, build: buildChainSpec},
+	}
+}
+
+// ParseBackoff 函数解析退避策略描述字符串
+// The ParseBackoff function parses a backoff spec string
+func ParseBackoff(s string) (*BackoffSpec, error) {
+	p := &specParser{src: s}
+
+	p.skipSpace()
+	spec, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpace()
+	if p.pos < len(p.src) {
+		return nil, p.errorf("unexpected %q after end of spec", p.src[p.pos:])
+	}
+
+	return spec, nil
+}
+
+// MustParseBackoff 函数解析退避策略描述字符串，解析失败时 panic
+// The MustParseBackoff function parses a backoff spec string and panics if parsing fails
+func MustParseBackoff(s string) *BackoffSpec {
+	spec, err := ParseBackoff(s)
+	if err != nil {
+		panic(err)
+	}
+	return spec
+}
+
+// Factory 方法返回根据描述创建的退避策略工厂函数
+// The Factory method returns the backoff factory built from the description
+func (s *BackoffSpec) Factory() BackoffFactory {
+	return s.factory
+}
+
+// String 方法返回规范化的描述字符串，可以再次被 ParseBackoff 解析
+// The String method returns the normalized spec string, which can be parsed again by ParseBackoff
+func (s *BackoffSpec) String() string {
+	var b strings.Builder
+	b.WriteString(s.Name)
+	b.WriteByte('(')
+
+	parts := make([]string, 0, len(s.Args)+len(s.Children))
+	for _, key := range specKinds[s.Name].params {
+		if v, ok := s.Args[key]; ok {
+			parts = append(parts, key+"="+v)
+		}
+	}
+	for _, child := range s.Children {
+		parts = append(parts, child.String())
+	}
+
+	b.WriteString(strings.Join(parts, ","))
+	b.WriteByte(')')
+
+	if s.Repeat > 0 {
+		b.WriteByte('x')
+		b.WriteString(strconv.FormatInt(s.Repeat, 10))
+	}
+
+	return b.String()
+}
+
+// specParser 是退避策略描述字符串的递归下降解析器
+// specParser is a recursive descent parser for backoff spec strings
+type specParser struct {
+	src string
+	pos int
+}
+
+// errorf 方法返回当前位置的解析错误
+// The errorf method returns a parse error at the current position
+func (p *specParser) errorf(format string, args ...any) error {
+	return &SpecError{Spec: p.src, Pos: p.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// skipSpace 方法跳过空白字符
+// The skipSpace method skips whitespace
+func (p *specParser) skipSpace() {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+}
+
+// peek 方法返回当前字符，到达末尾时返回 0
+// The peek method returns the current character, or 0 at the end
+func (p *specParser) peek() byte {
+	if p.pos < len(p.src) {
+		return p.src[p.pos]
+	}
+	return 0
+}
+
+// parseIdent 方法解析一个标识符
+// The parseIdent method parses an identifier
+func (p *specParser) parseIdent() string {
+	start := p.pos
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (p.pos > start && c >= '0' && c <= '9') {
+			p.pos++
+			continue
+		}
+		break
+	}
+	return strings.ToLower(p.src[start:p.pos])
+}
+
+// parseExpr 方法解析 name(args)[xN] 形式的表达式
+// The parseExpr method parses an expression of the form name(args)[xN]
+func (p *specParser) parseExpr() (*BackoffSpec, error) {
+	start := p.pos
+	name := p.parseIdent()
+	if name == "" {
+		return nil, p.errorf("expected strategy name")
+	}
+
+	kind, ok := specKinds[name]
+	if !ok {
+		p.pos = start
+		return nil, p.errorf("unknown strategy %q", name)
+	}
+
+	p.skipSpace()
+	if p.peek() != '(' {
+		return nil, p.errorf("expected '(' after %q", name)
+	}
+	p.pos++
+
+	spec := &BackoffSpec{Name: name, Args: make(map[string]string), argPos: make(map[string]int)}
+	if err := p.parseArgs(spec, kind); err != nil {
+		return nil, err
+	}
+
+	// 解析可选的重复次数
+	// Parse the optional repeat count
+	if p.peek() == 'x' || p.peek() == 'X' {
+		p.pos++
+		numStart := p.pos
+		for p.pos < len(p.src) && p.src[p.pos] >= '0' && p.src[p.pos] <= '9' {
+			p.pos++
+		}
+		repeat, err := strconv.ParseInt(p.src[numStart:p.pos], 10, 64)
+		if err != nil || repeat <= 0 {
+			p.pos = numStart
+			return nil, p.errorf("expected positive repeat count after 'x'")
+		}
+		spec.Repeat = repeat
+	}
+
+	// 参数的值无效时报告参数的位置，其他错误报告表达式的位置
+	// Invalid argument values report the position of the argument, other errors report the position of the expression
+	factory, err := kind.build(spec)
+	if err != nil {
+		pos := start
+		var argErr *specArgError
+		if errors.As(err, &argErr) {
+			if at, ok := spec.argPos[argErr.key]; ok {
+				pos = at
+			}
+		}
+		return nil, &SpecError{Spec: p.src, Pos: pos, Msg: fmt.Sprintf("%s: %v", name, err)}
+	}
+	spec.factory = factory
+
+	return spec, nil
+}
+
+// parseArgs 方法解析括号中的参数，直到遇到右括号
+// The parseArgs method parses the arguments in parentheses up to the closing parenthesis
+func (p *specParser) parseArgs(spec *BackoffSpec, kind specKind) error {
+	p.skipSpace()
+	if p.peek() == ')' {
+		p.pos++
+		return nil
+	}
+
+	for index := 0; ; index++ {
+		p.skipSpace()
+		if err := p.parseArg(spec, kind, index); err != nil {
+			return err
+		}
+
+		p.skipSpace()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ')':
+			p.pos++
+			return nil
+		case 0:
+			return p.errorf("missing ')'")
+		default:
+			return p.errorf("expected ',' or ')'")
+		}
+	}
+}
+
+// parseArg 方法解析一个参数：子策略、key=value 或按位置传入的值
+// The parseArg method parses one argument: a child strategy, key=value, or a positional value
+func (p *specParser) parseArg(spec *BackoffSpec, kind specKind, index int) error {
+	start := p.pos
+
+	if kind.children {
+		child, err := p.parseExpr()
+		if err != nil {
+			return err
+		}
+		spec.Children = append(spec.Children, child)
+		return nil
+	}
+
+	// 尝试解析 key=value 形式的参数
+	// Try to parse an argument of the form key=value
+	key := p.parseIdent()
+	p.skipSpace()
+	if key != "" && p.peek() == '=' {
+		p.pos++
+		p.skipSpace()
+	} else {
+		// 按位置传入的值只允许作为第一个参数
+		// A positional value is only allowed as the first argument
+		p.pos = start
+		if index != 0 || len(kind.params) == 0 {
+			return p.errorf("expected key=value")
+		}
+		key = kind.params[0]
+	}
+
+	if !containsString(kind.params, key) {
+		p.pos = start
+		return p.errorf("unknown parameter %q, expected one of %s", key, strings.Join(kind.params, ", "))
+	}
+	if _, ok := spec.Args[key]; ok {
+		p.pos = start
+		return p.errorf("duplicate parameter %q", key)
+	}
+
+	valueStart := p.pos
+	for p.pos < len(p.src) && p.src[p.pos] != ',' && p.src[p.pos] != ')' {
+		p.pos++
+	}
+	value := strings.TrimSpace(p.src[valueStart:p.pos])
+	if value == "" {
+		p.pos = valueStart
+		return p.errorf("missing value for %q", key)
+	}
+
+	spec.Args[key] = value
+	spec.argPos[key] = valueStart
+	return nil
+}
+
+// containsString 函数判断字符串切片中是否包含指定的字符串
+// The containsString function reports whether the slice contains the string
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// durationArg 函数读取并规范化一个时间参数，参数不存在时返回默认值
+// The durationArg function reads and normalizes a duration argument, returning the default value if it is absent
+func durationArg(spec *BackoffSpec, key string, def time.Duration) (time.Duration, error) {
+	v, ok := spec.Args[key]
+	if !ok {
+		return def, nil
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, argErrorf(key, "invalid %s %q: expected a duration such as 100ms", key, v)
+	}
+	if d < 0 {
+		return 0, argErrorf(key, "invalid %s %q: must not be negative", key, v)
+	}
+
+	spec.Args[key] = d.String()
+	return d, nil
+}
+
+// floatArg 函数读取并规范化一个浮点数参数，参数不存在时返回默认值
+// The floatArg function reads and normalizes a float argument, returning the default value if it is absent
+func floatArg(spec *BackoffSpec, key string, def float64) (float64, error) {
+	v, ok := spec.Args[key]
+	if !ok {
+		return def, nil
+	}
+
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, argErrorf(key, "invalid %s %q: expected a number", key, v)
+	}
+
+	spec.Args[key] = strconv.FormatFloat(f, 'g', -1, 64)
+	return f, nil
+}
+
+// buildFixedSpec 函数构建 fixed(delay) 策略
+// The buildFixedSpec function builds the fixed(delay) strategy
+func buildFixedSpec(spec *BackoffSpec) (BackoffFactory, error) {
+	if _, ok := spec.Args["delay"]; !ok {
+		return nil, fmt.Errorf("missing delay")
+	}
+
+	delay, err := durationArg(spec, "delay", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return repeatFactory(spec, func() Backoff {
+		return &statelessBackoff{next: func(int64, *rand.Rand) time.Duration { return delay }}
+	}), nil
+}
+
+// buildExponentialSpec 函数构建 exponential(base, factor, max, jitter) 策略
+// The buildExponentialSpec function builds the exponential(base, factor, max, jitter) strategy
+func buildExponentialSpec(spec *BackoffSpec) (BackoffFactory, error) {
+	base, err := durationArg(spec, "base", baseInterval)
+	if err != nil {
+		return nil, err
+	}
+	factor, err := floatArg(spec, "factor", 2)
+	if err != nil {
+		return nil, err
+	}
+	if factor < 1 {
+		return nil, argErrorf("factor", "invalid factor %v: must be at least 1", factor)
+	}
+	ceil, err := durationArg(spec, "max", 0)
+	if err != nil {
+		return nil, err
+	}
+	if ceil <= 0 {
+		ceil = time.Duration(math.MaxInt64)
+	}
+
+	jitter := JitterNone
+	if v, ok := spec.Args["jitter"]; ok {
+		jitter = strings.ToLower(v)
+		spec.Args["jitter"] = jitter
+	}
+	if jitter != JitterNone && jitter != JitterFull && jitter != JitterEqual {
+		return nil, argErrorf("jitter", "invalid jitter %q, expected one of none, full, equal", jitter)
+	}
+
+	next := func(attempt int64, rng *rand.Rand) time.Duration {
+		// 计算 base * factor^(attempt-1)，并限制在上限以内
+		// Compute base * factor^(attempt-1) and keep it within the cap
+		d := time.Duration(math.MaxInt64)
+		if v := float64(base) * math.Pow(factor, float64(attempt-1)); v < float64(ceil) {
+			d = time.Duration(v)
+		}
+		if d > ceil {
+			d = ceil
+		}
+
+		switch jitter {
+		case JitterFull:
+			return rngDuration(rng, d)
+		case JitterEqual:
+			half := d / 2
+			return half + rngDuration(rng, d-half)
+		}
+		return d
+	}
+
+	return repeatFactory(spec, func() Backoff {
+		return &statelessBackoff{next: next}
+	}), nil
+}
+
+// buildDecorrelatedSpec 函数构建 decorrelated(base, max) 策略
+// The buildDecorrelatedSpec function builds the decorrelated(base, max) strategy
+func buildDecorrelatedSpec(spec *BackoffSpec) (BackoffFactory, error) {
+	base, err := durationArg(spec, "base", baseInterval)
+	if err != nil {
+		return nil, err
+	}
+	ceil, err := durationArg(spec, "max", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return repeatFactory(spec, NewDecorrelatedJitterFactory(base, ceil)), nil
+}
+
+// buildRandomSpec 函数构建 random(max, min) 策略
+// The buildRandomSpec function builds the random(max, min) strategy
+func buildRandomSpec(spec *BackoffSpec) (BackoffFactory, error) {
+	if _, ok := spec.Args["max"]; !ok {
+		return nil, fmt.Errorf("missing max")
+	}
+
+	ceil, err := durationArg(spec, "max", 0)
+	if err != nil {
+		return nil, err
+	}
+	floor, err := durationArg(spec, "min", 0)
+	if err != nil {
+		return nil, err
+	}
+	if floor > ceil {
+		return nil, argErrorf("min", "min %v is greater than max %v", floor, ceil)
+	}
+
+	return repeatFactory(spec, func() Backoff {
+		return &statelessBackoff{next: func(_ int64, rng *rand.Rand) time.Duration {
+			return floor + rngDuration(rng, ceil-floor)
+		}}
+	}), nil
+}
+
+// buildChainSpec 函数构建 chain(a, b, ...) 策略，依次使用每个子策略，直到子策略要求停止
+// The buildChainSpec function builds the chain(a, b, ...) strategy, which uses each child in turn until the child asks to stop
+func buildChainSpec(spec *BackoffSpec) (BackoffFactory, error) {
+	if len(spec.Children) == 0 {
+		return nil, fmt.Errorf("at least one strategy is required")
+	}
+
+	// 没有重复次数的子策略永远不会停止，因此只能放在最后
+	// A child without a repeat count never stops, so it can only be the last one
+	for _, child := range spec.Children[:len(spec.Children)-1] {
+		if child.Repeat == 0 {
+			return nil, fmt.Errorf("%s has no repeat count, only the last strategy may be unbounded", child.String())
+		}
+	}
+
+	children := spec.Children
+	return repeatFactory(spec, func() Backoff {
+		backoffs := make([]Backoff, len(children))
+		for i, child := range children {
+			backoffs[i] = child.factory()
+		}
+		return &chainBackoff{backoffs: backoffs}
+	}), nil
+}
+
+// repeatFactory 函数在描述带有重复次数时，使用 LimitBackoff 让工厂函数创建的退避策略返回 Repeat 次延迟时间后停止
+// 执行次数从 1 开始，因此 Repeat 次延迟时间对应 Repeat+1 次执行
+// The repeatFactory function uses LimitBackoff to stop the backoffs created by the factory after Repeat delays when the description has a repeat count
+// The execution count starts at 1, so Repeat delays correspond to Repeat+1 executions
+func repeatFactory(spec *BackoffSpec, factory BackoffFactory) BackoffFactory {
+	if spec.Repeat <= 0 || spec.Repeat == math.MaxInt64 {
+		return factory
+	}
+	return LimitBackoff(factory, spec.Repeat+1)
+}
+
+// chainBackoff 依次使用每个退避策略，每个退避策略的执行次数从 1 开始重新计算
+// chainBackoff uses each backoff in turn, the execution count restarts at 1 for every backoff
+type chainBackoff struct {
+	backoffs []Backoff
+	index    int
+	offset   int64
+}
+
+// Next 方法返回当前退避策略的延迟时间，当前退避策略要求停止时切换到下一个
+// The Next method returns the delay of the current backoff, moving on to the next one when the current one asks to stop
+func (b *chainBackoff) Next(attempt int64, lastErr error) (time.Duration, bool) {
+	for b.index < len(b.backoffs) {
+		if delay, ok := b.backoffs[b.index].Next(attempt-b.offset, lastErr); ok {
+			return delay, true
+		}
+		b.index++
+		b.offset = attempt - 1
+	}
+	return 0, false
+}
+
+// Reset 方法重置所有退避策略并从第一个开始
+// The Reset method resets all backoffs and starts from the first one
+func (b *chainBackoff) Reset() {
+	for _, bo := range b.backoffs {
+		bo.Reset()
+	}
+	b.index = 0
+	b.offset = 0
+}
+
+// SetRand 方法将随机数生成器传递给所有退避策略
+// The SetRand method passes the random number generator to all backoffs
+func (b *chainBackoff) SetRand(rng *rand.Rand) {
+	for _, bo := range b.backoffs {
+		setBackoffRand(bo, rng)
+	}
+}
+
+// setBackoffRand 函数在退避策略实现了 RandomizedBackoff 接口时设置随机数生成器
+// The setBackoffRand function sets the random number generator if the backoff implements RandomizedBackoff
+func setBackoffRand(bo Backoff, rng *rand.Rand) {
+	if rb, ok := bo.(RandomizedBackoff); ok {
+		rb.SetRand(rng)
+	}
+}
@@ -0,0 +1,160 @@
+package retry
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBackoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "fixed positional",
+			input:    "fixed(200ms)",
+			expected: "fixed(delay=200ms)",
+		},
+		{
+			name:     "exponential",
+			input:    "exponential(base=100ms,factor=2,max=30s,jitter=full)",
+			expected: "exponential(base=100ms,factor=2,max=30s,jitter=full)",
+		},
+		{
+			name:     "normalized order and spaces",
+			input:    " Exponential( jitter = EQUAL , max=1m, base=0.5s ) ",
+			expected: "exponential(base=500ms,max=1m0s,jitter=equal)",
+		},
+		{
+			name:     "chain",
+			input:    "chain(fixed(200ms)x3, exponential(base=1s,max=10s))",
+			expected: "chain(fixed(delay=200ms)x3,exponential(base=1s,max=10s))",
+		},
+		{
+			name:     "top level repeat",
+			input:    "decorrelated(base=10ms,max=1s)x5",
+			expected: "decorrelated(base=10ms,max=1s)x5",
+		},
+		{
+			name:     "random",
+			input:    "random(max=1s,min=100ms)",
+			expected: "random(max=1s,min=100ms)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := ParseBackoff(tt.input)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, spec.String())
+
+			// 规范化后的描述字符串可以再次解析并得到相同的结果
+			// The normalized spec string can be parsed again with the same result
+			again, err := ParseBackoff(spec.String())
+			assert.NoError(t, err)
+			assert.Equal(t, spec.String(), again.String())
+		})
+	}
+}
+
+func TestParseBackoffErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		pos   int
+		msg   string
+	}{
+		{name: "empty", input: "", pos: 0, msg: "expected strategy name"},
+		{name: "unknown strategy", input: "linear(1s)", pos: 0, msg: `unknown strategy "linear"`},
+		{name: "missing paren", input: "fixed", pos: 5, msg: `expected '(' after "fixed"`},
+		{name: "unclosed", input: "fixed(1s", pos: 8, msg: "missing ')'"},
+		{name: "unknown parameter", input: "exponential(step=1s)", pos: 12, msg: `unknown parameter "step"`},
+		{name: "duplicate parameter", input: "exponential(base=1s,base=2s)", pos: 20, msg: `duplicate parameter "base"`},
+		{name: "bad duration", input: "fixed(soon)", pos: 6, msg: `invalid delay "soon"`},
+		{name: "bad named duration", input: "exponential(base=1s, max=later)", pos: 25, msg: `invalid max "later"`},
+		{name: "bad jitter", input: "exponential(jitter=some)", pos: 19, msg: `invalid jitter "some"`},
+		{name: "small factor", input: "chain(fixed(1s)x2, exponential(factor=0.5))", pos: 38, msg: "must be at least 1"},
+		{name: "random min above max", input: "random(max=1s, min=2s)", pos: 19, msg: "greater than max"},
+		{name: "bad repeat", input: "fixed(1s)x0", pos: 10, msg: "expected positive repeat count"},
+		{name: "unbounded chain element", input: "chain(fixed(1s),fixed(2s))", pos: 0, msg: "only the last strategy may be unbounded"},
+		{name: "trailing", input: "fixed(1s) fixed(2s)", pos: 10, msg: "after end of spec"},
+		{name: "missing random max", input: "random(min=1s)", pos: 0, msg: "missing max"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseBackoff(tt.input)
+
+			var specErr *SpecError
+			assert.True(t, errors.As(err, &specErr))
+			assert.Equal(t, tt.pos, specErr.Pos)
+			assert.Contains(t, specErr.Msg, tt.msg)
+			assert.Contains(t, err.Error(), tt.input)
+		})
+	}
+}
+
+func TestBackoffSpecFactory(t *testing.T) {
+	bo := MustParseBackoff("chain(fixed(200ms)x2, exponential(base=1s,factor=3,max=5s)x3)").Factory()()
+
+	expected := []time.Duration{
+		200 * time.Millisecond,
+		200 * time.Millisecond,
+		time.Second,
+		3 * time.Second,
+		5 * time.Second,
+	}
+	for i, delay := range expected {
+		actual, ok := bo.Next(int64(i+1), nil)
+		assert.True(t, ok)
+		assert.Equal(t, delay, actual)
+	}
+
+	_, ok := bo.Next(int64(len(expected)+1), nil)
+	assert.False(t, ok)
+
+	// 重置后从第一个策略重新开始
+	// After a reset it starts again from the first strategy
+	bo.Reset()
+	actual, ok := bo.Next(1, nil)
+	assert.True(t, ok)
+	assert.Equal(t, 200*time.Millisecond, actual)
+}
+
+func TestBackoffSpecJitter(t *testing.T) {
+	bo := MustParseBackoff("exponential(base=10ms,max=100ms,jitter=full)").Factory()()
+	setBackoffRand(bo, newRand(1))
+
+	for attempt := int64(1); attempt <= 20; attempt++ {
+		delay, ok := bo.Next(attempt, nil)
+		assert.True(t, ok)
+		assert.GreaterOrEqual(t, delay, time.Duration(0))
+		assert.LessOrEqual(t, delay, 100*time.Millisecond)
+	}
+
+	bo = MustParseBackoff("random(min=10ms,max=20ms)").Factory()()
+	for attempt := int64(1); attempt <= 20; attempt++ {
+		delay, _ := bo.Next(attempt, nil)
+		assert.GreaterOrEqual(t, delay, 10*time.Millisecond)
+		assert.LessOrEqual(t, delay, 20*time.Millisecond)
+	}
+}
+
+func TestConfig_WithBackoffSpec(t *testing.T) {
+	spec := MustParseBackoff("fixed(1ms)x1")
+	cfg := NewConfig().WithInitDelay(time.Millisecond).WithBackoffSpec(spec)
+	assert.Equal(t, spec, cfg.BackoffSpec())
+
+	result := Do(func() (any, error) {
+		return nil, errors.New("test")
+	}, cfg)
+	assert.Equal(t, ErrorRetryBackoffStopped, result.TryError())
+	assert.Equal(t, int64(2), result.Count())
+
+	cfg.WithBackoffFactory(FuncBackoff(FixedBackoff))
+	assert.Nil(t, cfg.BackoffSpec())
+}
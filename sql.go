@@ -0,0 +1,573 @@
+package retry
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+)
+
+// 驱动不支持的功能对应的错误
+// Errors for features the driver does not support
+var (
+	errSQLNamedArgs = errors.New("retry: driver does not support named arguments")
+	errSQLTxOptions = errors.New("retry: driver does not support non-default transaction options")
+	errSQLNoRetry   = errors.New("retry: statement may have executed")
+)
+
+// sqlIdempotentKey 类型是标记幂等语句的上下文键
+// The sqlIdempotentKey type is the context key that marks idempotent statements
+type sqlIdempotentKey struct{}
+
+// WithIdempotentSQL 函数返回一个标记了幂等语句的上下文，使用这个上下文执行的事务之外的语句遇到任何临时错误都会被重试
+// 没有这个标记时，语句只在 driver.ErrBadConn 时被重试，因为其他临时错误发生时语句可能已经执行，重试自动提交的非幂等语句会让它执行两次
+// The WithIdempotentSQL function returns a context that marks statements as idempotent, statements outside transactions run with this context are retried on any transient error
+// Without this mark statements are only retried on driver.ErrBadConn, because other transient errors may happen after the statement executed, and retrying a non-idempotent autocommit statement would run it twice
+func WithIdempotentSQL(ctx context.Context) context.Context {
+	return context.WithValue(ctx, sqlIdempotentKey{}, true)
+}
+
+// isIdempotentSQL 函数判断上下文是否标记了幂等语句
+// The isIdempotentSQL function reports whether the context marks statements as idempotent
+func isIdempotentSQL(ctx context.Context) bool {
+	idempotent, _ := ctx.Value(sqlIdempotentKey{}).(bool)
+	return idempotent
+}
+
+// SQLConnector 结构体是一个带重试的 driver.Connector，可以通过 sql.OpenDB 使用
+// 建立连接、准备语句和开始事务遇到临时错误时会在新的连接上重试，事务之外的语句只在 driver.ErrBadConn 时重试，使用 WithIdempotentSQL 标记的语句遇到任何临时错误都会重试
+// 事务中的语句可能已经部分执行，因此不会被重试
+// 临时错误包括 driver.ErrBadConn、连接被重置或拒绝，以及 WithTransientCodes 设置的错误码，错误码由配置中的 ErrorCodeFunc 获取
+// The SQLConnector struct is a driver.Connector with retries that can be used through sql.OpenDB
+// Opening connections, preparing statements and beginning transactions are retried on a new connection when the error is transient, statements outside transactions are only retried on driver.ErrBadConn, and statements marked with WithIdempotentSQL are retried on any transient error
+// Statements inside transactions may have partially executed and are never retried
+// Transient errors are driver.ErrBadConn, reset or refused connections, and the codes set by WithTransientCodes, which are read by the ErrorCodeFunc of the configuration
+type SQLConnector struct {
+	base  driver.Connector
+	retry *Retry
+	codes map[string]struct{}
+	code  ErrorCodeFunc
+}
+
+// NewSQLConnector 函数创建一个新的 SQLConnector 实例，conf 为空时使用默认配置，第一次执行总是立即进行，配置会被复制
+// The NewSQLConnector function creates a new SQLConnector instance, using the default configuration when conf is nil, the first execution always happens immediately and the configuration is copied
+func NewSQLConnector(base driver.Connector, conf *Config) *SQLConnector {
+	if conf == nil {
+		conf = NewConfig()
+	}
+
+	c := &SQLConnector{base: base, codes: make(map[string]struct{}), code: conf.errorCodeFunc}
+	if c.code == nil {
+		c.code = defaultErrorCodeFunc
+	}
+	c.retry = newImmediateRetry(conf, c.transient)
+
+	return c
+}
+
+// WithTransientCodes 方法设置被视为临时错误的错误码并返回 SQLConnector 实例，例如 PostgreSQL 的 "08006"
+// The WithTransientCodes method sets the error codes treated as transient and returns the SQLConnector instance, such as "08006" of PostgreSQL
+func (c *SQLConnector) WithTransientCodes(codes ...string) *SQLConnector {
+	c.codes = make(map[string]struct{}, len(codes))
+	for _, code := range codes {
+		c.codes[code] = struct{}{}
+	}
+	return c
+}
+
+// transient 方法判断错误是否是临时错误
+// The transient method reports whether the error is transient
+func (c *SQLConnector) transient(err error) bool {
+	if errors.Is(err, errSQLNoRetry) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.ErrUnexpectedEOF) || isConnErrno(err) {
+		return true
+	}
+
+	if code := c.code(err); code != "" {
+		_, ok := c.codes[code]
+		return ok
+	}
+
+	return false
+}
+
+// Connect 方法建立连接，遇到临时错误时重试
+// The Connect method opens a connection and retries on transient errors
+func (c *SQLConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn := &sqlConn{connector: c}
+	if err := conn.do(ctx, nil, nil); err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// Driver 方法返回底层的驱动
+// The Driver method returns the underlying driver
+func (c *SQLConnector) Driver() driver.Driver {
+	return c.base.Driver()
+}
+
+// SQLDriver 结构体是一个带重试的 driver.Driver，可以通过 sql.Register 注册，行为与 SQLConnector 相同
+// The SQLDriver struct is a driver.Driver with retries that can be registered through sql.Register, it behaves like SQLConnector
+type SQLDriver struct {
+	base  driver.Driver
+	conf  *Config
+	codes []string
+}
+
+// NewSQLDriver 函数创建一个新的 SQLDriver 实例，conf 为空时使用默认配置，配置会被复制
+// The NewSQLDriver function creates a new SQLDriver instance, using the default configuration when conf is nil, and the configuration is copied
+func NewSQLDriver(base driver.Driver, conf *Config) *SQLDriver {
+	if conf == nil {
+		conf = NewConfig()
+	}
+	return &SQLDriver{base: base, conf: conf.Clone()}
+}
+
+// WithTransientCodes 方法设置被视为临时错误的错误码并返回 SQLDriver 实例
+// The WithTransientCodes method sets the error codes treated as transient and returns the SQLDriver instance
+func (d *SQLDriver) WithTransientCodes(codes ...string) *SQLDriver {
+	d.codes = append([]string(nil), codes...)
+	return d
+}
+
+// Open 方法使用 name 建立一个新的连接
+// The Open method opens a new connection with name
+func (d *SQLDriver) Open(name string) (driver.Conn, error) {
+	connector, err := d.OpenConnector(name)
+	if err != nil {
+		return nil, err
+	}
+	return connector.Connect(context.Background())
+}
+
+// OpenConnector 方法返回使用 name 建立连接的 SQLConnector
+// The OpenConnector method returns an SQLConnector that opens connections with name
+func (d *SQLDriver) OpenConnector(name string) (driver.Connector, error) {
+	var base driver.Connector = &dsnConnector{name: name, driver: d.base}
+	if dc, ok := d.base.(driver.DriverContext); ok {
+		var err error
+		if base, err = dc.OpenConnector(name); err != nil {
+			return nil, err
+		}
+	}
+	return NewSQLConnector(base, d.conf).WithTransientCodes(d.codes...), nil
+}
+
+// dsnConnector 结构体将不支持 DriverContext 的驱动适配为 driver.Connector
+// The dsnConnector struct adapts drivers that do not support DriverContext to driver.Connector
+type dsnConnector struct {
+	name   string
+	driver driver.Driver
+}
+
+func (c *dsnConnector) Connect(context.Context) (driver.Conn, error) { return c.driver.Open(c.name) }
+
+func (c *dsnConnector) Driver() driver.Driver { return c.driver }
+
+// sqlConn 结构体包装了底层的连接，事务之外遇到临时错误时关闭底层的连接并重新建立
+// database/sql 保证一个连接同一时间只被一个 goroutine 使用，因此不需要加锁
+// The sqlConn struct wraps the underlying connection, closing and reopening it on transient errors outside transactions
+// database/sql guarantees that a connection is used by one goroutine at a time, so no locking is needed
+type sqlConn struct {
+	connector *SQLConnector
+	conn      driver.Conn
+	broken    bool   // 底层的连接是否不可用 Whether the underlying connection is unusable
+	inTx      bool   // 是否在事务中 Whether a transaction is in progress
+	gen       uint64 // 重新建立连接的次数，用于重新准备语句 Number of reconnections, used to prepare statements again
+}
+
+// do 方法依次执行 setup 和 exec，两者都可以为空，事务之外遇到临时错误时重新建立连接并重试，事务中只执行一次
+// setup 是建立连接、准备语句这样不会改变数据的阶段，遇到临时错误总是重试；exec 执行语句，只在 driver.ErrBadConn 或者语句被 WithIdempotentSQL 标记时重试
+// The do method runs setup and then exec, both may be nil, reopening the connection and retrying on transient errors outside transactions, and running them only once inside a transaction
+// setup is a phase that changes no data such as connecting or preparing and is always retried on transient errors, exec runs the statement and is only retried on driver.ErrBadConn or when the statement is marked with WithIdempotentSQL
+func (c *sqlConn) do(ctx context.Context, setup, exec func() error) error {
+	if c.inTx {
+		// 事务中不重试，但记录连接不可用，事务结束后的第一次执行会重新建立连接
+		// No retry inside a transaction, but the connection is marked unusable so the first execution after the transaction reopens it
+		err := runSQLPhases(setup, exec)
+		if err != nil && c.connector.transient(err) {
+			c.broken = true
+		}
+		return err
+	}
+
+	var lastErr error
+	result := c.connector.retry.TryOnConflictContext(ctx, func() (any, error) {
+		if c.conn == nil || c.broken {
+			if err := c.reconnect(ctx); err != nil {
+				lastErr = err
+				return nil, err
+			}
+		}
+
+		if setup != nil {
+			if err := setup(); err != nil {
+				if c.connector.transient(err) {
+					c.broken = true
+				}
+				lastErr = err
+				return nil, err
+			}
+		}
+
+		if exec != nil {
+			if err := exec(); err != nil {
+				if c.connector.transient(err) {
+					c.broken = true
+				}
+				lastErr = err
+
+				// 语句可能已经执行，只有驱动确认没有执行或者调用方标记了幂等语句时才重试
+				// The statement may have executed, so it is only retried when the driver confirms it did not or the caller marked it as idempotent
+				if !errors.Is(err, driver.ErrBadConn) && !isIdempotentSQL(ctx) {
+					return nil, errSQLNoRetry
+				}
+				return nil, err
+			}
+		}
+		return nil, nil
+	})
+
+	if result.IsSuccess() {
+		return nil
+	}
+	if lastErr != nil {
+		return lastErr
+	}
+	return result.TryError()
+}
+
+// runSQLPhases 函数依次执行不为空的 setup 和 exec，返回第一个错误
+// The runSQLPhases function runs setup and exec when they are not nil, returning the first error
+func runSQLPhases(setup, exec func() error) error {
+	if setup != nil {
+		if err := setup(); err != nil {
+			return err
+		}
+	}
+	if exec != nil {
+		return exec()
+	}
+	return nil
+}
+
+// reconnect 方法关闭底层的连接并建立一个新的连接
+// The reconnect method closes the underlying connection and opens a new one
+func (c *sqlConn) reconnect(ctx context.Context) error {
+	if c.conn != nil {
+		_ = c.conn.Close()
+		c.conn = nil
+	}
+
+	conn, err := c.connector.base.Connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	c.conn, c.broken = conn, false
+	c.gen++
+	return nil
+}
+
+// Prepare 方法准备一个语句
+// The Prepare method prepares a statement
+func (c *sqlConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+// PrepareContext 方法准备一个语句，遇到临时错误时重试
+// The PrepareContext method prepares a statement and retries on transient errors
+func (c *sqlConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	s := &sqlStmt{conn: c, query: query}
+	if err := c.do(ctx, func() error { return s.prepare(ctx) }, nil); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Close 方法关闭底层的连接
+// The Close method closes the underlying connection
+func (c *sqlConn) Close() error {
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+// Begin 方法开始一个事务
+// The Begin method begins a transaction
+func (c *sqlConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+// BeginTx 方法开始一个事务，遇到临时错误时重试，事务结束之前的语句都不会被重试
+// The BeginTx method begins a transaction and retries on transient errors, no statement is retried until the transaction ends
+func (c *sqlConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	var tx driver.Tx
+	err := c.do(ctx, func() error {
+		var err error
+		if b, ok := c.conn.(driver.ConnBeginTx); ok {
+			tx, err = b.BeginTx(ctx, opts)
+			return err
+		}
+		if opts.Isolation != 0 || opts.ReadOnly {
+			return errSQLTxOptions
+		}
+		tx, err = c.conn.Begin()
+		return err
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	c.inTx = true
+	return &sqlTx{conn: c, tx: tx}, nil
+}
+
+// ExecContext 方法执行一个语句，底层的连接不支持 ExecerContext 时返回 driver.ErrSkip，由 database/sql 改为准备语句后执行
+// The ExecContext method executes a statement, returning driver.ErrSkip when the underlying connection does not support ExecerContext so that database/sql prepares the statement instead
+func (c *sqlConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if _, ok := c.conn.(driver.ExecerContext); !ok && !c.broken {
+		return nil, driver.ErrSkip
+	}
+
+	var res driver.Result
+	err := c.do(ctx, nil, func() error {
+		execer, ok := c.conn.(driver.ExecerContext)
+		if !ok {
+			return driver.ErrSkip
+		}
+		var err error
+		res, err = execer.ExecContext(ctx, query, args)
+		return err
+	})
+	return res, err
+}
+
+// QueryContext 方法执行一个查询，底层的连接不支持 QueryerContext 时返回 driver.ErrSkip
+// The QueryContext method runs a query, returning driver.ErrSkip when the underlying connection does not support QueryerContext
+func (c *sqlConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if _, ok := c.conn.(driver.QueryerContext); !ok && !c.broken {
+		return nil, driver.ErrSkip
+	}
+
+	var rows driver.Rows
+	err := c.do(ctx, nil, func() error {
+		queryer, ok := c.conn.(driver.QueryerContext)
+		if !ok {
+			return driver.ErrSkip
+		}
+		var err error
+		rows, err = queryer.QueryContext(ctx, query, args)
+		return err
+	})
+	return rows, err
+}
+
+// Ping 方法检查连接是否可用，遇到临时错误时重新建立连接并重试
+// The Ping method checks whether the connection is usable, reopening it and retrying on transient errors
+func (c *sqlConn) Ping(ctx context.Context) error {
+	return c.do(ctx, func() error {
+		if pinger, ok := c.conn.(driver.Pinger); ok {
+			return pinger.Ping(ctx)
+		}
+		return nil
+	}, nil)
+}
+
+// ResetSession 方法在连接被重新使用之前调用，底层的连接不可用时返回 driver.ErrBadConn
+// The ResetSession method is called before the connection is reused, returning driver.ErrBadConn when the underlying connection is unusable
+func (c *sqlConn) ResetSession(ctx context.Context) error {
+	if c.broken || c.conn == nil {
+		return driver.ErrBadConn
+	}
+	if resetter, ok := c.conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+// IsValid 方法返回连接是否可以继续使用
+// The IsValid method reports whether the connection can still be used
+func (c *sqlConn) IsValid() bool {
+	if c.broken || c.conn == nil {
+		return false
+	}
+	if validator, ok := c.conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+// CheckNamedValue 方法使用底层连接的 NamedValueChecker 检查参数，不支持时使用默认的转换
+// The CheckNamedValue method checks arguments with the NamedValueChecker of the underlying connection, falling back to the default conversion
+func (c *sqlConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := c.conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// sqlTx 结构体包装了底层的事务，事务结束后连接上的语句重新可以被重试
+// The sqlTx struct wraps the underlying transaction, statements on the connection can be retried again once it ends
+type sqlTx struct {
+	conn *sqlConn
+	tx   driver.Tx
+}
+
+func (t *sqlTx) Commit() error {
+	t.conn.inTx = false
+	return t.tx.Commit()
+}
+
+func (t *sqlTx) Rollback() error {
+	t.conn.inTx = false
+	return t.tx.Rollback()
+}
+
+// sqlStmt 结构体包装了底层的语句，重新建立连接后会在新的连接上重新准备语句
+// The sqlStmt struct wraps the underlying statement and prepares it again on the new connection after a reconnection
+type sqlStmt struct {
+	conn  *sqlConn
+	query string
+	stmt  driver.Stmt
+	gen   uint64
+}
+
+// prepare 方法在当前的连接上准备语句
+// The prepare method prepares the statement on the current connection
+func (s *sqlStmt) prepare(ctx context.Context) error {
+	if s.stmt != nil {
+		_ = s.stmt.Close()
+		s.stmt = nil
+	}
+
+	var (
+		stmt driver.Stmt
+		err  error
+	)
+	if p, ok := s.conn.conn.(driver.ConnPrepareContext); ok {
+		stmt, err = p.PrepareContext(ctx, s.query)
+	} else {
+		stmt, err = s.conn.conn.Prepare(s.query)
+	}
+	if err != nil {
+		return err
+	}
+
+	s.stmt, s.gen = stmt, s.conn.gen
+	return nil
+}
+
+// ensure 方法确保语句已经在当前的连接上准备好，连接被重新建立过时重新准备
+// The ensure method makes sure the statement is prepared on the current connection, preparing it again if the connection was reopened
+func (s *sqlStmt) ensure(ctx context.Context) error {
+	if s.stmt == nil || s.gen != s.conn.gen {
+		return s.prepare(ctx)
+	}
+	return nil
+}
+
+func (s *sqlStmt) Close() error {
+	if s.stmt == nil {
+		return nil
+	}
+	err := s.stmt.Close()
+	s.stmt = nil
+	return err
+}
+
+func (s *sqlStmt) NumInput() int {
+	if s.stmt == nil {
+		return -1
+	}
+	return s.stmt.NumInput()
+}
+
+func (s *sqlStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), valuesToNamed(args))
+}
+
+func (s *sqlStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), valuesToNamed(args))
+}
+
+// ExecContext 方法执行语句，事务之外准备语句遇到临时错误时重试，执行语句只在 driver.ErrBadConn 或者语句被 WithIdempotentSQL 标记时重试
+// The ExecContext method executes the statement, outside transactions preparing it is retried on transient errors and executing it only on driver.ErrBadConn or when it is marked with WithIdempotentSQL
+func (s *sqlStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	var res driver.Result
+	err := s.conn.do(ctx, func() error { return s.ensure(ctx) }, func() error {
+		stmt := s.stmt
+		if e, ok := stmt.(driver.StmtExecContext); ok {
+			var err error
+			res, err = e.ExecContext(ctx, args)
+			return err
+		}
+		values, err := namedToValues(args)
+		if err != nil {
+			return err
+		}
+		res, err = stmt.Exec(values)
+		return err
+	})
+	return res, err
+}
+
+// QueryContext 方法执行查询，重试的规则与 ExecContext 相同
+// The QueryContext method runs the query, retried under the same rules as ExecContext
+func (s *sqlStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	var rows driver.Rows
+	err := s.conn.do(ctx, func() error { return s.ensure(ctx) }, func() error {
+		stmt := s.stmt
+		if q, ok := stmt.(driver.StmtQueryContext); ok {
+			var err error
+			rows, err = q.QueryContext(ctx, args)
+			return err
+		}
+		values, err := namedToValues(args)
+		if err != nil {
+			return err
+		}
+		rows, err = stmt.Query(values)
+		return err
+	})
+	return rows, err
+}
+
+// CheckNamedValue 方法使用连接的 CheckNamedValue 检查参数
+// The CheckNamedValue method checks arguments with the CheckNamedValue of the connection
+func (s *sqlStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := s.stmt.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return s.conn.CheckNamedValue(nv)
+}
+
+// namedToValues 函数将 NamedValue 转换为 Value，驱动不支持命名参数
+// The namedToValues function converts NamedValue to Value, the driver does not support named arguments
+func namedToValues(named []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(named))
+	for i, nv := range named {
+		if nv.Name != "" {
+			return nil, errSQLNamedArgs
+		}
+		values[i] = nv.Value
+	}
+	return values, nil
+}
+
+// valuesToNamed 函数将 Value 转换为按位置编号的 NamedValue
+// The valuesToNamed function converts Value to NamedValue numbered by position
+func valuesToNamed(values []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(values))
+	for i, v := range values {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return named
+}
@@ -0,0 +1,336 @@
+package retry
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeDB 结构体是一个内存中的假数据库，按顺序返回预先设置的错误
+// The fakeDB struct is an in-memory fake database that returns preset errors in order
+type fakeDB struct {
+	mu         sync.Mutex
+	opens      int
+	openErrs   []error
+	execErrs   []error
+	commitErrs []error
+	executed   []string
+	prepares   int
+	noExecer   bool
+	committed  int
+	rolledBack int
+}
+
+func (db *fakeDB) pop(errs *[]error) error {
+	if len(*errs) == 0 {
+		return nil
+	}
+	err := (*errs)[0]
+	*errs = (*errs)[1:]
+	return err
+}
+
+func (db *fakeDB) Open(string) (driver.Conn, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.opens++
+	if err := db.pop(&db.openErrs); err != nil {
+		return nil, err
+	}
+	if db.noExecer {
+		return &fakeBasicConn{db: db}, nil
+	}
+	return &fakeConn{fakeBasicConn{db: db}}, nil
+}
+
+func (db *fakeDB) exec(query string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if err := db.pop(&db.execErrs); err != nil {
+		return err
+	}
+	db.executed = append(db.executed, query)
+	return nil
+}
+
+type fakeBasicConn struct {
+	db     *fakeDB
+	closed bool
+}
+
+func (c *fakeBasicConn) Prepare(query string) (driver.Stmt, error) {
+	c.db.mu.Lock()
+	c.db.prepares++
+	c.db.mu.Unlock()
+	return &fakeStmt{conn: c, query: query}, nil
+}
+
+func (c *fakeBasicConn) Close() error {
+	c.closed = true
+	return nil
+}
+
+func (c *fakeBasicConn) Begin() (driver.Tx, error) {
+	return &fakeTx{db: c.db}, nil
+}
+
+type fakeConn struct {
+	fakeBasicConn
+}
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	if err := c.db.exec(query); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	if err := c.db.exec(query); err != nil {
+		return nil, err
+	}
+	return &fakeRows{}, nil
+}
+
+type fakeStmt struct {
+	conn  *fakeBasicConn
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec([]driver.Value) (driver.Result, error) {
+	if s.conn.closed {
+		return nil, errors.New("statement used on a closed connection")
+	}
+	if err := s.conn.db.exec(s.query); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	if err := s.conn.db.exec(s.query); err != nil {
+		return nil, err
+	}
+	return &fakeRows{}, nil
+}
+
+type fakeTx struct {
+	db *fakeDB
+}
+
+func (t *fakeTx) Commit() error {
+	t.db.mu.Lock()
+	defer t.db.mu.Unlock()
+
+	if err := t.db.pop(&t.db.commitErrs); err != nil {
+		return err
+	}
+	t.db.committed++
+	return nil
+}
+
+func (t *fakeTx) Rollback() error {
+	t.db.mu.Lock()
+	t.db.rolledBack++
+	t.db.mu.Unlock()
+	return nil
+}
+
+type fakeRows struct {
+	done bool
+}
+
+func (r *fakeRows) Columns() []string { return []string{"n"} }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = int64(1)
+	return nil
+}
+
+func newTestSQLConfig() *Config {
+	return NewConfig().
+		WithInitDelay(time.Millisecond).
+		WithBackOffFunc(func(int64) time.Duration { return 0 })
+}
+
+func openTestDB(fake *fakeDB, conf *Config, codes ...string) *sql.DB {
+	connector, _ := NewSQLDriver(fake, conf).WithTransientCodes(codes...).OpenConnector("fake")
+	db := sql.OpenDB(connector)
+	db.SetMaxOpenConns(1)
+	return db
+}
+
+func TestSQLConnector_Connect(t *testing.T) {
+	fake := &fakeDB{openErrs: []error{syscall.ECONNREFUSED, syscall.ECONNREFUSED}}
+	db := openTestDB(fake, newTestSQLConfig())
+	defer db.Close()
+
+	assert.NoError(t, db.Ping())
+	assert.Equal(t, 3, fake.opens)
+
+	// 不是临时错误时不重试
+	// Errors that are not transient are not retried
+	fake = &fakeDB{openErrs: []error{errors.New("password authentication failed")}}
+	db2 := openTestDB(fake, newTestSQLConfig())
+	defer db2.Close()
+
+	assert.EqualError(t, db2.Ping(), "password authentication failed")
+	assert.Equal(t, 1, fake.opens)
+}
+
+func TestSQLConnector_ExecRetry(t *testing.T) {
+	fake := &fakeDB{execErrs: []error{driver.ErrBadConn, driver.ErrBadConn}}
+	db := openTestDB(fake, newTestSQLConfig())
+	defer db.Close()
+
+	res, err := db.Exec("UPDATE accounts SET balance = 1")
+	assert.NoError(t, err)
+	n, _ := res.RowsAffected()
+	assert.Equal(t, int64(1), n)
+
+	// 每次 driver.ErrBadConn 之后都会重新建立连接
+	// The connection is reopened after every driver.ErrBadConn
+	assert.Equal(t, 3, fake.opens)
+	assert.Equal(t, []string{"UPDATE accounts SET balance = 1"}, fake.executed)
+
+	// 语句可能已经执行，其他临时错误不会被重试，但连接会在下一次执行时重新建立
+	// The statement may have executed, so other transient errors are not retried, but the connection is reopened on the next execution
+	fake.execErrs = []error{syscall.ECONNRESET}
+	_, err = db.Exec("INSERT INTO payments VALUES (1)")
+	assert.True(t, errors.Is(err, syscall.ECONNRESET))
+	assert.Len(t, fake.executed, 1)
+	assert.Equal(t, 3, fake.opens)
+
+	var count int
+	fake.execErrs = []error{io.ErrUnexpectedEOF}
+	assert.ErrorIs(t, db.QueryRow("SELECT 1").Scan(&count), io.ErrUnexpectedEOF)
+	assert.Equal(t, 4, fake.opens)
+}
+
+func TestSQLConnector_IdempotentRetry(t *testing.T) {
+	fake := &fakeDB{execErrs: []error{syscall.ECONNRESET, syscall.EPIPE}}
+	db := openTestDB(fake, newTestSQLConfig())
+	defer db.Close()
+
+	// 标记为幂等的语句遇到任何临时错误都会被重试
+	// Statements marked as idempotent are retried on any transient error
+	ctx := WithIdempotentSQL(context.Background())
+	_, err := db.ExecContext(ctx, "UPDATE accounts SET balance = 1")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, fake.opens)
+	assert.Equal(t, []string{"UPDATE accounts SET balance = 1"}, fake.executed)
+
+	var count int
+	fake.execErrs = []error{io.ErrUnexpectedEOF}
+	assert.NoError(t, db.QueryRowContext(ctx, "SELECT 1").Scan(&count))
+	assert.Equal(t, 1, count)
+	assert.Equal(t, 4, fake.opens)
+}
+
+func TestSQLConnector_ConnErrnos(t *testing.T) {
+	c := NewSQLConnector(nil, newTestSQLConfig())
+
+	// 当前平台的连接错误码都是临时错误，包括 Windows 上的 Winsock 错误码
+	// The connection error numbers of the current platform are all transient, including the Winsock error numbers on Windows
+	for _, errno := range connErrnos {
+		assert.True(t, c.transient(&net.OpError{Op: "read", Err: os.NewSyscallError("read", errno)}), errno.Error())
+	}
+	assert.False(t, c.transient(syscall.ENOENT))
+}
+
+func TestSQLConnector_TransientCodes(t *testing.T) {
+	fake := &fakeDB{execErrs: []error{&codeError{code: "08006"}}}
+	db := openTestDB(fake, newTestSQLConfig(), "08006")
+	defer db.Close()
+
+	_, err := db.ExecContext(WithIdempotentSQL(context.Background()), "DELETE FROM sessions")
+	assert.NoError(t, err)
+	assert.Len(t, fake.executed, 1)
+
+	// 没有设置的错误码不会被重试
+	// Codes that were not configured are not retried
+	fake.execErrs = []error{&codeError{code: "23505"}}
+	_, err = db.Exec("INSERT INTO users VALUES (1)")
+	assert.Equal(t, &codeError{code: "23505"}, err)
+	assert.Len(t, fake.executed, 1)
+}
+
+func TestSQLConnector_AttemptsExceeded(t *testing.T) {
+	fake := &fakeDB{execErrs: []error{syscall.ECONNRESET, syscall.ECONNRESET, syscall.ECONNRESET, syscall.ECONNRESET}}
+	db := openTestDB(fake, newTestSQLConfig().WithAttempts(2))
+	defer db.Close()
+
+	_, err := db.ExecContext(WithIdempotentSQL(context.Background()), "UPDATE accounts SET balance = 1")
+	assert.True(t, errors.Is(err, syscall.ECONNRESET))
+	assert.Empty(t, fake.executed)
+}
+
+func TestSQLConnector_NoRetryInTx(t *testing.T) {
+	fake := &fakeDB{}
+	db := openTestDB(fake, newTestSQLConfig())
+	defer db.Close()
+
+	tx, err := db.Begin()
+	assert.NoError(t, err)
+
+	// 事务中的语句可能已经部分执行，因此不会被重试
+	// Statements inside a transaction may have partially executed, so they are not retried
+	fake.execErrs = []error{syscall.ECONNRESET}
+	_, err = tx.Exec("UPDATE accounts SET balance = balance - 1")
+	assert.True(t, errors.Is(err, syscall.ECONNRESET))
+	assert.Empty(t, fake.executed)
+	assert.NoError(t, tx.Rollback())
+	assert.Equal(t, 1, fake.rolledBack)
+
+	// 事务结束之后语句重新可以被重试
+	// Statements can be retried again once the transaction ends
+	fake.execErrs = []error{driver.ErrBadConn}
+	_, err = db.Exec("UPDATE accounts SET balance = balance - 1")
+	assert.NoError(t, err)
+	assert.Len(t, fake.executed, 1)
+}
+
+func TestSQLConnector_PreparedStatement(t *testing.T) {
+	fake := &fakeDB{noExecer: true}
+	db := openTestDB(fake, newTestSQLConfig())
+	defer db.Close()
+
+	stmt, err := db.Prepare("UPDATE accounts SET balance = ?")
+	assert.NoError(t, err)
+	defer stmt.Close()
+
+	// 重新建立连接之后语句会在新的连接上重新准备
+	// The statement is prepared again on the new connection after a reconnection
+	fake.execErrs = []error{driver.ErrBadConn}
+	_, err = stmt.Exec(1)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, fake.opens)
+	assert.Equal(t, 2, fake.prepares)
+	assert.Len(t, fake.executed, 1)
+
+	// 底层连接不支持 ExecerContext 时由 database/sql 准备语句后执行
+	// database/sql prepares the statement when the underlying connection does not support ExecerContext
+	_, err = db.Exec("DELETE FROM sessions")
+	assert.NoError(t, err)
+	assert.Len(t, fake.executed, 2)
+}
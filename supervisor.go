@@ -0,0 +1,158 @@
+package retry
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// 默认的稳定运行时间，任务运行超过这个时间后退避策略被重置
+// Default stable period, the backoff is reset after the task runs longer than this
+const defaultStableAfter = time.Minute
+
+// TaskFunc 类型定义了被监督的长期运行的任务，任务应该在上下文结束时返回
+// The TaskFunc type defines a long-lived supervised task, the task should return when the context is done
+type TaskFunc = func(ctx context.Context) error
+
+// Supervisor 结构体持续运行一个任务，例如消息消费者或 websocket 客户端，任务返回错误或 panic 时按退避策略重新启动
+// 任务稳定运行超过配置的时间后退避策略被重置，默认不限制连续失败的次数，显式设置的重试次数限制连续失败的次数，重新启动的次数可以被多个 goroutine 并发读取
+// The Supervisor struct keeps a task running, such as a message consumer or a websocket client, restarting it with backoff when it returns an error or panics
+// The backoff is reset once the task has run stably for the configured period, consecutive failures are unlimited by default and limited by explicitly set attempts, and the restart count can be read concurrently by multiple goroutines
+type Supervisor struct {
+	task        TaskFunc
+	retry       *Retry
+	stableAfter time.Duration
+	restarts    int64
+	now         func() time.Time
+}
+
+// NewSupervisor 函数创建一个新的 Supervisor 实例，conf 为空时使用默认配置，配置会被复制
+// 任务第一次立即启动，默认稳定运行一分钟后重置退避策略
+// The NewSupervisor function creates a new Supervisor instance, using the default configuration when conf is nil, and the configuration is copied
+// The task starts immediately the first time, and by default the backoff is reset after one minute of stable running
+func NewSupervisor(task TaskFunc, conf *Config) *Supervisor {
+	return &Supervisor{
+		task:        task,
+		retry:       newImmediateRetry(conf, defaultRetryIfFunc),
+		stableAfter: defaultStableAfter,
+		now:         time.Now,
+	}
+}
+
+// WithStableAfter 方法设置稳定运行的时间并返回 Supervisor 实例，任务运行超过这个时间后才失败时，退避策略和连续失败的次数被重置
+// The WithStableAfter method sets the stable period and returns the Supervisor instance, when the task fails after running longer than this, the backoff and the consecutive failure count are reset
+func (s *Supervisor) WithStableAfter(stableAfter time.Duration) *Supervisor {
+	if stableAfter > 0 {
+		s.stableAfter = stableAfter
+	}
+	return s
+}
+
+// Restarts 方法返回任务被重新启动的总次数
+// The Restarts method returns the total number of times the task has been restarted
+func (s *Supervisor) Restarts() int64 {
+	return atomic.LoadInt64(&s.restarts)
+}
+
+// Run 方法运行任务直到它返回 nil、返回 RetryIfFunc 不允许重试的错误或者上下文结束，配置的超时时间限制整个运行时间
+// 每次重新启动之前调用配置的回调函数，传入重新启动的总次数、延迟时间和任务的错误
+// 任务返回 nil 时返回 nil，上下文结束时返回上下文的错误，默认不限制连续失败的次数，显式设置的重试次数用完时返回包装了 ErrorRetryAttemptsExceeded 的错误，退避策略要求停止时返回包装了 ErrorRetryBackoffStopped 的错误
+// The Run method runs the task until it returns nil, returns an error RetryIfFunc does not allow to retry or the context is done, and the configured timeout bounds the whole run
+// The configured callback is called before every restart with the total restart count, the delay and the error of the task
+// It returns nil when the task returns nil, the context error when the context is done, consecutive failures are unlimited by default and it returns an error wrapping ErrorRetryAttemptsExceeded when explicitly set attempts run out, and an error wrapping ErrorRetryBackoffStopped when the backoff asks to stop
+// 包装的错误同时包装任务最后一次的错误，errors.Is 和 errors.As 可以用于两者
+// The wrapping errors also wrap the last error of the task, so errors.Is and errors.As work for both
+func (s *Supervisor) Run(ctx context.Context) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	conf := s.retry.config
+	if conf.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, conf.timeout)
+		defer cancel()
+	}
+
+	bo := s.retry.newBackoff()
+	var failures int64
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		start := s.now()
+		err := runTask(ctx, s.task)
+
+		// 上下文结束时任务的错误通常只是上下文的错误，直接停止
+		// When the context is done the error of the task is usually just the context error, so stop directly
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err == nil {
+			return nil
+		}
+		if !conf.retryIfFunc(err) {
+			return err
+		}
+
+		// 稳定运行之后的失败重新开始计算退避和连续失败的次数
+		// A failure after a stable run starts the backoff and the consecutive failure count over
+		if s.now().Sub(start) >= s.stableAfter {
+			bo.Reset()
+			failures = 0
+		}
+		failures++
+
+		if limit := failureLimit(conf); limit > 0 && uint64(failures) >= limit {
+			return newCauseError(ErrorRetryAttemptsExceeded, err, "%v after %d consecutive failures: %v", ErrorRetryAttemptsExceeded, failures, err)
+		}
+
+		delay, ok := s.retry.nextDelay(bo, failures, err)
+		if !ok {
+			return newCauseError(ErrorRetryBackoffStopped, err, "%v: %v", ErrorRetryBackoffStopped, err)
+		}
+
+		conf.callback.OnRetry(atomic.AddInt64(&s.restarts, 1), delay, err)
+
+		if err := sleepContext(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// failureLimit 函数返回监督者允许的连续失败次数，只有通过 WithAttempts、JSON 或环境变量显式设置重试次数时才有限制，0 表示不限制
+// The failureLimit function returns the consecutive failures a supervisor allows, there is only a limit when the attempts were set explicitly through WithAttempts, JSON or environment variables, and 0 means unlimited
+func failureLimit(conf *Config) uint64 {
+	if !conf.attemptsSet {
+		return 0
+	}
+	return conf.attempts
+}
+
+// runTask 函数运行一次任务，并把 panic 转换为包装了 ErrorTaskPanicked 的错误
+// The runTask function runs the task once, turning a panic into an error wrapping ErrorTaskPanicked
+func runTask(ctx context.Context, task TaskFunc) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("%w: %v", ErrorTaskPanicked, p)
+		}
+	}()
+	return task(ctx)
+}
+
+// sleepContext 函数等待 delay 时间，上下文先结束时返回上下文的错误
+// The sleepContext function waits for delay, returning the context error when the context is done first
+func sleepContext(ctx context.Context, delay time.Duration) error {
+	tr := time.NewTimer(delay)
+	defer tr.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-tr.C:
+		return nil
+	}
+}
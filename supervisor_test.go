@@ -0,0 +1,177 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingBackoff 记录每次调用的执行次数和重置的次数
+// recordingBackoff records the attempt of every call and the number of resets
+type recordingBackoff struct {
+	attempts []int64
+	resets   int
+}
+
+func (b *recordingBackoff) Next(attempt int64, _ error) (time.Duration, bool) {
+	b.attempts = append(b.attempts, attempt)
+	return 0, true
+}
+
+func (b *recordingBackoff) Reset() { b.resets++ }
+
+func newSupervisorConfig() *Config {
+	return NewConfig().
+		WithInitDelay(time.Millisecond).
+		WithBackOffFunc(func(int64) time.Duration { return 0 }).
+		WithAttempts(10)
+}
+
+func TestSupervisor_RestartUntilDone(t *testing.T) {
+	cb := &delayCallback{}
+	runs := 0
+	s := NewSupervisor(func(ctx context.Context) error {
+		runs++
+		if runs < 3 {
+			return errors.New("disconnected")
+		}
+		return nil
+	}, newSupervisorConfig().WithCallback(cb))
+
+	assert.NoError(t, s.Run(context.Background()))
+	assert.Equal(t, 3, runs)
+	assert.Equal(t, int64(2), s.Restarts())
+	assert.Len(t, cb.delays, 2)
+}
+
+func TestSupervisor_AttemptsExceeded(t *testing.T) {
+	errDisconnected := errors.New("disconnected")
+	runs := 0
+	s := NewSupervisor(func(ctx context.Context) error {
+		runs++
+		return errDisconnected
+	}, newSupervisorConfig().WithAttempts(4))
+
+	// 返回的错误同时包装哨兵错误和任务的错误
+	// The returned error wraps both the sentinel error and the error of the task
+	err := s.Run(context.Background())
+	assert.ErrorIs(t, err, ErrorRetryAttemptsExceeded)
+	assert.ErrorIs(t, err, errDisconnected)
+	assert.Contains(t, err.Error(), "disconnected")
+	assert.Equal(t, 4, runs)
+	assert.Equal(t, int64(3), s.Restarts())
+}
+
+func TestSupervisor_UnlimitedByDefault(t *testing.T) {
+	runs := 0
+
+	// 没有显式设置重试次数时，连续失败超过默认的重试次数也会继续重新启动
+	// Without explicitly set attempts, the task keeps being restarted past the default attempts
+	s := NewSupervisor(func(ctx context.Context) error {
+		runs++
+		if runs <= 8 {
+			return errors.New("disconnected")
+		}
+		return nil
+	}, NewConfig().WithInitDelay(time.Millisecond).WithBackOffFunc(func(int64) time.Duration { return 0 }))
+
+	assert.NoError(t, s.Run(context.Background()))
+	assert.Equal(t, 9, runs)
+	assert.Equal(t, int64(8), s.Restarts())
+}
+
+func TestSupervisor_StableReset(t *testing.T) {
+	bo := &recordingBackoff{}
+	now := time.Now()
+	runs := 0
+
+	// 第三次运行是稳定的，之后的失败重新开始计算
+	// The third run is stable, so the failures after it start over
+	s := NewSupervisor(func(ctx context.Context) error {
+		runs++
+		if runs == 3 {
+			now = now.Add(time.Hour)
+		}
+		if runs == 6 {
+			return nil
+		}
+		return errors.New("disconnected")
+	}, newSupervisorConfig().WithAttempts(4).WithBackoffFactory(func() Backoff { return bo }))
+	s.now = func() time.Time { return now }
+
+	assert.NoError(t, s.Run(context.Background()))
+	assert.Equal(t, 6, runs)
+	assert.Equal(t, []int64{1, 2, 1, 2, 3}, bo.attempts)
+	assert.Equal(t, 1, bo.resets)
+}
+
+func TestSupervisor_BackoffStopped(t *testing.T) {
+	errTask := &codeError{code: "ECONNRESET"}
+	s := NewSupervisor(func(ctx context.Context) error {
+		return errTask
+	}, newSupervisorConfig().WithBackoffFactory(LimitBackoff(FuncBackoff(func(int64) time.Duration { return 0 }), 2)))
+
+	err := s.Run(context.Background())
+	assert.ErrorIs(t, err, ErrorRetryBackoffStopped)
+	assert.ErrorIs(t, err, errTask)
+
+	var target *codeError
+	assert.True(t, errors.As(err, &target))
+	assert.Equal(t, "ECONNRESET", target.code)
+}
+
+func TestSupervisor_FatalError(t *testing.T) {
+	errFatal := errors.New("fatal")
+	s := NewSupervisor(func(ctx context.Context) error {
+		return errFatal
+	}, newSupervisorConfig().WithRetryIfFunc(func(err error) bool { return !errors.Is(err, errFatal) }))
+
+	assert.Equal(t, errFatal, s.Run(context.Background()))
+	assert.Equal(t, int64(0), s.Restarts())
+}
+
+func TestSupervisor_Panic(t *testing.T) {
+	runs := 0
+	s := NewSupervisor(func(ctx context.Context) error {
+		runs++
+		if runs == 1 {
+			panic("boom")
+		}
+		return nil
+	}, newSupervisorConfig())
+
+	assert.NoError(t, s.Run(context.Background()))
+	assert.Equal(t, int64(1), s.Restarts())
+
+	err := runTask(context.Background(), func(ctx context.Context) error { panic("boom") })
+	assert.ErrorIs(t, err, ErrorTaskPanicked)
+	assert.Contains(t, err.Error(), "boom")
+}
+
+func TestSupervisor_ContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+
+	s := NewSupervisor(func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return errors.New("connection closed")
+	}, newSupervisorConfig())
+
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx) }()
+
+	<-started
+	cancel()
+
+	select {
+	case err := <-done:
+		assert.Equal(t, context.Canceled, err)
+	case <-time.After(time.Second):
+		t.Fatal("supervisor did not stop")
+	}
+	assert.Equal(t, int64(0), s.Restarts())
+}
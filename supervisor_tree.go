@@ -0,0 +1,431 @@
+package retry
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// 默认的重新启动强度和停止超时时间
+// Default restart intensity and shutdown timeout
+const (
+	defaultMaxRestarts     = 3               // 时间窗口内最多重新启动的次数 Maximum restarts within the period
+	defaultRestartPeriod   = 5 * time.Second // 重新启动强度的时间窗口 Period of the restart intensity
+	defaultShutdownTimeout = 5 * time.Second // 等待子任务停止的时间 How long to wait for a child to stop
+)
+
+// RestartStrategy 类型表示子任务失败时监督树重新启动哪些子任务
+// The RestartStrategy type represents which children a supervision tree restarts when a child fails
+type RestartStrategy int
+
+const (
+	OneForOne  RestartStrategy = iota // 只重新启动失败的子任务 Only the failed child is restarted
+	OneForAll                         // 重新启动所有子任务 Every child is restarted
+	RestForOne                        // 重新启动失败的子任务和在它之后添加的子任务 The failed child and the children added after it are restarted
+)
+
+// String 方法返回重新启动策略的名称
+// The String method returns the name of the restart strategy
+func (s RestartStrategy) String() string {
+	switch s {
+	case OneForOne:
+		return "one-for-one"
+	case OneForAll:
+		return "one-for-all"
+	case RestForOne:
+		return "rest-for-one"
+	default:
+		return "unknown"
+	}
+}
+
+// Child 结构体是监督树中的一个子任务，使用自己的配置计算重新启动的延迟，重新启动的次数可以被多个 goroutine 并发读取
+// The Child struct is a child task of a supervision tree that uses its own configuration for the restart delay, and the restart count can be read concurrently by multiple goroutines
+type Child struct {
+	name            string
+	task            TaskFunc
+	retry           *Retry
+	shutdownTimeout time.Duration
+	restarts        int64
+}
+
+// NewChild 函数创建一个新的子任务，conf 为空时使用默认配置，配置会被复制，默认等待 5 秒让子任务停止
+// 配置的 RetryIfFunc 拒绝的错误和显式设置的重试次数用完都会让整个监督树停止，没有显式设置重试次数时只由监督树的重新启动强度限制，另一个 SupervisorTree 的 Run 方法也可以作为子任务
+// The NewChild function creates a new child task, using the default configuration when conf is nil, the configuration is copied, and by default the child is given 5 seconds to stop
+// Errors rejected by the configured RetryIfFunc and running out of explicitly set attempts both stop the whole tree, only the restart intensity of the tree applies when no attempts are set, and the Run method of another SupervisorTree can be used as a child task as well
+func NewChild(name string, task TaskFunc, conf *Config) *Child {
+	return &Child{
+		name:            name,
+		task:            task,
+		retry:           newImmediateRetry(conf, defaultRetryIfFunc),
+		shutdownTimeout: defaultShutdownTimeout,
+	}
+}
+
+// WithShutdownTimeout 方法设置等待子任务停止的时间并返回 Child 实例，超时后监督树不再等待它
+// The WithShutdownTimeout method sets how long to wait for the child to stop and returns the Child instance, the tree stops waiting for it after the timeout
+func (c *Child) WithShutdownTimeout(timeout time.Duration) *Child {
+	if timeout > 0 {
+		c.shutdownTimeout = timeout
+	}
+	return c
+}
+
+// Name 方法返回子任务的名称
+// The Name method returns the name of the child
+func (c *Child) Name() string {
+	return c.name
+}
+
+// Restarts 方法返回子任务被重新启动的总次数，包括因为其他子任务失败而被重新启动的次数
+// The Restarts method returns the total number of times the child has been restarted, including restarts caused by other children failing
+func (c *Child) Restarts() int64 {
+	return atomic.LoadInt64(&c.restarts)
+}
+
+// SupervisorTree 结构体按 Erlang 的方式监督一组子任务：子任务失败时按重新启动策略重新启动，时间窗口内重新启动的次数超过限制时停止并把错误交给上一级
+// 子任务按添加的顺序启动，按相反的顺序停止，子任务需要在 Run 之前添加，Run 同一时间只能被调用一次
+// The SupervisorTree struct supervises a group of children the Erlang way: failed children are restarted following the restart strategy, and the tree stops and escalates to its parent when it restarts too often within the period
+// Children start in the order they were added and stop in reverse order, children must be added before Run, and Run must not be called concurrently
+type SupervisorTree struct {
+	strategy    RestartStrategy
+	children    []*Child
+	maxRestarts int
+	period      time.Duration
+	stableAfter time.Duration
+	now         func() time.Time
+}
+
+// NewSupervisorTree 函数创建一个新的 SupervisorTree 实例，默认 5 秒内最多重新启动 3 次，子任务稳定运行一分钟后重置它的退避策略
+// The NewSupervisorTree function creates a new SupervisorTree instance, by default at most 3 restarts are allowed within 5 seconds and the backoff of a child is reset after one minute of stable running
+func NewSupervisorTree(strategy RestartStrategy) *SupervisorTree {
+	return &SupervisorTree{
+		strategy:    strategy,
+		maxRestarts: defaultMaxRestarts,
+		period:      defaultRestartPeriod,
+		stableAfter: defaultStableAfter,
+		now:         time.Now,
+	}
+}
+
+// WithIntensity 方法设置重新启动的强度并返回 SupervisorTree 实例，period 时间内最多重新启动 maxRestarts 次，maxRestarts 为 0 时第一次失败就停止
+// The WithIntensity method sets the restart intensity and returns the SupervisorTree instance, at most maxRestarts restarts are allowed within period, a maxRestarts of 0 stops on the first failure
+func (t *SupervisorTree) WithIntensity(maxRestarts int, period time.Duration) *SupervisorTree {
+	if maxRestarts >= 0 && period > 0 {
+		t.maxRestarts = maxRestarts
+		t.period = period
+	}
+	return t
+}
+
+// WithStableAfter 方法设置稳定运行的时间并返回 SupervisorTree 实例，子任务运行超过这个时间后才失败时，它的退避策略和连续失败的次数被重置
+// The WithStableAfter method sets the stable period and returns the SupervisorTree instance, when a child fails after running longer than this, its backoff and consecutive failure count are reset
+func (t *SupervisorTree) WithStableAfter(stableAfter time.Duration) *SupervisorTree {
+	if stableAfter > 0 {
+		t.stableAfter = stableAfter
+	}
+	return t
+}
+
+// AddChild 方法按顺序添加子任务并返回 SupervisorTree 实例
+// The AddChild method adds children in order and returns the SupervisorTree instance
+func (t *SupervisorTree) AddChild(children ...*Child) *SupervisorTree {
+	t.children = append(t.children, children...)
+	return t
+}
+
+// childRun 结构体保存一次 Run 中一个子任务的运行状态
+// The childRun struct keeps the running state of a child within one Run
+type childRun struct {
+	child    *Child
+	bo       Backoff
+	failures int64
+	gen      int // 每次启动加一，用于忽略已经停止的实例的退出 Incremented on every start, used to ignore exits of stopped instances
+	started  time.Time
+	running  bool
+	finished bool // 子任务返回了 nil，不再重新启动 The child returned nil and is not restarted again
+	cancel   context.CancelFunc
+	done     chan struct{}
+	token    int         // 每次安排重新启动时加一，用于忽略被取代的重新启动 Incremented whenever a restart is scheduled, used to ignore superseded restarts
+	timer    *time.Timer // 等待中的重新启动的定时器 Timer of the pending restart
+}
+
+// childExit 结构体表示一个子任务实例的退出
+// The childExit struct represents the exit of a child instance
+type childExit struct {
+	index int
+	gen   int
+	err   error
+}
+
+// childRestart 结构体表示一组子任务的延迟时间已经结束，可以按顺序重新启动
+// The childRestart struct represents that the delay of a set of children is over and they can be restarted in order
+type childRestart struct {
+	indexes []int
+	tokens  []int
+}
+
+// Run 方法启动所有子任务并监督它们，直到所有子任务返回 nil、上下文结束或者监督树需要把错误交给上一级
+// 每次重新启动之前调用被重新启动的子任务的配置中的回调函数，传入它的重新启动次数、延迟时间和失败的子任务的错误，返回之前按相反的顺序停止所有子任务
+// 所有子任务返回 nil 时返回 nil，上下文结束时返回上下文的错误，重新启动的次数超过限制时返回包装了 ErrorRestartIntensityExceeded 的错误
+// 子任务的错误被 RetryIfFunc 拒绝、显式设置的重试次数用完或者退避策略要求停止时返回对应的错误
+// The Run method starts every child and supervises them until every child returns nil, the context is done or the tree needs to escalate
+// Before every restart the callback in the configuration of each restarted child is called with its restart count, the delay and the error of the failed child, and every child is stopped in reverse order before returning
+// It returns nil when every child returns nil, the context error when the context is done, and an error wrapping ErrorRestartIntensityExceeded when there are too many restarts
+// The matching error is returned when the error of a child is rejected by RetryIfFunc, its explicitly set attempts run out or its backoff asks to stop
+// 这些错误同时包装失败的子任务的错误，errors.Is 和 errors.As 可以用于两者
+// These errors also wrap the error of the failed child, so errors.Is and errors.As work for both
+func (t *SupervisorTree) Run(ctx context.Context) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if len(t.children) == 0 {
+		return nil
+	}
+
+	runs := make([]*childRun, len(t.children))
+	for i, child := range t.children {
+		runs[i] = &childRun{child: child, bo: child.retry.newBackoff()}
+	}
+
+	// 已经被停止的实例在 stopped 关闭后不再发送退出事件
+	// Instances that were already stopped no longer send exit events once stopped is closed
+	exits := make(chan childExit)
+	restartCh := make(chan childRestart)
+	stopped := make(chan struct{})
+	defer close(stopped)
+	defer func() {
+		for _, run := range runs {
+			if run.timer != nil {
+				run.timer.Stop()
+			}
+		}
+	}()
+
+	for i := range runs {
+		t.start(ctx, runs, i, exits, stopped)
+	}
+
+	var restarts []time.Time
+	active := len(runs)
+
+	for {
+		select {
+		case <-ctx.Done():
+			t.shutdown(runs)
+			return ctx.Err()
+
+		case ev := <-exits:
+			// 忽略旧的实例和已经被监督树停止的实例的退出
+			// Ignore exits of old instances and of instances the tree already stopped
+			run := runs[ev.index]
+			if ev.gen != run.gen || !run.running {
+				continue
+			}
+			run.running = false
+			run.cancel()
+
+			if err := ctx.Err(); err != nil {
+				t.shutdown(runs)
+				return err
+			}
+
+			if ev.err == nil {
+				run.finished = true
+				if active--; active == 0 {
+					return nil
+				}
+				continue
+			}
+
+			if err := t.restart(runs, ev.index, ev.err, &restarts, restartCh, stopped); err != nil {
+				t.shutdown(runs)
+				return err
+			}
+
+		case ev := <-restartCh:
+			// 被之后的失败取代的重新启动已经包含在新的重新启动中
+			// A restart superseded by a later failure is part of the newer restart
+			for n, i := range ev.indexes {
+				run := runs[i]
+				if run.token == ev.tokens[n] && !run.running && !run.finished {
+					run.timer = nil
+					t.start(ctx, runs, i, exits, stopped)
+				}
+			}
+		}
+	}
+}
+
+// restart 方法处理一个子任务的失败，检查重新启动的强度和子任务的配置，然后按策略停止子任务，并在延迟时间之后通过 restartCh 通知 Run 重新启动它们，需要停止监督树时返回错误
+// 等待延迟时间不会阻塞 Run，其他子任务的失败在等待期间照常处理
+// The restart method handles the failure of a child, checking the restart intensity and the configuration of the child, then stops children following the strategy and tells Run through restartCh to restart them after the delay, returning an error when the tree needs to stop
+// Waiting for the delay does not block Run, so failures of other children are handled as usual meanwhile
+func (t *SupervisorTree) restart(runs []*childRun, index int, cause error, restarts *[]time.Time, restartCh chan<- childRestart, stopped <-chan struct{}) error {
+	run := runs[index]
+	child := run.child
+	conf := child.retry.config
+
+	if !conf.retryIfFunc(cause) {
+		return fmt.Errorf("retry child %q: %w", child.name, cause)
+	}
+
+	// 只保留时间窗口内的重新启动
+	// Only keep the restarts within the period
+	now := t.now()
+	kept := (*restarts)[:0]
+	for _, at := range *restarts {
+		if now.Sub(at) < t.period {
+			kept = append(kept, at)
+		}
+	}
+	*restarts = append(kept, now)
+	if len(*restarts) > t.maxRestarts {
+		return newCauseError(ErrorRestartIntensityExceeded, cause, "%v: child %q: %v", ErrorRestartIntensityExceeded, child.name, cause)
+	}
+
+	if now.Sub(run.started) >= t.stableAfter {
+		run.bo.Reset()
+		run.failures = 0
+	}
+	run.failures++
+
+	if limit := failureLimit(conf); limit > 0 && uint64(run.failures) >= limit {
+		return newCauseError(ErrorRetryAttemptsExceeded, cause, "%v: child %q after %d consecutive failures: %v", ErrorRetryAttemptsExceeded, child.name, run.failures, cause)
+	}
+
+	delay, ok := child.retry.nextDelay(run.bo, run.failures, cause)
+	if !ok {
+		return newCauseError(ErrorRetryBackoffStopped, cause, "%v: child %q: %v", ErrorRetryBackoffStopped, child.name, cause)
+	}
+
+	// 按相反的顺序停止需要一起重新启动的子任务
+	// Stop the children restarted together in reverse order
+	indexes := t.restartSet(index, len(runs))
+	for i := len(indexes) - 1; i >= 0; i-- {
+		t.stop(runs[indexes[i]])
+	}
+
+	for _, i := range indexes {
+		if runs[i].finished {
+			continue
+		}
+		c := runs[i].child
+		c.retry.config.callback.OnRetry(atomic.AddInt64(&c.restarts, 1), delay, cause)
+	}
+
+	ev := childRestart{indexes: indexes, tokens: make([]int, len(indexes))}
+	for n, i := range indexes {
+		run := runs[i]
+		if run.timer != nil {
+			run.timer.Stop()
+		}
+		run.token++
+		ev.tokens[n] = run.token
+	}
+
+	timer := time.AfterFunc(delay, func() {
+		select {
+		case restartCh <- ev:
+		case <-stopped:
+		}
+	})
+	for _, i := range indexes {
+		runs[i].timer = timer
+	}
+	return nil
+}
+
+// restartSet 方法按策略返回需要重新启动的子任务的下标，按启动的顺序排列
+// The restartSet method returns the indexes of the children to restart following the strategy, in start order
+func (t *SupervisorTree) restartSet(index, count int) []int {
+	from, to := index, index+1
+	switch t.strategy {
+	case OneForAll:
+		from, to = 0, count
+	case RestForOne:
+		to = count
+	}
+
+	indexes := make([]int, 0, to-from)
+	for i := from; i < to; i++ {
+		indexes = append(indexes, i)
+	}
+	return indexes
+}
+
+// start 方法在新的 goroutine 中启动一个子任务的新实例
+// The start method starts a new instance of a child in a new goroutine
+func (t *SupervisorTree) start(ctx context.Context, runs []*childRun, index int, exits chan<- childExit, stopped <-chan struct{}) {
+	run := runs[index]
+	// 子任务的上下文不随 ctx 一起取消，这样监督树可以按相反的顺序逐个停止它们
+	// The context of a child is not cancelled together with ctx, so the tree can stop the children one by one in reverse order
+	childCtx, cancel := context.WithCancel(detachedContext{parent: ctx})
+
+	run.gen++
+	run.cancel = cancel
+	run.done = make(chan struct{})
+	run.running = true
+	run.started = t.now()
+
+	gen, done, task := run.gen, run.done, run.child.task
+	go func() {
+		err := runTask(childCtx, task)
+		close(done)
+
+		select {
+		case exits <- childExit{index: index, gen: gen, err: err}:
+		case <-stopped:
+		}
+	}()
+}
+
+// stop 方法取消一个正在运行的子任务并等待它停止，最多等待子任务的停止超时时间
+// The stop method cancels a running child and waits for it to stop, for at most the shutdown timeout of the child
+func (t *SupervisorTree) stop(run *childRun) {
+	if !run.running {
+		return
+	}
+	run.running = false
+	run.cancel()
+
+	tr := time.NewTimer(run.child.shutdownTimeout)
+	defer tr.Stop()
+
+	select {
+	case <-run.done:
+	case <-tr.C:
+	}
+}
+
+// shutdown 方法按相反的顺序停止所有子任务
+// The shutdown method stops every child in reverse order
+func (t *SupervisorTree) shutdown(runs []*childRun) {
+	for i := len(runs) - 1; i >= 0; i-- {
+		t.stop(runs[i])
+	}
+}
+
+// detachedContext 结构体保留父上下文的值，但不继承它的取消和截止时间
+// The detachedContext struct keeps the values of the parent context without inheriting its cancellation and deadline
+type detachedContext struct {
+	parent context.Context
+}
+
+// Deadline 方法总是返回没有截止时间
+// The Deadline method always reports no deadline
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+
+// Done 方法返回 nil，表示永远不会被取消
+// The Done method returns nil, meaning it is never cancelled
+func (detachedContext) Done() <-chan struct{} { return nil }
+
+// Err 方法总是返回 nil
+// The Err method always returns nil
+func (detachedContext) Err() error { return nil }
+
+// Value 方法返回父上下文中的值
+// The Value method returns the value from the parent context
+func (c detachedContext) Value(key any) any { return c.parent.Value(key) }
@@ -0,0 +1,336 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// treeRecorder 记录每个子任务的启动次数和停止的顺序
+// treeRecorder records the start count of every child and the order they stopped in
+type treeRecorder struct {
+	mu      sync.Mutex
+	starts  map[string]int
+	stopped []string
+}
+
+func newTreeRecorder() *treeRecorder {
+	return &treeRecorder{starts: make(map[string]int)}
+}
+
+// task 方法返回一个运行直到上下文结束的子任务，failures 次之内的启动会立即失败
+// The task method returns a child task that runs until the context is done, starts within failures fail immediately
+func (r *treeRecorder) task(name string, failures int) TaskFunc {
+	return func(ctx context.Context) error {
+		r.mu.Lock()
+		r.starts[name]++
+		n := r.starts[name]
+		r.mu.Unlock()
+
+		if n <= failures {
+			return errors.New(name + " failed")
+		}
+
+		<-ctx.Done()
+
+		r.mu.Lock()
+		r.stopped = append(r.stopped, name)
+		r.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+func (r *treeRecorder) startsOf(name string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.starts[name]
+}
+
+func newTreeConfig() *Config {
+	return NewConfig().
+		WithInitDelay(time.Millisecond).
+		WithBackOffFunc(func(int64) time.Duration { return 0 })
+}
+
+// runTree 函数运行监督树直到 ready 返回 true，然后取消上下文并返回 Run 的错误
+// The runTree function runs the tree until ready returns true, then cancels the context and returns the error of Run
+func runTree(t *testing.T, tree *SupervisorTree, ready func() bool) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- tree.Run(ctx) }()
+
+	assert.Eventually(t, ready, time.Second, time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(time.Second):
+		t.Fatal("tree did not stop")
+		return nil
+	}
+}
+
+func TestSupervisorTree_OneForOne(t *testing.T) {
+	rec := newTreeRecorder()
+	a := NewChild("a", rec.task("a", 2), newTreeConfig())
+	b := NewChild("b", rec.task("b", 0), newTreeConfig())
+	tree := NewSupervisorTree(OneForOne).AddChild(a, b)
+
+	err := runTree(t, tree, func() bool { return rec.startsOf("a") == 3 })
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 1, rec.startsOf("b"))
+	assert.Equal(t, int64(2), a.Restarts())
+	assert.Equal(t, int64(0), b.Restarts())
+}
+
+func TestSupervisorTree_OneForAll(t *testing.T) {
+	rec := newTreeRecorder()
+	cb := &delayCallback{}
+	a := NewChild("a", rec.task("a", 0), newTreeConfig().WithCallback(cb))
+	b := NewChild("b", rec.task("b", 1), newTreeConfig())
+	tree := NewSupervisorTree(OneForAll).AddChild(a, b)
+
+	err := runTree(t, tree, func() bool { return rec.startsOf("a") == 2 && rec.startsOf("b") == 2 })
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, int64(1), a.Restarts())
+	assert.Equal(t, int64(1), b.Restarts())
+	assert.Len(t, cb.delays, 1)
+}
+
+func TestSupervisorTree_RestForOne(t *testing.T) {
+	rec := newTreeRecorder()
+	a := NewChild("a", rec.task("a", 0), newTreeConfig())
+	b := NewChild("b", rec.task("b", 1), newTreeConfig())
+	c := NewChild("c", rec.task("c", 0), newTreeConfig())
+	tree := NewSupervisorTree(RestForOne).AddChild(a, b, c)
+
+	err := runTree(t, tree, func() bool { return rec.startsOf("b") == 2 && rec.startsOf("c") == 2 })
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 1, rec.startsOf("a"))
+	assert.Equal(t, []int64{0, 1, 1}, []int64{a.Restarts(), b.Restarts(), c.Restarts()})
+}
+
+func TestSupervisorTree_ConcurrentDelays(t *testing.T) {
+	rec := newTreeRecorder()
+	slow := NewChild("slow", rec.task("slow", 1), newTreeConfig().WithInitDelay(300*time.Millisecond))
+	fast := NewChild("fast", rec.task("fast", 3), newTreeConfig())
+	tree := NewSupervisorTree(OneForOne).WithIntensity(10, time.Minute).AddChild(slow, fast)
+
+	// 等待 slow 的延迟时间时 fast 仍然按自己的延迟时间重新启动
+	// While slow waits for its delay, fast still restarts after its own delay
+	var fastFirst bool
+	err := runTree(t, tree, func() bool {
+		if rec.startsOf("fast") == 4 && rec.startsOf("slow") == 1 {
+			fastFirst = true
+		}
+		return rec.startsOf("slow") == 2
+	})
+	assert.Equal(t, context.Canceled, err)
+	assert.True(t, fastFirst)
+	assert.Equal(t, int64(1), slow.Restarts())
+	assert.Equal(t, int64(3), fast.Restarts())
+}
+
+func TestSupervisorTree_SupersededRestart(t *testing.T) {
+	rec := newTreeRecorder()
+	var runs int64
+	a := NewChild("a", func(ctx context.Context) error {
+		// a 在 b 和 c 等待重新启动时失败，取代它们的重新启动
+		// a fails while b and c wait to restart, superseding their restart
+		if atomic.AddInt64(&runs, 1) == 1 {
+			time.Sleep(50 * time.Millisecond)
+			return errors.New("a failed")
+		}
+		return rec.task("a", 0)(ctx)
+	}, newTreeConfig())
+	b := NewChild("b", rec.task("b", 1), newTreeConfig().WithInitDelay(200*time.Millisecond))
+	c := NewChild("c", rec.task("c", 0), newTreeConfig())
+	tree := NewSupervisorTree(RestForOne).WithIntensity(10, time.Minute).AddChild(a, b, c)
+
+	// 被取代的重新启动到期后不会再次启动 b 和 c
+	// The superseded restart does not start b and c again when it fires
+	var settled time.Time
+	err := runTree(t, tree, func() bool {
+		if rec.startsOf("b") != 2 || rec.startsOf("c") != 2 {
+			return false
+		}
+		if settled.IsZero() {
+			settled = time.Now()
+		}
+		return time.Since(settled) > 250*time.Millisecond
+	})
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 2, rec.startsOf("b"))
+	assert.Equal(t, 2, rec.startsOf("c"))
+	assert.Equal(t, int64(1), a.Restarts())
+	assert.Equal(t, int64(2), b.Restarts())
+}
+
+func TestSupervisorTree_Intensity(t *testing.T) {
+	rec := newTreeRecorder()
+	a := NewChild("a", rec.task("a", 100), newTreeConfig())
+	tree := NewSupervisorTree(OneForOne).WithIntensity(2, time.Minute).AddChild(a)
+
+	err := tree.Run(context.Background())
+	assert.ErrorIs(t, err, ErrorRestartIntensityExceeded)
+	assert.Contains(t, err.Error(), `"a"`)
+	assert.Equal(t, 3, rec.startsOf("a"))
+	assert.Equal(t, int64(2), a.Restarts())
+
+	// 时间窗口之外的重新启动不计算在内
+	// Restarts outside the period do not count
+	rec = newTreeRecorder()
+	now := time.Now()
+	tree = NewSupervisorTree(OneForOne).WithIntensity(1, time.Second).AddChild(NewChild("a", func(ctx context.Context) error {
+		rec.task("a", 100)(ctx)
+		now = now.Add(2 * time.Second)
+		if rec.startsOf("a") == 5 {
+			return nil
+		}
+		return errors.New("a failed")
+	}, newTreeConfig()))
+	tree.now = func() time.Time { return now }
+
+	assert.NoError(t, tree.Run(context.Background()))
+	assert.Equal(t, 5, rec.startsOf("a"))
+}
+
+func TestSupervisorTree_IntensityOnlyByDefault(t *testing.T) {
+	rec := newTreeRecorder()
+	a := NewChild("a", rec.task("a", 6), newTreeConfig())
+	tree := NewSupervisorTree(OneForOne).WithIntensity(10, time.Minute).AddChild(a)
+
+	// 没有显式设置重试次数时，强度允许的重新启动不受默认重试次数的限制
+	// Without explicitly set attempts, the restarts the intensity allows are not limited by the default attempts
+	err := runTree(t, tree, func() bool { return rec.startsOf("a") == 7 })
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, int64(6), a.Restarts())
+}
+
+func TestSupervisorTree_Escalation(t *testing.T) {
+	rec := newTreeRecorder()
+	sub := NewSupervisorTree(OneForOne).
+		WithIntensity(1, time.Minute).
+		AddChild(NewChild("worker", rec.task("worker", 100), newTreeConfig()))
+
+	subChild := NewChild("sub", sub.Run, newTreeConfig())
+	parent := NewSupervisorTree(OneForOne).WithIntensity(1, time.Minute).AddChild(subChild)
+
+	// 子树每次运行启动 worker 两次，父树重新启动子树一次之后放弃
+	// The subtree starts the worker twice per run, and the parent gives up after restarting the subtree once
+	err := parent.Run(context.Background())
+	assert.ErrorIs(t, err, ErrorRestartIntensityExceeded)
+	assert.Contains(t, err.Error(), `"sub"`)
+	assert.Equal(t, 4, rec.startsOf("worker"))
+	assert.Equal(t, int64(1), subChild.Restarts())
+}
+
+func TestSupervisorTree_WrappedCause(t *testing.T) {
+	errTask := errors.New("disconnected")
+	failing := func(ctx context.Context) error { return errTask }
+
+	// 监督树的错误同时包装哨兵错误和子任务的错误
+	// The errors of the tree wrap both the sentinel error and the error of the child
+	err := NewSupervisorTree(OneForOne).WithIntensity(1, time.Minute).
+		AddChild(NewChild("a", failing, newTreeConfig())).
+		Run(context.Background())
+	assert.ErrorIs(t, err, ErrorRestartIntensityExceeded)
+	assert.ErrorIs(t, err, errTask)
+
+	err = NewSupervisorTree(OneForOne).
+		AddChild(NewChild("a", failing, newTreeConfig().WithAttempts(2))).
+		Run(context.Background())
+	assert.ErrorIs(t, err, ErrorRetryAttemptsExceeded)
+	assert.ErrorIs(t, err, errTask)
+
+	err = NewSupervisorTree(OneForOne).
+		AddChild(NewChild("a", failing, newTreeConfig().WithBackoffFactory(LimitBackoff(FuncBackoff(func(int64) time.Duration { return 0 }), 1)))).
+		Run(context.Background())
+	assert.ErrorIs(t, err, ErrorRetryBackoffStopped)
+	assert.ErrorIs(t, err, errTask)
+
+	// 上一级监督树的错误仍然可以找到最初的错误
+	// The error of the parent tree still reaches the original error
+	sub := NewSupervisorTree(OneForOne).WithIntensity(0, time.Minute).AddChild(NewChild("worker", failing, newTreeConfig()))
+	err = NewSupervisorTree(OneForOne).WithIntensity(0, time.Minute).
+		AddChild(NewChild("sub", sub.Run, newTreeConfig())).
+		Run(context.Background())
+	assert.ErrorIs(t, err, ErrorRestartIntensityExceeded)
+	assert.ErrorIs(t, err, errTask)
+}
+
+func TestSupervisorTree_FatalError(t *testing.T) {
+	errFatal := errors.New("fatal")
+	rec := newTreeRecorder()
+	tree := NewSupervisorTree(OneForOne).AddChild(
+		NewChild("a", rec.task("a", 0), newTreeConfig()),
+		NewChild("b", func(ctx context.Context) error { return errFatal },
+			newTreeConfig().WithRetryIfFunc(func(err error) bool { return !errors.Is(err, errFatal) })),
+	)
+
+	err := tree.Run(context.Background())
+	assert.ErrorIs(t, err, errFatal)
+	assert.Equal(t, []string{"a"}, rec.stopped)
+}
+
+func TestSupervisorTree_AllDone(t *testing.T) {
+	var runs int64
+	task := func(ctx context.Context) error {
+		atomic.AddInt64(&runs, 1)
+		return nil
+	}
+	tree := NewSupervisorTree(OneForAll).AddChild(
+		NewChild("a", task, nil),
+		NewChild("b", task, nil),
+	)
+
+	assert.NoError(t, tree.Run(context.Background()))
+	assert.Equal(t, int64(2), atomic.LoadInt64(&runs))
+	assert.NoError(t, NewSupervisorTree(OneForOne).Run(context.Background()))
+}
+
+func TestSupervisorTree_OrderedShutdown(t *testing.T) {
+	rec := newTreeRecorder()
+	tree := NewSupervisorTree(OneForOne).AddChild(
+		NewChild("a", rec.task("a", 0), nil),
+		NewChild("b", rec.task("b", 0), nil),
+		NewChild("c", rec.task("c", 0), nil),
+	)
+
+	err := runTree(t, tree, func() bool { return rec.startsOf("c") == 1 })
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, []string{"c", "b", "a"}, rec.stopped)
+}
+
+func TestSupervisorTree_ShutdownTimeout(t *testing.T) {
+	rec := newTreeRecorder()
+	release := make(chan struct{})
+	defer close(release)
+
+	tree := NewSupervisorTree(OneForOne).AddChild(
+		NewChild("a", rec.task("a", 0), nil),
+		NewChild("stuck", func(ctx context.Context) error {
+			<-release
+			return nil
+		}, nil).WithShutdownTimeout(20*time.Millisecond),
+	)
+
+	start := time.Now()
+	err := runTree(t, tree, func() bool { return rec.startsOf("a") == 1 })
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, []string{"a"}, rec.stopped)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+}
+
+func TestRestartStrategy_String(t *testing.T) {
+	assert.Equal(t, "one-for-one", OneForOne.String())
+	assert.Equal(t, "one-for-all", OneForAll.String())
+	assert.Equal(t, "rest-for-one", RestForOne.String())
+	assert.Equal(t, "unknown", RestartStrategy(99).String())
+}
@@ -0,0 +1,352 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultIdempotencyHeader 是默认的幂等键请求头，带有该请求头的请求即使方法不是幂等的也会被重试
+// DefaultIdempotencyHeader is the default idempotency key header, requests carrying it are retried even when their method is not idempotent
+const DefaultIdempotencyHeader = "Idempotency-Key"
+
+// 丢弃响应时最多读取的字节数，超过该长度的响应体直接关闭，不再复用连接
+// Maximum number of bytes read when discarding a response, longer bodies are closed directly without reusing the connection
+const maxDrainBytes = 64 << 10
+
+// 默认可重试的响应状态码
+// Response status codes retried by default
+var defaultRetryableStatus = []int{
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// 幂等的请求方法
+// Idempotent request methods
+var idempotentMethods = map[string]struct{}{
+	http.MethodGet:     {},
+	http.MethodHead:    {},
+	http.MethodOptions: {},
+	http.MethodTrace:   {},
+	http.MethodPut:     {},
+	http.MethodDelete:  {},
+}
+
+// StatusError 结构体表示一个可重试的响应状态码，它实现了 ErrorCoder 和 RetryAfterError 接口
+// Code 方法返回状态码的字符串形式，可以配合 WithAttemptsByErrorCode 限制某个状态码的重试次数
+// The StatusError struct represents a retryable response status code, it implements the ErrorCoder and RetryAfterError interfaces
+// The Code method returns the status code as a string, which works with WithAttemptsByErrorCode to limit the retries of a status code
+type StatusError struct {
+	StatusCode int    // 响应状态码 Response status code
+	Status     string // 响应状态，例如 "503 Service Unavailable" Response status, such as "503 Service Unavailable"
+
+	retryAfter time.Duration
+}
+
+// Error 方法返回错误的描述
+// The Error method returns the description of the error
+func (e *StatusError) Error() string {
+	return "retryable http status: " + e.Status
+}
+
+// Code 方法返回状态码的字符串形式
+// The Code method returns the status code as a string
+func (e *StatusError) Code() string {
+	return strconv.Itoa(e.StatusCode)
+}
+
+// RetryAfter 方法返回响应中 Retry-After 头要求的等待时间，没有该头时返回 0
+// The RetryAfter method returns the wait requested by the Retry-After header of the response, or 0 without the header
+func (e *StatusError) RetryAfter() time.Duration {
+	return e.retryAfter
+}
+
+// Transport 结构体是一个带重试的 http.RoundTripper，可以被多个 goroutine 并发使用
+// 只有幂等的方法（GET、HEAD、OPTIONS、TRACE、PUT、DELETE）或带有幂等键请求头的请求会被重试，带有请求体的请求还需要设置 GetBody 以便重放请求体
+// 连接错误和 429、502、503、504 状态码默认是可重试的，Retry-After 头会作为下一次重试前的最短等待时间
+// 第一次请求总是立即发送，之后的重试使用配置中的退避策略，请求的上下文结束时停止重试
+// 重试次数用完时返回最后一次的响应，被丢弃的响应会被读完并关闭，以便复用连接
+// The Transport struct is an http.RoundTripper with retries and is safe for concurrent use by multiple goroutines
+// Only idempotent methods (GET, HEAD, OPTIONS, TRACE, PUT, DELETE) or requests carrying the idempotency key header are retried, requests with a body also need GetBody to replay the body
+// Connection errors and the 429, 502, 503 and 504 status codes are retryable by default, the Retry-After header is the minimum wait before the next retry
+// The first request is always sent immediately, later retries use the configured backoff, and retrying stops when the request context is done
+// When the attempts run out the last response is returned, discarded responses are drained and closed so that connections are reused
+type Transport struct {
+	base              http.RoundTripper
+	config            *Config
+	retry             *Retry
+	idempotencyHeader string
+	retryableStatus   map[int]struct{}
+}
+
+// NewTransport 函数创建一个新的 Transport 实例，base 为空时使用 http.DefaultTransport，conf 为空时使用默认配置
+// 配置会被复制，之后修改 conf 不会影响 Transport，With 开头的方法需要在开始使用之前调用
+// The NewTransport function creates a new Transport instance, using http.DefaultTransport when base is nil and the default configuration when conf is nil
+// The configuration is copied so later changes to conf do not affect the Transport, the With methods must be called before it is used
+func NewTransport(base http.RoundTripper, conf *Config) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if conf == nil {
+		conf = NewConfig()
+	}
+
+	t := &Transport{
+		base:              base,
+		config:            conf.Clone(),
+		idempotencyHeader: DefaultIdempotencyHeader,
+	}
+	t.WithRetryableStatus(defaultRetryableStatus...)
+
+	return t
+}
+
+// WithIdempotencyHeader 方法设置幂等键请求头的名称并返回 Transport 实例，为空时只重试幂等的方法
+// The WithIdempotencyHeader method sets the name of the idempotency key header and returns the Transport instance, when empty only idempotent methods are retried
+func (t *Transport) WithIdempotencyHeader(name string) *Transport {
+	t.idempotencyHeader = name
+	return t
+}
+
+// WithRetryableStatus 方法设置可重试的响应状态码并返回 Transport 实例，它会替换默认的状态码
+// The WithRetryableStatus method sets the retryable response status codes and returns the Transport instance, replacing the default status codes
+func (t *Transport) WithRetryableStatus(codes ...int) *Transport {
+	t.retryableStatus = make(map[int]struct{}, len(codes))
+	for _, code := range codes {
+		t.retryableStatus[code] = struct{}{}
+	}
+	t.build()
+	return t
+}
+
+// build 方法根据配置创建 Retry 实例，第一次请求立即发送，是否重试由 Transport 的分类和配置中的 RetryIfFunc 共同决定
+// The build method creates the Retry instance from the configuration, the first request is sent immediately and whether to retry is decided by both the Transport classification and the configured RetryIfFunc
+func (t *Transport) build() {
+	t.retry = newImmediateRetry(t.config, isRetryableTransportError)
+}
+
+// canRetry 方法判断请求是否可以被重试
+// The canRetry method reports whether the request can be retried
+func (t *Transport) canRetry(req *http.Request) bool {
+	if _, ok := idempotentMethods[req.Method]; !ok {
+		if t.idempotencyHeader == "" || req.Header.Get(t.idempotencyHeader) == "" {
+			return false
+		}
+	}
+
+	// 请求体无法重放时只能发送一次
+	// The request can only be sent once when its body cannot be replayed
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+
+	return true
+}
+
+// RoundTrip 方法发送请求，并在遇到可重试的错误或状态码时重试
+// The RoundTrip method sends the request and retries on retryable errors or status codes
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.roundTrip(req, nil)
+}
+
+// roundTrip 方法发送请求并重试，guard 不为空时每次发送前都要经过主机的熔断器和重试预算
+// 熔断器或重试预算拒绝重试时返回最后一次的响应或错误，它们的错误只在一次请求都没有发送时返回
+// The roundTrip method sends the request with retries, when guard is not nil every send goes through the circuit breaker and retry budget of the host
+// When the circuit breaker or retry budget refuses a retry the last response or error is returned, their errors are only returned when nothing was sent
+func (t *Transport) roundTrip(req *http.Request, guard *hostGuard) (*http.Response, error) {
+	if !t.canRetry(req) {
+		if err := guard.acquire(false); err != nil {
+			return nil, err
+		}
+		resp, err := t.base.RoundTrip(req)
+		guard.record(t.outcome(resp, err))
+		return resp, err
+	}
+
+	var (
+		attempts int
+		lastResp *http.Response
+		lastErr  error
+	)
+
+	result := t.retry.TryOnConflictContext(req.Context(), func() (any, error) {
+		// 熔断器或重试预算拒绝时保留上一次的响应，以便返回给调用者
+		// Keep the previous response when the circuit breaker or retry budget refuses, so it can be handed back to the caller
+		if err := guard.acquire(attempts > 0); err != nil {
+			if lastErr == nil {
+				lastErr = err
+			}
+			return nil, err
+		}
+
+		// 开始新的请求之前丢弃上一次的响应
+		// Discard the previous response before starting a new request
+		if lastResp != nil {
+			discardResponse(lastResp)
+			lastResp = nil
+		}
+
+		r, err := rewindRequest(req, attempts)
+		attempts++
+		if err != nil {
+			guard.record(outcomeIgnored)
+			lastErr = err
+			return nil, err
+		}
+
+		resp, err := t.base.RoundTrip(r)
+		guard.record(t.outcome(resp, err))
+		if err != nil {
+			lastErr = err
+			return nil, err
+		}
+
+		if _, ok := t.retryableStatus[resp.StatusCode]; ok {
+			lastResp, lastErr = resp, newStatusError(resp, time.Now())
+			return nil, lastErr
+		}
+
+		return resp, nil
+	})
+
+	if result.IsSuccess() {
+		return result.Data().(*http.Response), nil
+	}
+
+	// 请求的上下文结束时不再返回响应
+	// No response is returned once the request context is done
+	if err := req.Context().Err(); err != nil {
+		if lastResp != nil {
+			discardResponse(lastResp)
+		}
+		return nil, err
+	}
+
+	// 最后一次失败是可重试的状态码时，将这个响应返回给调用者
+	// When the last failure was a retryable status code, the response is handed back to the caller
+	if lastResp != nil {
+		return lastResp, nil
+	}
+
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	return nil, result.TryError()
+}
+
+// outcome 方法根据响应和错误判断请求对主机状态的影响
+// The outcome method decides the effect of a request on the host state from its response and error
+func (t *Transport) outcome(resp *http.Response, err error) outcome {
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return outcomeIgnored
+		}
+		return outcomeFailure
+	}
+	if _, ok := t.retryableStatus[resp.StatusCode]; ok {
+		return outcomeFailure
+	}
+	return outcomeSuccess
+}
+
+// rewindRequest 函数返回第 attempt 次发送的请求，重试时复制请求并通过 GetBody 重放请求体
+// The rewindRequest function returns the request for the given attempt, cloning the request and replaying the body through GetBody when retrying
+func rewindRequest(req *http.Request, attempt int) (*http.Request, error) {
+	if attempt == 0 || req.Body == nil || req.Body == http.NoBody {
+		return req, nil
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, fmt.Errorf("retry: rewind request body: %w", err)
+	}
+
+	r := req.Clone(req.Context())
+	r.Body = body
+	return r, nil
+}
+
+// newStatusError 函数根据响应创建 StatusError，并解析其中的 Retry-After 头
+// The newStatusError function creates a StatusError from the response and parses its Retry-After header
+func newStatusError(resp *http.Response, now time.Time) *StatusError {
+	err := &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	if err.Status == "" {
+		err.Status = strconv.Itoa(resp.StatusCode) + " " + http.StatusText(resp.StatusCode)
+	}
+	if d, ok := parseRetryAfter(resp.Header.Get("Retry-After"), now); ok {
+		err.retryAfter = d
+	}
+	return err
+}
+
+// parseRetryAfter 函数解析 Retry-After 头，它可以是秒数或者 HTTP 日期
+// The parseRetryAfter function parses the Retry-After header, which is either a number of seconds or an HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		if seconds > int64(math.MaxInt64/time.Second) {
+			return time.Duration(math.MaxInt64), true
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if at, err := http.ParseTime(value); err == nil {
+		if d := at.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+
+	return 0, false
+}
+
+// isRetryableTransportError 函数判断错误是否可以重试：可重试的状态码和连接错误可以重试，上下文的错误不可以重试
+// The isRetryableTransportError function reports whether the error can be retried: retryable status codes and connection errors can, context errors cannot
+func isRetryableTransportError(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return true
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || isConnErrno(err) {
+		return true
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// discardResponse 函数读完并关闭响应体，以便复用底层的连接
+// The discardResponse function drains and closes the response body so that the underlying connection can be reused
+func discardResponse(resp *http.Response) {
+	if resp.Body == nil {
+		return
+	}
+	_, _ = io.CopyN(io.Discard, resp.Body, maxDrainBytes)
+	_ = resp.Body.Close()
+}
@@ -0,0 +1,353 @@
+package retry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestTransportConfig() *Config {
+	return NewConfig().
+		WithInitDelay(time.Millisecond).
+		WithBackOffFunc(func(int64) time.Duration { return 0 })
+}
+
+func TestTransport_RetryableStatus(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: NewTransport(nil, newTestTransportConfig())}
+	resp, err := client.Get(srv.URL)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "ok", string(body))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
+
+func TestTransport_AttemptsExceeded(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadGateway)
+		_, _ = w.Write([]byte("bad gateway"))
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: NewTransport(nil, newTestTransportConfig().WithAttempts(4))}
+	resp, err := client.Get(srv.URL)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	// 重试次数用完时返回最后一次的响应
+	// The last response is returned when the attempts run out
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	assert.Equal(t, "bad gateway", string(body))
+	assert.Equal(t, int32(4), atomic.LoadInt32(&calls))
+}
+
+func TestTransport_NotRetryableStatus(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: NewTransport(nil, newTestTransportConfig())}
+	resp, err := client.Get(srv.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	// 可以替换可重试的状态码
+	// The retryable status codes can be replaced
+	atomic.StoreInt32(&calls, 0)
+	client = &http.Client{Transport: NewTransport(nil, newTestTransportConfig().WithAttempts(2)).WithRetryableStatus(http.StatusInternalServerError)}
+	resp, err = client.Get(srv.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestTransport_RetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		header func() string
+		wait   time.Duration
+	}{
+		{
+			name:   "seconds",
+			header: func() string { return "1" },
+			wait:   time.Second,
+		},
+		{
+			name: "http date",
+			header: func() string {
+				// HTTP 日期只精确到秒，因此使用两秒后的时间，实际的等待时间在一到两秒之间
+				// HTTP dates only have second precision, so use a time two seconds later, the actual wait is between one and two seconds
+				return time.Now().Add(2 * time.Second).UTC().Format(http.TimeFormat)
+			},
+			wait: time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.AddInt32(&calls, 1) == 1 {
+					w.Header().Set("Retry-After", tt.header())
+					w.WriteHeader(http.StatusTooManyRequests)
+					return
+				}
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			defer srv.Close()
+
+			client := &http.Client{Transport: NewTransport(nil, newTestTransportConfig())}
+			start := time.Now()
+			resp, err := client.Get(srv.URL)
+			assert.NoError(t, err)
+			resp.Body.Close()
+
+			assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+			assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+			assert.GreaterOrEqual(t, time.Since(start), tt.wait)
+		})
+	}
+}
+
+func TestTransport_ParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value string
+		wait  time.Duration
+		ok    bool
+	}{
+		{value: "", ok: false},
+		{value: "3", wait: 3 * time.Second, ok: true},
+		{value: " 0 ", wait: 0, ok: true},
+		{value: "-1", ok: false},
+		{value: "abc", ok: false},
+		{value: "99999999999999999999", ok: false},
+		{value: "Mon, 01 Jan 2024 00:00:30 GMT", wait: 30 * time.Second, ok: true},
+		{value: "Sun, 31 Dec 2023 23:59:00 GMT", wait: 0, ok: true},
+	}
+
+	for _, tt := range tests {
+		wait, ok := parseRetryAfter(tt.value, now)
+		assert.Equal(t, tt.ok, ok, tt.value)
+		assert.Equal(t, tt.wait, wait, tt.value)
+	}
+}
+
+func TestTransport_NonIdempotent(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: NewTransport(nil, newTestTransportConfig())}
+	resp, err := client.Post(srv.URL, "text/plain", strings.NewReader("payload"))
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	// POST 请求没有幂等键时不会重试
+	// POST requests without an idempotency key are not retried
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestTransport_IdempotencyKeyRewindsBody(t *testing.T) {
+	var calls int32
+	bodies := make(chan string, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies <- string(body)
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: NewTransport(nil, newTestTransportConfig())}
+
+	// bytes.Reader 的请求会自动设置 GetBody
+	// Requests with a bytes.Reader get GetBody set automatically
+	req, _ := http.NewRequest(http.MethodPost, srv.URL, bytes.NewReader([]byte("payload")))
+	req.Header.Set(DefaultIdempotencyHeader, "key-1")
+	resp, err := client.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	close(bodies)
+	for body := range bodies {
+		assert.Equal(t, "payload", body)
+	}
+}
+
+func TestTransport_BodyWithoutGetBody(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: NewTransport(nil, newTestTransportConfig())}
+	req, _ := http.NewRequest(http.MethodPut, srv.URL, io.NopCloser(strings.NewReader("payload")))
+	req.GetBody = nil
+	resp, err := client.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	// 请求体无法重放时只发送一次
+	// The request is sent only once when its body cannot be replayed
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestTransport_ConnectionReuse(t *testing.T) {
+	var calls int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 4 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write(bytes.Repeat([]byte("x"), 1024))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	var conns int32
+	srv.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	client := &http.Client{Transport: NewTransport(srv.Client().Transport, newTestTransportConfig().WithAttempts(5))}
+	resp, err := client.Get(srv.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	// 被丢弃的响应被读完并关闭，所有的请求复用同一个连接
+	// Discarded responses are drained and closed, so every request reuses the same connection
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(4), atomic.LoadInt32(&calls))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&conns))
+}
+
+func TestTransport_IgnoresRetryIfResult(t *testing.T) {
+	var calls, conns int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		_, _ = w.Write([]byte("ok"))
+	}))
+	srv.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	// 结果检查函数不会让 Transport 重试并泄漏被拒绝的响应
+	// The result check function does not make the Transport retry and leak rejected responses
+	conf := newTestTransportConfig().WithRetryIfResult(func(data any, err error) bool { return true })
+	client := &http.Client{Transport: NewTransport(srv.Client().Transport, conf)}
+	resp, err := client.Get(srv.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&conns))
+}
+
+func TestTransport_ConnectionError(t *testing.T) {
+	// 先监听再关闭，得到一个拒绝连接的地址
+	// Listen and then close to get an address that refuses connections
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+
+	cb := &delayCallback{}
+	client := &http.Client{Transport: NewTransport(nil, newTestTransportConfig().WithAttempts(3).WithCallback(cb))}
+	_, err = client.Get("http://" + addr)
+	assert.Error(t, err)
+
+	var opErr *net.OpError
+	assert.True(t, errors.As(err, &opErr))
+	assert.Len(t, cb.delays, 3)
+}
+
+func TestTransport_ContextCanceled(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	client := &http.Client{Transport: NewTransport(nil, NewConfig().WithAttempts(100).WithInitDelay(20*time.Millisecond).WithBackOffFunc(FixedBackoff).WithJitter(0))}
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	resp, err := client.Do(req)
+
+	assert.Nil(t, resp)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Less(t, atomic.LoadInt32(&calls), int32(100))
+}
+
+func TestTransport_StatusErrorCode(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	// StatusError 实现了 ErrorCoder，可以按状态码限制重试次数
+	// StatusError implements ErrorCoder, so retries can be limited per status code
+	cfg := newTestTransportConfig().WithAttempts(10).WithAttemptsByErrorCode(map[string]uint64{"429": 1})
+	client := &http.Client{Transport: NewTransport(nil, cfg)}
+	resp, err := client.Get(srv.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
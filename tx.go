@@ -0,0 +1,126 @@
+package retry
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// 默认被视为冲突的 SQLSTATE：序列化失败和死锁
+// SQLSTATE codes treated as conflicts by default: serialization failure and deadlock
+var defaultConflictStates = []string{"40001", "40P01"}
+
+// ConflictFunc 类型定义了判断错误是否是事务冲突的函数类型，返回 true 时重新执行整个事务
+// The ConflictFunc type defines the function type that reports whether an error is a transaction conflict, returning true reruns the whole transaction
+type ConflictFunc = func(err error) bool
+
+// TxFunc 类型定义了在事务中执行的函数类型
+// The TxFunc type defines the function type run inside a transaction
+type TxFunc = func(tx *sql.Tx) error
+
+// TxBeginner 接口由可以开始事务的类型实现，例如 *sql.DB 和 *sql.Conn
+// The TxBeginner interface is implemented by types that can begin transactions, such as *sql.DB and *sql.Conn
+type TxBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// sqlStater 接口由带有 SQLSTATE 的驱动错误实现，例如 pgx 和 lib/pq 的错误
+// The sqlStater interface is implemented by driver errors carrying a SQLSTATE, such as the errors of pgx and lib/pq
+type sqlStater interface {
+	SQLState() string
+}
+
+// IsSerializationConflict 函数判断错误是否是序列化失败（40001）或死锁（40P01），SQLSTATE 从 SQLState() 方法或 ErrorCoder 中获取
+// The IsSerializationConflict function reports whether the error is a serialization failure (40001) or a deadlock (40P01), the SQLSTATE is read from a SQLState() method or ErrorCoder
+func IsSerializationConflict(err error) bool {
+	var stater sqlStater
+	if errors.As(err, &stater) {
+		return containsString(defaultConflictStates, stater.SQLState())
+	}
+	return containsString(defaultConflictStates, defaultErrorCodeFunc(err))
+}
+
+// TxRunner 结构体在事务中执行函数，遇到冲突时回滚并重新执行整个事务，可以被多个 goroutine 并发使用
+// The TxRunner struct runs functions inside transactions, rolling back and rerunning the whole transaction on conflicts, and is safe for concurrent use by multiple goroutines
+type TxRunner struct {
+	retry    *Retry
+	conflict ConflictFunc
+}
+
+// NewTxRunner 函数创建一个新的 TxRunner 实例，conf 为空时使用默认配置，第一次执行总是立即进行，配置会被复制
+// 默认使用 IsSerializationConflict 判断冲突
+// The NewTxRunner function creates a new TxRunner instance, using the default configuration when conf is nil, the first run always happens immediately and the configuration is copied
+// IsSerializationConflict is used to detect conflicts by default
+func NewTxRunner(conf *Config) *TxRunner {
+	r := &TxRunner{conflict: IsSerializationConflict}
+	r.retry = newImmediateRetry(conf, func(err error) bool { return r.conflict(err) })
+	return r
+}
+
+// WithConflictFunc 方法设置判断冲突的函数并返回 TxRunner 实例
+// The WithConflictFunc method sets the function that detects conflicts and returns the TxRunner instance
+func (r *TxRunner) WithConflictFunc(fn ConflictFunc) *TxRunner {
+	if fn != nil {
+		r.conflict = fn
+	}
+	return r
+}
+
+// Run 方法开始一个事务并执行 fn，fn 返回错误时回滚，否则提交，fn、提交或开始事务的错误是冲突时重新执行整个事务
+// 重试次数用完或错误不是冲突时返回最后一次的错误，上下文结束时返回上下文的错误
+// The Run method begins a transaction and runs fn, rolling back when fn returns an error and committing otherwise, the whole transaction is rerun when the error of fn, the commit or the begin is a conflict
+// It returns the last error when the attempts run out or the error is not a conflict, and the context error when the context is done
+func (r *TxRunner) Run(ctx context.Context, db TxBeginner, opts *sql.TxOptions, fn TxFunc) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	var lastErr error
+	result := r.retry.TryOnConflictContext(ctx, func() (any, error) {
+		if err := runTx(ctx, db, opts, fn); err != nil {
+			lastErr = err
+			return nil, err
+		}
+		return nil, nil
+	})
+
+	if result.IsSuccess() {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if lastErr != nil {
+		return lastErr
+	}
+	return result.TryError()
+}
+
+// runTx 函数执行一次事务，fn 返回错误或 panic 时回滚
+// The runTx function runs the transaction once, rolling back when fn returns an error or panics
+func runTx(ctx context.Context, db TxBeginner, opts *sql.TxOptions, fn TxFunc) error {
+	tx, err := db.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RunInTx 函数使用默认配置和 IsSerializationConflict 在事务中执行 fn，遇到序列化失败或死锁时重新执行整个事务
+// The RunInTx function runs fn inside a transaction with the default configuration and IsSerializationConflict, rerunning the whole transaction on serialization failures or deadlocks
+func RunInTx(ctx context.Context, db TxBeginner, opts *sql.TxOptions, fn TxFunc) error {
+	return NewTxRunner(nil).Run(ctx, db, opts, fn)
+}
@@ -0,0 +1,165 @@
+package retry
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type sqlStateError struct {
+	state string
+}
+
+func (e *sqlStateError) Error() string { return "sqlstate " + e.state }
+
+func (e *sqlStateError) SQLState() string { return e.state }
+
+func newTestTxRunner(attempts uint64) *TxRunner {
+	return NewTxRunner(NewConfig().
+		WithInitDelay(time.Millisecond).
+		WithBackOffFunc(func(int64) time.Duration { return 0 }).
+		WithAttempts(attempts))
+}
+
+func openTestTxDB(fake *fakeDB) *sql.DB {
+	return sql.OpenDB(&dsnConnector{name: "fake", driver: fake})
+}
+
+func TestIsSerializationConflict(t *testing.T) {
+	assert.True(t, IsSerializationConflict(&sqlStateError{state: "40001"}))
+	assert.True(t, IsSerializationConflict(fmt.Errorf("wrapped: %w", &sqlStateError{state: "40P01"})))
+	assert.True(t, IsSerializationConflict(&codeError{code: "40001"}))
+	assert.False(t, IsSerializationConflict(&sqlStateError{state: "23505"}))
+	assert.False(t, IsSerializationConflict(errors.New("40001")))
+	assert.False(t, IsSerializationConflict(nil))
+}
+
+func TestTxRunner_RerunsOnConflict(t *testing.T) {
+	fake := &fakeDB{}
+	db := openTestTxDB(fake)
+	defer db.Close()
+
+	runs := 0
+	err := newTestTxRunner(5).Run(context.Background(), db, nil, func(tx *sql.Tx) error {
+		runs++
+		if runs < 3 {
+			return &sqlStateError{state: "40001"}
+		}
+		_, err := tx.Exec("UPDATE accounts SET balance = balance - 1")
+		return err
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, runs)
+	assert.Equal(t, 2, fake.rolledBack)
+	assert.Equal(t, 1, fake.committed)
+}
+
+func TestTxRunner_CommitConflict(t *testing.T) {
+	fake := &fakeDB{commitErrs: []error{&sqlStateError{state: "40P01"}}}
+	db := openTestTxDB(fake)
+	defer db.Close()
+
+	runs := 0
+	err := newTestTxRunner(5).Run(context.Background(), db, nil, func(tx *sql.Tx) error {
+		runs++
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, runs)
+	assert.Equal(t, 1, fake.committed)
+}
+
+func TestTxRunner_NotConflict(t *testing.T) {
+	fake := &fakeDB{}
+	db := openTestTxDB(fake)
+	defer db.Close()
+
+	e := errors.New("insufficient funds")
+	runs := 0
+	err := newTestTxRunner(5).Run(context.Background(), db, nil, func(tx *sql.Tx) error {
+		runs++
+		return e
+	})
+
+	assert.Equal(t, e, err)
+	assert.Equal(t, 1, runs)
+	assert.Equal(t, 1, fake.rolledBack)
+	assert.Equal(t, 0, fake.committed)
+}
+
+func TestTxRunner_AttemptsExceeded(t *testing.T) {
+	fake := &fakeDB{}
+	db := openTestTxDB(fake)
+	defer db.Close()
+
+	runs := 0
+	err := newTestTxRunner(3).Run(context.Background(), db, nil, func(tx *sql.Tx) error {
+		runs++
+		return &sqlStateError{state: "40001"}
+	})
+
+	assert.Equal(t, &sqlStateError{state: "40001"}, err)
+	assert.Equal(t, 3, runs)
+	assert.Equal(t, 3, fake.rolledBack)
+}
+
+func TestTxRunner_ConflictFunc(t *testing.T) {
+	fake := &fakeDB{}
+	db := openTestTxDB(fake)
+	defer db.Close()
+
+	deadlock := &codeError{code: "1213"}
+	runner := newTestTxRunner(5).WithConflictFunc(func(err error) bool {
+		var e *codeError
+		return errors.As(err, &e) && e.code == "1213"
+	})
+
+	runs := 0
+	err := runner.Run(context.Background(), db, nil, func(tx *sql.Tx) error {
+		runs++
+		if runs == 1 {
+			return deadlock
+		}
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, runs)
+}
+
+func TestTxRunner_Panic(t *testing.T) {
+	fake := &fakeDB{}
+	db := openTestTxDB(fake)
+	defer db.Close()
+
+	assert.PanicsWithValue(t, "boom", func() {
+		_ = RunInTx(context.Background(), db, nil, func(tx *sql.Tx) error {
+			panic("boom")
+		})
+	})
+	assert.Equal(t, 1, fake.rolledBack)
+}
+
+func TestTxRunner_ContextCanceled(t *testing.T) {
+	fake := &fakeDB{}
+	db := openTestTxDB(fake)
+	defer db.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runs := 0
+	err := newTestTxRunner(5).Run(ctx, db, nil, func(tx *sql.Tx) error {
+		runs++
+		cancel()
+		return &sqlStateError{state: "40001"}
+	})
+
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 1, runs)
+}
@@ -0,0 +1,83 @@
+package retry
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// checkAttempts 函数检查重试次数是否在有效范围内，有效时返回空字符串
+// The checkAttempts function checks whether the number of attempts is within the valid range, returning an empty string if it is valid
+func checkAttempts(attempts uint64) string {
+	if attempts == 0 || attempts >= math.MaxUint16 {
+		return fmt.Sprintf("must be between 1 and %d, got %d", math.MaxUint16-1, attempts)
+	}
+	return ""
+}
+
+// checkNonNegative 函数检查浮点数是否为非负的有限数，有效时返回空字符串
+// The checkNonNegative function checks whether a float is a non-negative finite number, returning an empty string if it is valid
+func checkNonNegative(value float64) string {
+	if value < 0 || math.IsNaN(value) || math.IsInf(value, 0) {
+		return fmt.Sprintf("must be a non-negative number, got %v", value)
+	}
+	return ""
+}
+
+// checkDuration 函数检查时间是否不小于 minimum，有效时返回空字符串
+// The checkDuration function checks whether a duration is at least minimum, returning an empty string if it is valid
+func checkDuration(d, minimum time.Duration) string {
+	if d < minimum {
+		return fmt.Sprintf("must be at least %v, got %v", minimum, d)
+	}
+	return ""
+}
+
+// checkDelayRange 函数检查最小延迟时间是否不大于最大延迟时间（最大延迟时间为 0 表示不限制），有效时返回空字符串
+// The checkDelayRange function checks that the minimum delay does not exceed the maximum delay (a zero maximum means no limit), returning an empty string if it is valid
+func checkDelayRange(minDelay, maxDelay time.Duration) string {
+	if maxDelay > 0 && minDelay > maxDelay {
+		return fmt.Sprintf("%v is greater than max_delay %v", minDelay, maxDelay)
+	}
+	return ""
+}
+
+// Validate 方法检查 Config 中的所有值，返回列出所有问题的 ConfigError，全部有效时返回 nil
+// 与 New 不同，Validate 不会用默认值替换无效的值
+// The Validate method checks every value of the Config and returns a ConfigError listing all problems, or nil if everything is valid
+// Unlike New, Validate never replaces invalid values with defaults
+func (c *Config) Validate() error {
+	var problems []string
+	check := func(field, problem string) {
+		if problem != "" {
+			problems = append(problems, field+": "+problem)
+		}
+	}
+
+	if c.ctx == nil {
+		check("context", "must not be nil")
+	}
+	if c.callback == nil {
+		check("callback", "must not be nil")
+	}
+	check("attempts", checkAttempts(c.attempts))
+	check("delay", checkDuration(c.delay, 1))
+	check("min_delay", checkDuration(c.minDelay, 0))
+	check("max_delay", checkDuration(c.maxDelay, 0))
+	check("min_delay", checkDelayRange(c.minDelay, c.maxDelay))
+	check("base_unit", checkDuration(c.baseUnit, 1))
+	check("timeout", checkDuration(c.timeout, 0))
+	check("factor", checkNonNegative(c.factor))
+	check("jitter", checkNonNegative(c.jitter))
+	if c.retryIfFunc == nil {
+		check("retry_if", "must not be nil")
+	}
+	if c.errorCodeFunc == nil {
+		check("error_code", "must not be nil")
+	}
+
+	if len(problems) > 0 {
+		return &ConfigError{Problems: problems}
+	}
+	return nil
+}
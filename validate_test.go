@@ -0,0 +1,91 @@
+package retry
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	assert.NoError(t, NewConfig().Validate())
+	assert.NoError(t, FixConfig().Validate())
+
+	tests := []struct {
+		name     string
+		cfg      *Config
+		problems []string
+	}{
+		{
+			name:     "too many attempts",
+			cfg:      NewConfig().WithAttempts(100000),
+			problems: []string{"attempts: must be between 1 and 65534, got 100000"},
+		},
+		{
+			name:     "zero attempts",
+			cfg:      NewConfig().WithAttempts(0),
+			problems: []string{"attempts: must be between 1 and 65534, got 0"},
+		},
+		{
+			name:     "negative factor",
+			cfg:      NewConfig().WithFactor(-1),
+			problems: []string{"factor: must be a non-negative number, got -1"},
+		},
+		{
+			name:     "nan jitter",
+			cfg:      NewConfig().WithJitter(math.NaN()),
+			problems: []string{"jitter: must be a non-negative number, got NaN"},
+		},
+		{
+			name:     "delay range",
+			cfg:      NewConfig().WithMinDelay(2 * time.Second).WithMaxDelay(time.Second),
+			problems: []string{"min_delay: 2s is greater than max_delay 1s"},
+		},
+		{
+			name: "every problem",
+			cfg: NewConfig().WithContext(nil).WithCallback(nil).WithAttempts(0).WithInitDelay(0).
+				WithBaseUnit(0).WithTimeout(-1).WithRetryIfFunc(nil).WithErrorCodeFunc(nil),
+			problems: []string{
+				"context: must not be nil",
+				"callback: must not be nil",
+				"attempts: must be between 1 and 65534, got 0",
+				"delay: must be at least 1ns, got 0s",
+				"base_unit: must be at least 1ns, got 0s",
+				"timeout: must be at least 0s, got -1ns",
+				"retry_if: must not be nil",
+				"error_code: must not be nil",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+
+			var cerr *ConfigError
+			assert.True(t, errors.As(err, &cerr))
+			assert.Equal(t, tt.problems, cerr.Problems)
+		})
+	}
+}
+
+func TestNewStrict(t *testing.T) {
+	r, err := NewStrict(nil)
+	assert.NoError(t, err)
+	assert.NotNil(t, r)
+
+	cfg := NewConfig().WithAttempts(100000).WithFactor(-1)
+	r, err = NewStrict(cfg)
+	assert.Nil(t, r)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "attempts")
+	assert.Contains(t, err.Error(), "factor")
+
+	// NewStrict 不会修改无效的配置，New 仍然保持宽松的行为
+	// NewStrict does not modify the invalid configuration, New keeps its lenient behaviour
+	assert.Equal(t, uint64(100000), cfg.attempts)
+	assert.NotNil(t, New(cfg))
+	assert.Equal(t, uint64(100000), cfg.attempts)
+}
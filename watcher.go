@@ -0,0 +1,315 @@
+package retry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 默认的文件检查间隔
+// Default interval between file checks
+const defaultWatchInterval = 5 * time.Second
+
+// PolicyChange 结构体描述了一次策略文件重新加载的结果
+// The PolicyChange struct describes the outcome of one policy file reload
+type PolicyChange struct {
+	Path    string              // 策略文件的路径 Path of the policy file
+	Added   []string            // 新增的策略 Added policies
+	Removed []string            // 删除的策略 Removed policies
+	Updated []string            // 修改的策略 Updated policies
+	Diffs   map[string][]string // 修改的策略中每个字段的变化，例如 "attempts: 3 -> 5" Field changes of updated policies, such as "attempts: 3 -> 5"
+	Err     error               // 读取、解析或检查失败时的错误，此时注册表保持不变 Error when reading, parsing or validating failed, in which case the registry is unchanged
+}
+
+// PolicyChangeFunc 类型定义了策略文件重新加载后调用的函数类型
+// The PolicyChangeFunc type defines the function type called after the policy file is reloaded
+type PolicyChangeFunc = func(change PolicyChange)
+
+// PolicyWatcher 结构体通过轮询修改时间监视一个 JSON 策略文件，并将其中的策略原子地替换到注册表中
+// 文件的内容是策略名称到配置的映射，例如 {"payment-write": {"attempts": 5, "backoff": "fixed(1s)"}}，未设置的字段使用默认值
+// 已经开始执行的调用会继续使用旧的策略，之后的 Do 调用使用新的策略
+// The PolicyWatcher struct watches a JSON policy file by polling its modification time and atomically swaps its policies into a registry
+// The file maps policy names to configurations, such as {"payment-write": {"attempts": 5, "backoff": "fixed(1s)"}}, fields not set use the defaults
+// Calls already running keep using the old policies while later Do calls use the new ones
+type PolicyWatcher struct {
+	registry *Registry
+	path     string
+	interval time.Duration
+	onChange PolicyChangeFunc
+
+	mu         sync.Mutex
+	modTime    time.Time
+	size       int64
+	failure    string             // 最后一次报告的错误，避免每次轮询都重复报告 Last reported error, to avoid reporting it again on every poll
+	managed    map[string][]byte  // 由本实例管理的策略及其规范化的 JSON Policies managed by this instance and their normalized JSON
+	overridden map[string]*policy // 被文件覆盖的已经注册的策略，从文件中删除时恢复 Registered policies overridden by the file, restored when removed from the file
+}
+
+// NewPolicyWatcher 函数创建一个新的 PolicyWatcher 实例，监视 path 指定的文件并更新 registry
+// The NewPolicyWatcher function creates a new PolicyWatcher instance that watches the file at path and updates registry
+func NewPolicyWatcher(registry *Registry, path string) *PolicyWatcher {
+	return &PolicyWatcher{
+		registry:   registry,
+		path:       path,
+		interval:   defaultWatchInterval,
+		onChange:   func(PolicyChange) {},
+		managed:    make(map[string][]byte),
+		overridden: make(map[string]*policy),
+	}
+}
+
+// WithInterval 方法设置检查文件的间隔并返回 PolicyWatcher 实例
+// The WithInterval method sets the interval between file checks and returns the PolicyWatcher instance
+func (w *PolicyWatcher) WithInterval(interval time.Duration) *PolicyWatcher {
+	if interval > 0 {
+		w.interval = interval
+	}
+	return w
+}
+
+// WithOnChange 方法设置重新加载后调用的函数并返回 PolicyWatcher 实例，策略有变化或加载失败时才会调用
+// 调用时不持有 PolicyWatcher 的锁，因此函数中可以再次调用 Check 或 Reload
+// The WithOnChange method sets the function called after a reload and returns the PolicyWatcher instance, it is only called when policies changed or loading failed
+// The lock of the PolicyWatcher is not held during the call, so the function may call Check or Reload again
+func (w *PolicyWatcher) WithOnChange(fn PolicyChangeFunc) *PolicyWatcher {
+	if fn != nil {
+		w.onChange = fn
+	}
+	return w
+}
+
+// Run 方法立即加载一次策略文件，之后按间隔检查文件是否被修改，直到 ctx 结束
+// The Run method loads the policy file once immediately, then checks for modifications at every interval until ctx is done
+func (w *PolicyWatcher) Run(ctx context.Context) {
+	_ = w.Check()
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_ = w.Check()
+		}
+	}
+}
+
+// Check 方法检查策略文件的修改时间和大小，有变化时重新加载，返回加载时的错误
+// The Check method checks the modification time and size of the policy file and reloads it when they changed, returning the loading error
+func (w *PolicyWatcher) Check() error {
+	info, err := os.Stat(w.path)
+
+	w.mu.Lock()
+	change, err := w.check(info, err)
+	w.mu.Unlock()
+
+	return w.report(change, err)
+}
+
+// check 方法根据文件的状态决定是否重新加载，返回需要报告的变化，调用方需要持有锁
+// The check method decides from the file state whether to reload, returning the change to report, the caller must hold the lock
+func (w *PolicyWatcher) check(info os.FileInfo, err error) (*PolicyChange, error) {
+	if err != nil {
+		// 清除记录的文件状态，文件重新出现时会被重新加载
+		// Clear the recorded file state so the file is reloaded when it reappears
+		w.modTime, w.size = time.Time{}, 0
+		return w.fail(err)
+	}
+
+	if info.ModTime().Equal(w.modTime) && info.Size() == w.size {
+		return nil, nil
+	}
+
+	w.modTime = info.ModTime()
+	w.size = info.Size()
+	return w.reload()
+}
+
+// Reload 方法无论文件是否被修改都重新加载策略文件
+// The Reload method reloads the policy file whether or not it was modified
+func (w *PolicyWatcher) Reload() error {
+	w.mu.Lock()
+	change, err := w.reload()
+	w.mu.Unlock()
+
+	return w.report(change, err)
+}
+
+// report 方法在释放锁之后调用变化函数，然后返回 err
+// The report method calls the change function after the lock is released, then returns err
+func (w *PolicyWatcher) report(change *PolicyChange, err error) error {
+	if change != nil {
+		w.onChange(*change)
+	}
+	return err
+}
+
+// fail 方法记录加载失败，注册表保持不变，同一个错误只返回一次需要报告的变化
+// The fail method records a loading failure, leaving the registry unchanged, and returns a change to report only once for the same error
+func (w *PolicyWatcher) fail(err error) (*PolicyChange, error) {
+	if msg := err.Error(); msg != w.failure {
+		w.failure = msg
+		return &PolicyChange{Path: w.path, Err: err}, err
+	}
+	return nil, err
+}
+
+// reload 方法读取、解析并检查策略文件，全部有效时才原子地更新注册表，返回需要报告的变化，调用方需要持有锁
+// 文件中的策略覆盖已经注册的同名策略时保存旧的策略，策略从文件中删除时恢复它
+// The reload method reads, parses and validates the policy file and only updates the registry atomically when everything is valid, returning the change to report, the caller must hold the lock
+// When a policy in the file overrides an already registered one the old policy is kept, and it is restored when the policy is removed from the file
+func (w *PolicyWatcher) reload() (*PolicyChange, error) {
+	data, err := os.ReadFile(w.path)
+	if err != nil {
+		return w.fail(err)
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return w.fail(fmt.Errorf("retry policy file %s: %w", w.path, err))
+	}
+
+	// 检查所有策略，收集所有的问题
+	// Validate every policy and collect every problem
+	names := make([]string, 0, len(raw))
+	for name := range raw {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var problems []string
+	policies := make(map[string]*policy, len(raw))
+	normalized := make(map[string][]byte, len(raw))
+	for _, name := range names {
+		conf := NewConfig()
+		if err := json.Unmarshal(raw[name], conf); err != nil {
+			problems = append(problems, policyProblems(name, err)...)
+			continue
+		}
+
+		p, err := newPolicy(name, conf)
+		if err != nil {
+			problems = append(problems, policyProblems(name, err)...)
+			continue
+		}
+
+		policies[name] = p
+		normalized[name], _ = json.Marshal(conf)
+	}
+
+	if len(problems) > 0 {
+		return w.fail(&ConfigError{Problems: problems})
+	}
+
+	// 计算与上一次加载的差异
+	// Compute the differences from the previous load
+	change := PolicyChange{Path: w.path, Diffs: make(map[string][]string)}
+	var removed []string
+	for _, name := range names {
+		prev, ok := w.managed[name]
+		switch {
+		case !ok:
+			change.Added = append(change.Added, name)
+		case !bytes.Equal(prev, normalized[name]):
+			change.Updated = append(change.Updated, name)
+			change.Diffs[name] = diffPolicyJSON(prev, normalized[name])
+		default:
+			// 没有变化的策略不需要替换
+			// Unchanged policies do not need to be replaced
+			delete(policies, name)
+		}
+	}
+	for name := range w.managed {
+		if _, ok := raw[name]; !ok {
+			change.Removed = append(change.Removed, name)
+		}
+	}
+	sort.Strings(change.Removed)
+
+	// 删除的策略覆盖过已经注册的策略时恢复旧的策略
+	// Restore the old policy when a removed policy overrode a registered one
+	for _, name := range change.Removed {
+		if prev, ok := w.overridden[name]; ok {
+			policies[name] = prev
+			delete(w.overridden, name)
+			continue
+		}
+		removed = append(removed, name)
+	}
+
+	old := w.registry.swap(policies, removed)
+	for _, name := range change.Added {
+		if prev, ok := old[name]; ok {
+			w.overridden[name] = prev
+		}
+	}
+	w.managed = normalized
+	w.failure = ""
+
+	if len(change.Added)+len(change.Removed)+len(change.Updated) > 0 {
+		return &change, nil
+	}
+	return nil, nil
+}
+
+// policyProblems 函数将策略的错误转换为带有策略名称的问题列表
+// The policyProblems function converts the error of a policy into a list of problems prefixed with the policy name
+func policyProblems(name string, err error) []string {
+	var problems []string
+	var cerr *ConfigError
+	if errors.As(err, &cerr) {
+		for _, problem := range cerr.Problems {
+			problems = append(problems, name+"."+problem)
+		}
+		return problems
+	}
+	return []string{name + ": " + err.Error()}
+}
+
+// diffPolicyJSON 函数比较两个配置的 JSON，返回每个变化字段的描述，例如 "attempts: 3 -> 5"
+// The diffPolicyJSON function compares the JSON of two configurations and describes every changed field, such as "attempts: 3 -> 5"
+func diffPolicyJSON(prev, next []byte) []string {
+	var before, after map[string]any
+	_ = json.Unmarshal(prev, &before)
+	_ = json.Unmarshal(next, &after)
+
+	keys := make(map[string]struct{}, len(before)+len(after))
+	for k := range before {
+		keys[k] = struct{}{}
+	}
+	for k := range after {
+		keys[k] = struct{}{}
+	}
+
+	diffs := make([]string, 0, len(keys))
+	for k := range keys {
+		b, bok := before[k]
+		a, aok := after[k]
+		if bok && aok && reflect.DeepEqual(a, b) {
+			continue
+		}
+		diffs = append(diffs, fmt.Sprintf("%s: %s -> %s", k, formatPolicyValue(b, bok), formatPolicyValue(a, aok)))
+	}
+	sort.Strings(diffs)
+
+	return diffs
+}
+
+// formatPolicyValue 函数格式化差异中的字段值，不存在的字段显示为 <unset>
+// The formatPolicyValue function formats a field value in a diff, absent fields are shown as <unset>
+func formatPolicyValue(v any, ok bool) string {
+	if !ok {
+		return "<unset>"
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
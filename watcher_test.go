@@ -0,0 +1,194 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writePolicyFile 函数写入策略文件，并将修改时间推进到 mtime，保证每次写入都能被检测到
+// The writePolicyFile function writes the policy file and moves its modification time to mtime so every write is detected
+func writePolicyFile(t *testing.T, path, content string, mtime time.Time) {
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	assert.NoError(t, os.Chtimes(path, mtime, mtime))
+}
+
+func TestPolicyWatcher_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policies.json")
+	mtime := time.Now()
+	writePolicyFile(t, path, `{"payment-write": {"attempts": 3, "delay": "1ms"}, "background-job": {"attempts": 10}}`, mtime)
+
+	var changes []PolicyChange
+	registry := NewRegistry()
+	w := NewPolicyWatcher(registry, path).WithOnChange(func(change PolicyChange) {
+		changes = append(changes, change)
+	})
+
+	assert.NoError(t, w.Check())
+	assert.Len(t, changes, 1)
+	assert.Equal(t, []string{"background-job", "payment-write"}, changes[0].Added)
+	assert.Equal(t, []string{"background-job", PolicyDefault, PolicyFix, "payment-write"}, registry.Names())
+
+	// 文件没有变化时不会重新加载
+	// The file is not reloaded when it has not changed
+	assert.NoError(t, w.Check())
+	assert.Len(t, changes, 1)
+
+	// 保存旧策略的 Retry 实例，替换后它仍然使用旧的配置
+	// Keep the Retry of the old policy, it keeps the old configuration after the swap
+	old, err := registry.Retry("payment-write")
+	assert.NoError(t, err)
+
+	mtime = mtime.Add(time.Second)
+	writePolicyFile(t, path, `{"payment-write": {"attempts": 5, "delay": "1ms", "backoff": "fixed(1ms)"}}`, mtime)
+	assert.NoError(t, w.Check())
+	assert.Len(t, changes, 2)
+	assert.Equal(t, []string{"payment-write"}, changes[1].Updated)
+	assert.Equal(t, []string{"background-job"}, changes[1].Removed)
+	assert.Equal(t, []string{`attempts: 3 -> 5`, `backoff: <unset> -> "fixed(delay=1ms)"`}, changes[1].Diffs["payment-write"])
+	assert.Equal(t, []string{PolicyDefault, PolicyFix, "payment-write"}, registry.Names())
+
+	cfg, err := registry.Config("payment-write")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(5), cfg.attempts)
+	assert.Equal(t, uint64(3), old.config.attempts)
+}
+
+func TestPolicyWatcher_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policies.json")
+	mtime := time.Now()
+	writePolicyFile(t, path, `{"payment-write": {"attempts": 3}}`, mtime)
+
+	var changes []PolicyChange
+	registry := NewRegistry()
+	w := NewPolicyWatcher(registry, path).WithOnChange(func(change PolicyChange) {
+		changes = append(changes, change)
+	})
+	assert.NoError(t, w.Check())
+
+	// 任何一个策略无效时，整个文件都不会生效
+	// When any policy is invalid, nothing in the file takes effect
+	mtime = mtime.Add(time.Second)
+	writePolicyFile(t, path, `{"payment-write": {"attempts": 4}, "bad": {"attempts": 0, "factor": -1}}`, mtime)
+	err := w.Check()
+
+	var cerr *ConfigError
+	assert.True(t, errors.As(err, &cerr))
+	assert.Equal(t, []string{"bad.attempts: must be between 1 and 65534, got 0", "bad.factor: must be a non-negative number, got -1"}, cerr.Problems)
+	assert.Len(t, changes, 2)
+	assert.Equal(t, err, changes[1].Err)
+
+	cfg, _ := registry.Config("payment-write")
+	assert.Equal(t, uint64(3), cfg.attempts)
+	_, err = registry.Config("bad")
+	assert.ErrorIs(t, err, ErrorPolicyNotFound)
+
+	// 同一个错误只报告一次
+	// The same error is only reported once
+	assert.Error(t, w.Reload())
+	assert.Len(t, changes, 2)
+
+	// 文件被删除时报告错误
+	// An error is reported when the file is removed
+	assert.NoError(t, os.Remove(path))
+	assert.Error(t, w.Check())
+	assert.Len(t, changes, 3)
+	assert.True(t, os.IsNotExist(changes[2].Err))
+}
+
+func TestPolicyWatcher_ReentrantCallback(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policies.json")
+	writePolicyFile(t, path, `{"payment-write": {"attempts": 3}}`, time.Now())
+
+	// 变化函数中再次调用 Check 和 Reload 不会死锁
+	// Calling Check and Reload again from the change function does not deadlock
+	var calls int
+	w := NewPolicyWatcher(NewRegistry(), path)
+	w.WithOnChange(func(change PolicyChange) {
+		calls++
+		assert.NoError(t, w.Check())
+		assert.NoError(t, w.Reload())
+	})
+
+	done := make(chan error, 1)
+	go func() { done <- w.Check() }()
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+		assert.Equal(t, 1, calls)
+	case <-time.After(time.Second):
+		t.Fatal("change function deadlocked the watcher")
+	}
+}
+
+func TestPolicyWatcher_RestoreOverridden(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policies.json")
+	mtime := time.Now()
+	writePolicyFile(t, path, `{"fix": {"attempts": 7}}`, mtime)
+
+	registry := NewRegistry()
+	w := NewPolicyWatcher(registry, path)
+	assert.NoError(t, w.Check())
+
+	cfg, err := registry.Config(PolicyFix)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(7), cfg.attempts)
+
+	// 从文件中删除后恢复预先注册的策略
+	// The pre-registered policy is restored once it is removed from the file
+	mtime = mtime.Add(time.Second)
+	writePolicyFile(t, path, `{}`, mtime)
+	assert.NoError(t, w.Check())
+
+	cfg, err = registry.Config(PolicyFix)
+	assert.NoError(t, err)
+	assert.Equal(t, FixConfig().attempts, cfg.attempts)
+	assert.Equal(t, 0.0, cfg.factor)
+}
+
+func TestPolicyWatcher_Run(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policies.json")
+	mtime := time.Now()
+	writePolicyFile(t, path, `{"idempotent-read": {"attempts": 2}}`, mtime)
+
+	var mu sync.Mutex
+	var changes []PolicyChange
+	registry := NewRegistry()
+	w := NewPolicyWatcher(registry, path).WithInterval(5 * time.Millisecond).WithOnChange(func(change PolicyChange) {
+		mu.Lock()
+		changes = append(changes, change)
+		mu.Unlock()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool {
+		_, err := registry.Config("idempotent-read")
+		return err == nil
+	}, time.Second, 5*time.Millisecond)
+
+	writePolicyFile(t, path, `{"idempotent-read": {"attempts": 4}}`, mtime.Add(time.Second))
+	assert.Eventually(t, func() bool {
+		cfg, _ := registry.Config("idempotent-read")
+		return cfg.attempts == 4
+	}, time.Second, 5*time.Millisecond)
+
+	cancel()
+	<-done
+
+	mu.Lock()
+	assert.Len(t, changes, 2)
+	mu.Unlock()
+}
@@ -0,0 +1,147 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+)
+
+// FlushFunc 类型定义了把缓冲的数据提交到底层存储的函数类型，例如 bufio.Writer.Flush 或 os.File.Sync
+// The FlushFunc type defines the function type that commits buffered data to the underlying storage, such as bufio.Writer.Flush or os.File.Sync
+type FlushFunc = func() error
+
+// flusher 和 syncer 接口用于发现底层 Writer 的提交方法
+// The flusher and syncer interfaces are used to discover the commit method of the underlying Writer
+type (
+	flusher interface{ Flush() error }
+	syncer  interface{ Sync() error }
+)
+
+// RetryingWriter 结构体是一个带重试的 io.Writer，短写入或出错时从没有写入的剩余部分继续写入
+// 上下文的错误和写入已经关闭的目标的错误不会重试，它不能被多个 goroutine 并发使用
+// The RetryingWriter struct is an io.Writer with retries that continues from the unwritten remainder after short writes or errors
+// Context errors and errors from writing to closed targets are not retried, and it must not be used by multiple goroutines concurrently
+type RetryingWriter struct {
+	ctx     context.Context
+	w       io.Writer
+	retry   *Retry
+	flush   FlushFunc
+	written int64
+}
+
+// NewRetryingWriter 函数创建一个新的 RetryingWriter 实例，w 实现了 Flush() error 或 Sync() error 时会被用作提交的方法
+// conf 为空时使用默认配置，第一次写入总是立即进行，配置会被复制
+// The NewRetryingWriter function creates a new RetryingWriter instance, Flush() error or Sync() error of w is used as the commit method when implemented
+// The default configuration is used when conf is nil, the first write always happens immediately and the configuration is copied
+func NewRetryingWriter(ctx context.Context, w io.Writer, conf *Config) *RetryingWriter {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	rw := &RetryingWriter{
+		ctx:   ctx,
+		w:     w,
+		retry: newImmediateRetry(conf, isRetryableWriteError),
+	}
+
+	switch v := w.(type) {
+	case flusher:
+		rw.flush = v.Flush
+	case syncer:
+		rw.flush = v.Sync
+	}
+
+	return rw
+}
+
+// WithFlushFunc 方法设置 Flush 调用的提交函数并返回 RetryingWriter 实例
+// The WithFlushFunc method sets the commit function called by Flush and returns the RetryingWriter instance
+func (w *RetryingWriter) WithFlushFunc(fn FlushFunc) *RetryingWriter {
+	w.flush = fn
+	return w
+}
+
+// Written 方法返回已经成功写入的总字节数
+// The Written method returns the total number of bytes written successfully
+func (w *RetryingWriter) Written() int64 {
+	return w.written
+}
+
+// Write 方法写入 p，短写入或出错时从没有写入的剩余部分继续，返回值 n 是本次调用确实写入的字节数
+// 重试次数用完时返回已经写入的字节数和最后一次的错误，上下文结束时返回上下文的错误
+// The Write method writes p, continuing from the unwritten remainder after short writes or errors, n is the number of bytes this call actually wrote
+// When the attempts run out it returns the bytes written so far and the last error, and the context error when the context is done
+func (w *RetryingWriter) Write(p []byte) (int, error) {
+	var (
+		n       int
+		lastErr error
+	)
+
+	result := w.retry.TryOnConflictContext(w.ctx, func() (any, error) {
+		for n < len(p) {
+			m, err := w.w.Write(p[n:])
+			if m < 0 || m > len(p)-n {
+				m = 0
+			}
+			n += m
+			w.written += int64(m)
+
+			if err != nil {
+				lastErr = err
+				return nil, err
+			}
+
+			// 没有进展的短写入视为一次失败
+			// A short write without progress counts as a failure
+			if m == 0 {
+				lastErr = io.ErrShortWrite
+				return nil, lastErr
+			}
+		}
+		return nil, nil
+	})
+
+	return n, w.finish(result, lastErr)
+}
+
+// Flush 方法调用提交函数，出错时重试，没有提交函数时直接返回
+// The Flush method calls the commit function and retries on errors, returning immediately without a commit function
+func (w *RetryingWriter) Flush() error {
+	if w.flush == nil {
+		return nil
+	}
+
+	var lastErr error
+	result := w.retry.TryOnConflictContext(w.ctx, func() (any, error) {
+		if err := w.flush(); err != nil {
+			lastErr = err
+			return nil, err
+		}
+		return nil, nil
+	})
+
+	return w.finish(result, lastErr)
+}
+
+// finish 方法根据执行结果返回错误
+// The finish method returns the error for the execution result
+func (w *RetryingWriter) finish(result *Result, lastErr error) error {
+	if result.IsSuccess() {
+		return nil
+	}
+	if err := w.ctx.Err(); err != nil {
+		return err
+	}
+	if lastErr != nil {
+		return lastErr
+	}
+	return result.TryError()
+}
+
+// isRetryableWriteError 函数判断写入的错误是否可以重试：上下文的错误和写入已经关闭的目标的错误不可以重试
+// The isRetryableWriteError function reports whether a write error can be retried: context errors and errors from writing to closed targets cannot
+func isRetryableWriteError(err error) bool {
+	return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) &&
+		!errors.Is(err, os.ErrClosed) && !errors.Is(err, io.ErrClosedPipe)
+}
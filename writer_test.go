@@ -0,0 +1,157 @@
+package retry
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// flakySink 结构体模拟一个每次最多写入 limit 个字节、并按顺序返回预先设置的错误的目标
+// The flakySink struct simulates a target that writes at most limit bytes at a time and returns preset errors in order
+type flakySink struct {
+	bytes.Buffer
+	limit    int
+	errs     []error
+	syncErrs []error
+	syncs    int
+}
+
+func (s *flakySink) Write(p []byte) (int, error) {
+	if len(p) > s.limit {
+		p = p[:s.limit]
+	}
+	n, _ := s.Buffer.Write(p)
+	if len(s.errs) > 0 {
+		err := s.errs[0]
+		s.errs = s.errs[1:]
+		return n, err
+	}
+	return n, nil
+}
+
+func (s *flakySink) Sync() error {
+	s.syncs++
+	if len(s.syncErrs) > 0 {
+		err := s.syncErrs[0]
+		s.syncErrs = s.syncErrs[1:]
+		return err
+	}
+	return nil
+}
+
+func newTestWriterConfig() *Config {
+	return NewConfig().
+		WithInitDelay(time.Millisecond).
+		WithBackOffFunc(func(int64) time.Duration { return 0 })
+}
+
+func TestRetryingWriter_ShortWrites(t *testing.T) {
+	data := bytes.Repeat([]byte("abc"), 100)
+	sink := &flakySink{limit: 7}
+
+	w := NewRetryingWriter(context.Background(), sink, newTestWriterConfig())
+	n, err := w.Write(data)
+	assert.NoError(t, err)
+	assert.Equal(t, len(data), n)
+	assert.Equal(t, data, sink.Bytes())
+	assert.Equal(t, int64(len(data)), w.Written())
+}
+
+func TestRetryingWriter_TransientErrors(t *testing.T) {
+	e := errors.New("stale file handle")
+	sink := &flakySink{limit: 4, errs: []error{e, nil, e}}
+
+	w := NewRetryingWriter(context.Background(), sink, newTestWriterConfig())
+	n, err := w.Write([]byte("hello world"))
+	assert.NoError(t, err)
+	assert.Equal(t, 11, n)
+
+	// 出错之前写入的部分不会被重复写入
+	// Bytes written before an error are not written again
+	assert.Equal(t, "hello world", sink.String())
+}
+
+func TestRetryingWriter_AttemptsExceeded(t *testing.T) {
+	e := errors.New("stale file handle")
+	sink := &flakySink{limit: 2, errs: []error{e, e, e, e}}
+
+	w := NewRetryingWriter(context.Background(), sink, newTestWriterConfig().WithAttempts(3))
+	n, err := w.Write([]byte("hello world"))
+
+	// 报告的字节数与确实写入的字节数一致
+	// The reported byte count matches the bytes actually written
+	assert.Equal(t, e, err)
+	assert.Equal(t, 6, n)
+	assert.Equal(t, "hello ", sink.String())
+	assert.Equal(t, int64(6), w.Written())
+}
+
+func TestRetryingWriter_NoProgress(t *testing.T) {
+	sink := &flakySink{limit: 0}
+
+	w := NewRetryingWriter(context.Background(), sink, newTestWriterConfig().WithAttempts(3))
+	n, err := w.Write([]byte("hello"))
+	assert.Equal(t, io.ErrShortWrite, err)
+	assert.Equal(t, 0, n)
+}
+
+func TestRetryingWriter_NotRetryable(t *testing.T) {
+	sink := &flakySink{limit: 100, errs: []error{os.ErrClosed, nil}}
+
+	w := NewRetryingWriter(context.Background(), sink, newTestWriterConfig())
+	_, err := w.Write([]byte("hello"))
+	assert.Equal(t, os.ErrClosed, err)
+	assert.Len(t, sink.errs, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w = NewRetryingWriter(ctx, sink, newTestWriterConfig())
+	n, err := w.Write([]byte("hello"))
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 0, n)
+}
+
+func TestRetryingWriter_Flush(t *testing.T) {
+	e := errors.New("sync failed")
+	sink := &flakySink{limit: 100, syncErrs: []error{e, e}}
+
+	// Sync() error 会被用作默认的提交方法
+	// Sync() error is used as the default commit method
+	w := NewRetryingWriter(context.Background(), sink, newTestWriterConfig())
+	assert.NoError(t, w.Flush())
+	assert.Equal(t, 3, sink.syncs)
+
+	sink.syncErrs = []error{e, e, e}
+	assert.Equal(t, e, w.Flush())
+
+	// Flush() error 同样会被发现
+	// Flush() error is discovered as well
+	var buf bytes.Buffer
+	bw := bufio.NewWriter(&buf)
+	w = NewRetryingWriter(context.Background(), bw, newTestWriterConfig())
+	_, err := w.Write([]byte("buffered"))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, buf.Len())
+	assert.NoError(t, w.Flush())
+	assert.Equal(t, "buffered", buf.String())
+
+	// 没有提交函数时 Flush 直接返回
+	// Flush returns immediately without a commit function
+	w = NewRetryingWriter(context.Background(), &buf, newTestWriterConfig())
+	assert.NoError(t, w.Flush())
+
+	calls := 0
+	w.WithFlushFunc(func() error {
+		calls++
+		return nil
+	})
+	assert.NoError(t, w.Flush())
+	assert.Equal(t, 1, calls)
+}